@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SinkNone     = ""
+	SinkStdout   = "stdout"
+	SinkFile     = "file"
+	SinkDatabase = "database"
+)
+
+// ErrNotQueryable is returned by Query if the sink can not be queried, e.g. stdout.
+var ErrNotQueryable = errors.New("audit sink can not be queried")
+
+// Sink writes the audit events somewhere, sinks implementing Querier can be queried by the admin API.
+type Sink interface {
+	Write(e *model.AuditEvent) error
+	Close() error
+}
+
+// Querier returns the events matching the query, the newest events first.
+type Querier interface {
+	Query(q *model.AuditQuery) ([]*model.AuditEvent, error)
+}
+
+// Options are the settings of the sink, the file settings are only used by the file sink.
+type Options struct {
+	Sink           string
+	File           string
+	FileMaxSize    int64
+	FileMaxBackups int
+}
+
+var (
+	lock    sync.RWMutex
+	current Sink
+)
+
+// NewSink returns the sink of the options, nil is returned if auditing is disabled.
+func NewSink(opts *Options) (Sink, error) {
+	switch opts.Sink {
+	case SinkNone:
+		return nil, nil
+	case SinkStdout:
+		return &writerSink{w: os.Stdout}, nil
+	case SinkFile:
+		return newFileSink(opts.File, opts.FileMaxSize, opts.FileMaxBackups)
+	case SinkDatabase:
+		return &databaseSink{}, nil
+	}
+	return nil, errors.Errorf(errUnknownSink, opts.Sink)
+}
+
+// SetSink replaces the current sink, the previous one is closed.
+func SetSink(s Sink) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if current != nil {
+		if err := current.Close(); err != nil {
+			logrus.Errorf("failed to close audit sink, err: %v", err)
+		}
+	}
+	current = s
+}
+
+// Enabled reports whether the events are recorded.
+func Enabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current != nil
+}
+
+// UsesDatabase reports whether the events are written to the database, so the database must be set.
+func UsesDatabase() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	_, ok := current.(*databaseSink)
+	return ok
+}
+
+// Record writes the event to the current sink, errors are logged since the API call is done anyway.
+func Record(e *model.AuditEvent) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	if current == nil {
+		return
+	}
+	if err := current.Write(e); err != nil {
+		logrus.Errorf("failed to write audit event %s of %s, err: %v", e.Action, e.Fqdn, err)
+	}
+}
+
+// Query returns the events of the query from the current sink.
+func Query(q *model.AuditQuery) ([]*model.AuditEvent, error) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	qr, ok := current.(Querier)
+	if !ok {
+		return nil, ErrNotQueryable
+	}
+	return qr.Query(q)
+}
+
+// writerSink writes the events as JSON lines, it is not queryable
+type writerSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *writerSink) Write(e *model.AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (s *writerSink) Close() error {
+	return nil
+}
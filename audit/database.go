@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"encoding/json"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// databaseSink keeps the events in the audit_log table, the event is kept as JSON so the table does not follow the model
+type databaseSink struct{}
+
+func (s *databaseSink) Write(e *model.AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.GetDatabase().InsertAuditLog(&model.AuditLog{
+		Fqdn:      e.Fqdn,
+		Action:    e.Action,
+		Content:   string(b),
+		CreatedOn: e.Time.UnixNano(),
+	})
+	return err
+}
+
+func (s *databaseSink) Query(q *model.AuditQuery) ([]*model.AuditEvent, error) {
+	logs, err := database.GetDatabase().ListAuditLogs(q.Fqdn, q.Since, q.Until, q.Limit)
+	if err != nil {
+		return nil, errors.Wrapf(err, errQueryDatabase, q.Fqdn)
+	}
+
+	result := make([]*model.AuditEvent, 0)
+	for _, l := range logs {
+		e := &model.AuditEvent{}
+		if err := json.Unmarshal([]byte(l.Content), e); err != nil {
+			return nil, errors.Wrapf(err, errQueryDatabase, q.Fqdn)
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (s *databaseSink) Close() error {
+	return nil
+}
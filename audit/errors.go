@@ -0,0 +1,10 @@
+package audit
+
+const (
+	errEmptyFile       = "audit file must be set for the file sink"
+	errInvalidRotation = "audit file max size must be at least 1 byte and max backups must not be negative, got %d and %d"
+	errOpenFile        = "failed to open audit file %s"
+	errQueryDatabase   = "failed to query audit logs of %s from database"
+	errRotateFile      = "failed to rotate audit file %s"
+	errUnknownSink     = "unknown audit sink %q, expected stdout, file or database"
+)
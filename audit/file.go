@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// fileSink writes the events as JSON lines to the file, the file is rotated to <file>.1 once it exceeds the max size
+// and at most max backups are kept, e.g. audit.log, audit.log.1, audit.log.2
+type fileSink struct {
+	lock       sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func newFileSink(path string, maxSize int64, maxBackups int) (*fileSink, error) {
+	if path == "" {
+		return nil, errors.New(errEmptyFile)
+	}
+	if maxSize < 1 || maxBackups < 0 {
+		return nil, errors.Errorf(errInvalidRotation, maxSize, maxBackups)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrapf(err, errOpenFile, path)
+	}
+
+	s := &fileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) Write(e *model.AuditEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.size > 0 && s.size+int64(len(b)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	return err
+}
+
+// Query scans the backups from the oldest one and the current file, so the events are kept in order
+func (s *fileSink) Query(q *model.AuditQuery) ([]*model.AuditEvent, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	events := make([]*model.AuditEvent, 0)
+	for i := s.maxBackups; i >= 0; i-- {
+		es, err := readEvents(s.backupPath(i), q)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, es...)
+	}
+
+	result := make([]*model.AuditEvent, 0)
+	for i := len(events) - 1; i >= 0 && len(result) < q.Limit; i-- {
+		result = append(result, events[i])
+	}
+	return result, nil
+}
+
+func (s *fileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.f.Close()
+}
+
+// Used to open the file for appending, the size is counted from the existing content
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrapf(err, errOpenFile, s.path)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, errOpenFile, s.path)
+	}
+
+	s.f = f
+	s.size = info.Size()
+	return nil
+}
+
+// Used to shift the backups and reopen the file, the oldest backup is dropped
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return errors.Wrapf(err, errRotateFile, s.path)
+	}
+
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, errRotateFile, s.path)
+		}
+		return s.open()
+	}
+
+	for i := s.maxBackups - 1; i >= 0; i-- {
+		if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, errRotateFile, s.path)
+		}
+	}
+	return s.open()
+}
+
+// Used to get the path of the backup, the current file is returned for 0
+func (s *fileSink) backupPath(i int) string {
+	if i == 0 {
+		return s.path
+	}
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// Used to read the events of the file matching the query, a missing file has no events
+func readEvents(path string, q *model.AuditQuery) ([]*model.AuditEvent, error) {
+	result := make([]*model.AuditEvent, 0)
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return result, errors.Wrapf(err, errOpenFile, path)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e := &model.AuditEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			continue
+		}
+		if q.Match(e) {
+			result = append(result, e)
+		}
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// ListDatabaseDomains pages the domains of the zone by the token ID, it is shared by the backends keeping tokens in the database.
+// The inspect func converts a token to the domain, tokens are scanned until the page is full since most filters are applied to the domain.
+func ListDatabaseDomains(ctx context.Context, zone string, leaseTime time.Duration, opts *model.ListOptions, inspect func(t *model.Token) (model.DomainInfo, error)) (model.DomainList, error) {
+	l := model.DomainList{
+		Items: make([]model.DomainInfo, 0),
+	}
+
+	var afterID int64
+	if opts.Marker != "" {
+		id, err := strconv.ParseInt(opts.Marker, 10, 64)
+		if err != nil {
+			return l, Invalid(errors.Errorf("invalid marker %s", opts.Marker))
+		}
+		afterID = id
+	}
+
+	for {
+		tokens, err := database.GetDatabase().WithContext(ctx).ListTokens("."+zone, opts.LeaseStartBefore(leaseTime), afterID, opts.Limit)
+		if err != nil {
+			return l, Unavailable(errors.Wrapf(err, "failed to list tokens of zone %s", zone))
+		}
+
+		for _, t := range tokens {
+			afterID = t.ID
+
+			// the domains of a nested zone are listed with that zone
+			if z, err := MatchZone(t.Fqdn); err != nil || z.Name != zone {
+				continue
+			}
+
+			d, err := inspect(t)
+			if err != nil {
+				return l, err
+			}
+			if !opts.Match(&d) {
+				continue
+			}
+
+			l.Items = append(l.Items, d)
+			if len(l.Items) >= opts.Limit {
+				l.Next = strconv.FormatInt(t.ID, 10)
+				return l, nil
+			}
+		}
+
+		if len(tokens) < opts.Limit {
+			return l, nil
+		}
+	}
+}
+
+// PurgeDomain deletes the records of the token by the backend regardless of the lease, then deletes the token
+// and the records referencing it from the database. It is called by ForceDelete of the backends, which records the change.
+func PurgeDomain(ctx context.Context, b Backend, token *model.Token) error {
+	// delete A records & sub A records & wildcard records
+	opts := &model.DomainOptions{
+		Fqdn: token.Fqdn,
+	}
+	// the token may be out of lease, so the backend may report the records as expired
+	a, err := b.Get(opts)
+	if (err == nil && a.Fqdn != "") || KindOf(err) == KindExpired {
+		if err := b.Delete(opts); err != nil && KindOf(err) != KindNotFound {
+			return err
+		}
+	}
+
+	// delete CNAME records
+	cname, err := b.GetCNAME(opts)
+	if (err == nil && cname.Fqdn != "") || KindOf(err) == KindExpired {
+		if err := b.DeleteCNAME(opts); err != nil && KindOf(err) != KindNotFound {
+			return err
+		}
+	}
+
+	// delete TXT records
+	ts, _ := database.GetDatabase().WithContext(ctx).QueryExpiredTXTs(token.ID)
+	for _, t := range ts {
+		tOpts := &model.DomainOptions{
+			Fqdn: t.Fqdn,
+		}
+		if err := b.DeleteText(tOpts); err != nil && KindOf(err) != KindNotFound {
+			logrus.Error(err)
+			continue
+		}
+	}
+
+	// delete token records & referenced records
+	return database.GetDatabase().WithContext(ctx).DeleteToken(token.Token)
+}
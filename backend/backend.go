@@ -1,13 +1,13 @@
 package backend
 
 import (
+	"context"
+
 	"github.com/rancher/rdns-server/model"
 
 	"github.com/sirupsen/logrus"
 )
 
-var currentBackend Backend
-
 type Backend interface {
 	Get(opts *model.DomainOptions) (model.Domain, error)
 	Set(opts *model.DomainOptions) (model.Domain, error)
@@ -24,20 +24,33 @@ type Backend interface {
 	DeleteCNAME(opts *model.DomainOptions) error
 	GetToken(fqdn string) (string, error)
 	GetTokenCount() (int64, error)
-	GetZone() string
+	UpdateToken(fqdn, token string) error
+	SetSubToken(fqdn string, t *model.SubToken) error
+	GetSubTokens(fqdn string) ([]*model.SubToken, error)
+	DeleteSubToken(fqdn, sid string) error
 	GetName() string
 	MigrateFrozen(opts *model.MigrateFrozen) error
 	MigrateToken(opts *model.MigrateToken) error
 	MigrateRecord(opts *model.MigrateRecord) error
+	List(opts *model.ListOptions) (model.DomainList, error)
+	Inspect(fqdn string) (model.DomainInfo, error)
+	ForceDelete(fqdn string) error
+	ForceRenew(fqdn string) (model.DomainInfo, error)
+	// Watch sends the changes of the domain after the revision until the context is done, the changes of all domains kept by
+	// the backend are sent if the fqdn is empty. The watch starts from the current revision if the revision is 0,
+	// the expired error is returned if the changes after the revision are not kept anymore. The channel is closed once the watch ends.
+	Watch(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error)
+	// Ping checks whether the service keeping the records is reachable, it is used by the readiness check
+	Ping(ctx context.Context) error
+	// WithContext returns a copy of the backend bound to the context of a request, its logs carry the request ID
+	WithContext(ctx context.Context) Backend
 }
 
-func SetBackend(b Backend) {
-	currentBackend = b
-}
-
+// GetBackend returns the backend of the default zone, it is used for the operations which are not bound to a zone.
 func GetBackend() Backend {
-	if currentBackend == nil {
+	z, err := GetZone("")
+	if err != nil {
 		logrus.Fatal("not found any backend")
 	}
-	return currentBackend
+	return z.Backend
 }
@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"context"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	watchPollInterval = time.Second
+	watchBatchSize    = 100
+)
+
+// WithChanges returns the backend recording the changes made by b in the database, the changes are read by WatchDatabase.
+// It is used by the backends keeping tokens in the database, the etcdv3 backend watches etcd instead.
+func WithChanges(b Backend) Backend {
+	return &changedBackend{Backend: b}
+}
+
+// changedBackend records a change once a mutating call succeeds, other calls are passed to the backend.
+// ForceDelete calls PurgeDomain with the unwrapped backend, so a purged domain is recorded as one change.
+type changedBackend struct {
+	Backend
+	ctx context.Context
+}
+
+func (c *changedBackend) WithContext(ctx context.Context) Backend {
+	return &changedBackend{Backend: c.Backend.WithContext(ctx), ctx: ctx}
+}
+
+func (c *changedBackend) Set(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.Set(opts)
+	if err == nil {
+		recordChange(c.ctx, v.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) Update(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.Update(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) Delete(opts *model.DomainOptions) error {
+	err := c.Backend.Delete(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return err
+}
+
+func (c *changedBackend) Renew(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.Renew(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) SetText(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.SetText(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) UpdateText(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.UpdateText(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) DeleteText(opts *model.DomainOptions) error {
+	err := c.Backend.DeleteText(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return err
+}
+
+func (c *changedBackend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.SetCNAME(opts)
+	if err == nil {
+		recordChange(c.ctx, v.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	v, err := c.Backend.UpdateCNAME(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return v, err
+}
+
+func (c *changedBackend) DeleteCNAME(opts *model.DomainOptions) error {
+	err := c.Backend.DeleteCNAME(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return err
+}
+
+func (c *changedBackend) MigrateRecord(opts *model.MigrateRecord) error {
+	err := c.Backend.MigrateRecord(opts)
+	if err == nil {
+		recordChange(c.ctx, opts.Fqdn)
+	}
+	return err
+}
+
+func (c *changedBackend) ForceDelete(fqdn string) error {
+	err := c.Backend.ForceDelete(fqdn)
+	if err == nil {
+		recordChange(c.ctx, fqdn)
+	}
+	return err
+}
+
+func (c *changedBackend) ForceRenew(fqdn string) (model.DomainInfo, error) {
+	v, err := c.Backend.ForceRenew(fqdn)
+	if err == nil {
+		recordChange(c.ctx, fqdn)
+	}
+	return v, err
+}
+
+// WatchDatabase polls the changes recorded by WithChanges, it is shared by the backends keeping tokens in the database.
+// The revision is the ID of the change, the changes of all zones are kept in the same table.
+func WatchDatabase(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error) {
+	first, last, err := database.GetDatabase().WithContext(ctx).QueryDomainChangeRange()
+	if err != nil {
+		return nil, Unavailable(errors.Wrap(err, "failed to query the range of domain changes"))
+	}
+	if revision < 0 || revision > last {
+		return nil, Invalid(errors.Errorf("revision %d is out of range, the current revision is %d", revision, last))
+	}
+	// IDs may be skipped by the database, so the watch is only resumed if the next change is kept for sure
+	if revision > 0 && revision < first-1 {
+		return nil, Expired(errors.Errorf("changes after revision %d are purged, the oldest revision is %d", revision, first))
+	}
+
+	ch := make(chan model.WatchChange)
+	go func() {
+		defer close(ch)
+
+		if revision == 0 {
+			revision = last
+			if !SendChange(ctx, ch, model.WatchChange{Revision: last}) {
+				return
+			}
+		}
+
+		t := time.NewTicker(watchPollInterval)
+		defer t.Stop()
+		for {
+			// the polls are not traced, they last as long as the watch
+			cs, err := database.GetDatabase().ListDomainChanges(fqdn, revision, watchBatchSize)
+			if err != nil {
+				Logger(ctx).Errorf("failed to list domain changes after revision %d, err: %v", revision, err)
+				return
+			}
+			for _, c := range cs {
+				revision = c.ID
+				if !SendChange(ctx, ch, model.WatchChange{Revision: c.ID, Fqdn: c.Fqdn}) {
+					return
+				}
+			}
+			// a full batch means more changes may be kept, so the next batch is listed at once
+			if len(cs) == watchBatchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// SendChange sends the change to the watch, false is returned if the watch is done before the change is received.
+func SendChange(ctx context.Context, ch chan<- model.WatchChange, c model.WatchChange) bool {
+	select {
+	case ch <- c:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Used to record a change of the domain holding the fqdn, errors are logged since the records are changed anyway
+func recordChange(ctx context.Context, fqdn string) {
+	z, err := MatchZone(fqdn)
+	if err != nil {
+		Logger(ctx).Warnf("failed to record change of %s, err: %v", fqdn, err)
+		return
+	}
+
+	if _, err := database.GetDatabase().WithContext(ctx).InsertDomainChange(&model.DomainChange{
+		Fqdn:      z.Domain(fqdn),
+		CreatedOn: time.Now().UnixNano(),
+	}); err != nil {
+		Logger(ctx).Errorf("failed to record change of %s, err: %v", fqdn, err)
+	}
+}
@@ -0,0 +1,78 @@
+package backend
+
+// ErrorKind classifies backend errors, the API uses it to respond with a proper status code.
+type ErrorKind int
+
+const (
+	KindUnknown ErrorKind = iota
+	KindNotFound
+	KindConflict
+	KindInvalid
+	KindExpired
+	KindUnavailable
+)
+
+// Error wraps a backend error with its kind, the message of the wrapped error is kept.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// NotFound is used when the domain or record does not exist.
+func NotFound(err error) error {
+	return newError(KindNotFound, err)
+}
+
+// Conflict is used when the domain or record already exists.
+func Conflict(err error) error {
+	return newError(KindConflict, err)
+}
+
+// Invalid is used when the request is rejected by the backend.
+func Invalid(err error) error {
+	return newError(KindInvalid, err)
+}
+
+// Expired is used when the domain exists but its lease is expired.
+func Expired(err error) error {
+	return newError(KindExpired, err)
+}
+
+// Unavailable is used when the upstream (e.g. route53, etcd or database) fails to serve the request.
+func Unavailable(err error) error {
+	return newError(KindUnavailable, err)
+}
+
+// KindOf returns the kind of the outermost typed error, errors wrapped by github.com/pkg/errors are unwrapped.
+func KindOf(err error) ErrorKind {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e.Kind
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = c.Cause()
+	}
+	return KindUnknown
+}
+
+// Used to wrap error with kind, nil error is kept as nil
+func newError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{
+		Kind: kind,
+		Err:  err,
+	}
+}
@@ -3,8 +3,11 @@ package etcdv3
 const (
 	errDeleteRecord           = "failed to delete %s record: %s"
 	errEmptyRecord            = "failed to found %s record: %s"
+	errExistRecord            = "failed to find a free key of %s record: %s"
 	errExistSlug              = "slug name %s can not be used, try another"
+	errGetLease               = "failed to get lease %d"
 	errGrantLease             = "failed to grant lease"
+	errSetRecord              = "failed to set %s record %s"
 	errSetRecordWithLease     = "failed to set %s record %s with lease %d"
 	errSyncRecords            = "failed to sync %s records: %s"
 	errSyncSubRecords         = "failed to sync sub %s records: %s"
@@ -14,4 +17,10 @@ const (
 	errMultiRecords           = "multiple %s records: %s"
 	errNoLookupResults        = "no lookup results for %s record: %s"
 	errNotValidDomainName     = "not valid domain name: %s"
+	errNoLeader               = "etcd endpoint %s has no leader"
+	errPing                   = "failed to get status of etcd endpoints %v"
+	errRevokeLease            = "failed to revoke lease %d"
+	errCompactedRevision      = "changes after revision %d are compacted"
+	errFutureRevision         = "revision %d is not reached"
+	errWatch                  = "failed to watch changes after revision %d"
 )
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/metric"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/slug"
+	"github.com/rancher/rdns-server/tracing"
 	"github.com/rancher/rdns-server/util"
 
 	"github.com/coreos/etcd/clientv3"
@@ -16,19 +22,22 @@ import (
 	"github.com/coreos/etcd/mvcc/mvccpb"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 )
 
 const (
 	Name             = "etcdv3"
 	typeA            = "A"
 	typeTXT          = "TXT"
+	typeCNAME        = "CNAME"
 	typeToken        = "TOKEN"
+	typeSubToken     = "SUBTOKEN"
 	typeFrozen       = "FROZEN"
 	tokenPath        = "/tokenv3"
+	subTokenPath     = "/subtokenv3"
 	frozenPath       = "/frozenv3"
-	maxSlugHashTimes = 100
+	cnameKey         = "_cname"
 	tokenLength      = 32
-	slugLength       = 6
 	operationTimeout = 100 * time.Millisecond
 )
 
@@ -39,31 +48,39 @@ type Backend struct {
 	LeaseTime time.Duration
 
 	C *clientv3.Client
+
+	ctx context.Context
 }
 
-func NewBackend() (*Backend, error) {
+// NewClient creates the etcd client, it is shared by the backends of all zones.
+func NewClient() (*clientv3.Client, error) {
 	cfg := clientv3.Config{
 		Endpoints:   strings.Split(os.Getenv("ETCD_ENDPOINTS"), ","),
 		DialTimeout: 5 * time.Second,
+		DialOptions: []grpc.DialOption{grpc.WithUnaryInterceptor(observeRequest)},
 	}
-	c, err := clientv3.New(cfg)
-	if err != nil {
-		return nil, err
-	}
-	leaseTime, err := time.ParseDuration(os.Getenv("ETCD_LEASE_TIME"))
-	if err != nil {
-		return nil, err
-	}
+	return clientv3.New(cfg)
+}
+
+// Used to observe and trace the etcd requests, the operation is the gRPC method such as KV/Range
+func observeRequest(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := tracing.UnaryClientInterceptor(ctx, method, req, reply, cc, invoker, opts...)
+	metric.ObserveBackendRequest(Name, strings.TrimPrefix(method, "/etcdserverpb."), time.Since(start), err != nil)
+	return err
+}
+
+func NewBackend(c *clientv3.Client, opts *backend.ZoneOptions) (*Backend, error) {
 	frozen, err := time.ParseDuration(os.Getenv("FROZEN"))
 	if err != nil {
 		return nil, err
 	}
 
 	return &Backend{
-		Domain:    os.Getenv("DOMAIN"),
+		Domain:    strings.ToLower(strings.TrimRight(opts.Name, ".")),
 		Prefix:    os.Getenv("ETCD_PREFIX_PATH"),
 		FrozenTTL: frozen,
-		LeaseTime: leaseTime,
+		LeaseTime: opts.LeaseTime,
 		C:         c,
 	}, nil
 }
@@ -72,12 +89,43 @@ func (b *Backend) GetName() string {
 	return Name
 }
 
-func (b *Backend) GetZone() string {
-	return b.Domain
+// WithContext returns a copy of the backend which logs the request ID of the context and traces its etcd calls under it.
+func (b *Backend) WithContext(ctx context.Context) backend.Backend {
+	c := *b
+	c.ctx = ctx
+	return &c
+}
+
+// Ping reports whether any etcd endpoint is serving with a leader, the etcd client is shared by the zones.
+func (b *Backend) Ping(ctx context.Context) error {
+	var err error
+	for _, ep := range b.C.Endpoints() {
+		var s *clientv3.StatusResponse
+		if s, err = b.C.Status(ctx, ep); err == nil && s.Leader == 0 {
+			err = errors.Errorf(errNoLeader, ep)
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return backend.Unavailable(errors.Wrapf(err, errPing, b.C.Endpoints()))
+}
+
+// Used to get the logger of the request bound to the backend
+func (b *Backend) log() *logrus.Entry {
+	return backend.Logger(b.ctx)
+}
+
+// Used to get the parent context of the etcd calls, the calls are traced under the request but not canceled with it
+func (b *Backend) callContext() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return context.WithoutCancel(b.ctx)
 }
 
 func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("get %s record for domain options: %s", typeA, opts.String())
+	b.log().Debugf("get %s record for domain options: %s", typeA, opts.String())
 
 	path := getPath(b.Prefix, opts.Fqdn)
 
@@ -87,7 +135,7 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 	}
 
 	if len(kvs) <= 0 {
-		return d, errors.Errorf(errNoLookupResults, typeA, path)
+		return d, backend.NotFound(errors.Errorf(errNoLookupResults, typeA, path))
 	}
 
 	subs := make(map[string][]string, 0)
@@ -154,26 +202,16 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 }
 
 func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("set %s record for domain options: %s", typeA, opts.String())
+	b.log().Debugf("set %s record for domain options: %s", typeA, opts.String())
 
-	var path, slug string
-	for i := 0; i < maxSlugHashTimes; i++ {
-		slug = generateSlug()
-
-		if b.checkSlugName(slug) {
-			logrus.Debugf(errExistSlug, slug)
-			continue
-		}
-
-		fqdn := fmt.Sprintf("%s.%s", slug, b.Domain)
-		path = getPath(b.Prefix, fqdn)
-
-		if !b.checkPathExist(path) {
-			opts.Fqdn = fqdn
-			break
-		}
+	slug, err := b.allocateSlug(opts.Name)
+	if err != nil {
+		return d, err
 	}
 
+	opts.Fqdn = fmt.Sprintf("%s.%s", slug, b.Domain)
+	path := getPath(b.Prefix, opts.Fqdn)
+
 	d, err = b.setRecord(path, opts, false)
 	if err != nil {
 		return d, err
@@ -187,7 +225,7 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 }
 
 func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("update %s record for domain options: %s", typeA, opts.String())
+	b.log().Debugf("update %s record for domain options: %s", typeA, opts.String())
 
 	path := getPath(b.Prefix, opts.Fqdn)
 
@@ -197,7 +235,7 @@ func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error)
 	}
 
 	if len(kvs) <= 0 {
-		return d, errors.Errorf(errNoLookupResults, typeA, path)
+		return d, backend.NotFound(errors.Errorf(errNoLookupResults, typeA, path))
 	}
 
 	if _, err = b.setRecord(path, opts, true); err != nil {
@@ -213,7 +251,7 @@ func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error)
 }
 
 func (b *Backend) Delete(opts *model.DomainOptions) error {
-	logrus.Debugf("delete %s record for domain options: %s", typeA, opts.String())
+	b.log().Debugf("delete %s record for domain options: %s", typeA, opts.String())
 
 	d, err := b.Get(opts)
 	if err != nil {
@@ -233,29 +271,29 @@ func (b *Backend) Delete(opts *model.DomainOptions) error {
 		path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
 
 		if prefix != "" && strings.Contains(prefix, "_") {
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+			ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 			_, err := b.C.Delete(ctx, path)
 			cancel()
 			if err != nil {
-				return errors.Wrapf(err, errDeleteRecord, typeA, path)
+				return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeA, path))
 			}
 		}
 
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Delete(ctx, path); err != nil {
-		return errors.Wrapf(err, errDeleteRecord, typeA, path)
+		return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeA, path))
 	}
 	for prefix := range d.SubDomain {
 		path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
 
-		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 		_, err := b.C.Delete(ctx, path, clientv3.WithPrefix())
 		cancel()
 		if err != nil {
-			return errors.Wrapf(err, errDeleteRecord, typeA, path)
+			return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeA, path))
 		}
 	}
 
@@ -263,7 +301,7 @@ func (b *Backend) Delete(opts *model.DomainOptions) error {
 }
 
 func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("renew %s record for domain options: %s", typeA, opts.String())
+	b.log().Debugf("renew %s record for domain options: %s", typeA, opts.String())
 
 	path := getPath(b.Prefix, opts.Fqdn)
 
@@ -283,7 +321,7 @@ func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
 	}
 
 	if len(kvs) <= 0 {
-		return d, errors.Errorf(errNoLookupResults, typeA, path)
+		return d, backend.NotFound(errors.Errorf(errNoLookupResults, typeA, path))
 	}
 
 	subs := make(map[string][]string, 0)
@@ -340,27 +378,116 @@ func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
 	return d, nil
 }
 
-func (b *Backend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("set %s record for domain options: %s", typeCNAME, opts.String())
+
+	slug, err := b.allocateSlug(opts.Name)
+	if err != nil {
+		return d, err
+	}
+
+	opts.Fqdn = fmt.Sprintf("%s.%s", slug, b.Domain)
+
+	leaseID, _, err := b.setToken(opts, false)
+	if err != nil {
+		return d, err
+	}
+
+	if err := b.setCNAMERecord(opts, leaseID); err != nil {
+		return d, err
+	}
+
+	if err := b.lockSlugName(opts.Fqdn, slug, false); err != nil {
+		return d, err
+	}
+
+	return b.GetCNAME(opts)
 }
 
-func (b *Backend) GetCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("get %s record for domain options: %s", typeCNAME, opts.String())
+
+	path := getPath(b.Prefix, opts.Fqdn)
+
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path)
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errEmptyRecord, typeCNAME, path))
+	}
+
+	if resp.Count <= 0 {
+		return d, backend.NotFound(errors.Errorf(errEmptyRecord, typeCNAME, path))
+	}
+
+	m, err := unmarshalToMap(resp.Kvs[0].Value)
+	if err != nil {
+		return d, err
+	}
+
+	// A records hold an empty host or an IP address on the domain path, only a name is a CNAME target
+	if m["host"] == "" || net.ParseIP(m["host"]) != nil {
+		return d, backend.NotFound(errors.Errorf(errEmptyRecord, typeCNAME, path))
+	}
+
+	lease, err := b.getLease(resp.Kvs[0].Lease)
+	if err != nil {
+		return d, err
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.CNAME = m["host"]
+	d.Expiration = getExpiration(lease.TTL)
+
+	return d, nil
 }
 
-func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
-	return model.Domain{}, nil
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("update %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return d, err
+	}
+
+	leaseID, _, err := b.setToken(opts, true)
+	if err != nil {
+		return d, err
+	}
+
+	if err := b.setCNAMERecord(opts, leaseID); err != nil {
+		return d, err
+	}
+
+	return b.GetCNAME(opts)
 }
 
 func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	b.log().Debugf("delete %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.GetCNAME(opts); err != nil {
+		return err
+	}
+
+	path := getPath(b.Prefix, opts.Fqdn)
+
+	for _, key := range []string{fmt.Sprintf("%s/%s", path, cnameKey), path} {
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+		_, err := b.C.Delete(ctx, key)
+		cancel()
+		if err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeCNAME, key))
+		}
+	}
+
 	return nil
 }
 
 func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("set %s record for domain options: %s", typeTXT, opts.String())
+	b.log().Debugf("set %s record for domain options: %s", typeTXT, opts.String())
 
 	if len(strings.Split(opts.Fqdn, "."))-len(strings.Split(b.Domain, ".")) <= 1 {
-		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
+		return d, backend.Invalid(errors.Errorf(errNotValidDomainName, opts.Fqdn))
 	}
 
 	path := getPath(b.Prefix, opts.Fqdn)
@@ -372,35 +499,35 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 		return d, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
-		return d, errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID)
+		return d, backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID))
 	}
 
 	return b.GetText(opts)
 }
 
 func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("get %s record for domain options: %s", typeTXT, opts.String())
+	b.log().Debugf("get %s record for domain options: %s", typeTXT, opts.String())
 
 	if len(strings.Split(opts.Fqdn, "."))-len(strings.Split(b.Domain, ".")) <= 1 {
-		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
+		return d, backend.Invalid(errors.Errorf(errNotValidDomainName, opts.Fqdn))
 	}
 
 	path := getPath(b.Prefix, opts.Fqdn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	resp, err := b.C.Get(ctx, path)
 	if err != nil {
-		return d, errors.Wrapf(err, errEmptyRecord, typeTXT, path)
+		return d, backend.Unavailable(errors.Wrapf(err, errEmptyRecord, typeTXT, path))
 	}
 
 	if resp.Count <= 0 {
-		return d, errors.Errorf(errEmptyRecord, typeTXT, path)
+		return d, backend.NotFound(errors.Errorf(errEmptyRecord, typeTXT, path))
 	}
 
 	lease, err := b.getLease(resp.Kvs[0].Lease)
@@ -424,10 +551,10 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 }
 
 func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("update %s record for domain options: %s", typeTXT, opts.String())
+	b.log().Debugf("update %s record for domain options: %s", typeTXT, opts.String())
 
 	if len(strings.Split(opts.Fqdn, "."))-len(strings.Split(b.Domain, ".")) <= 1 {
-		return d, errors.Errorf(errNotValidDomainName, opts.Fqdn)
+		return d, backend.Invalid(errors.Errorf(errNotValidDomainName, opts.Fqdn))
 	}
 
 	if _, err := b.GetText(opts); err != nil {
@@ -443,59 +570,140 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 		return d, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, formatTextValue(opts.Text), clientv3.WithLease(clientv3.LeaseID(leaseID)), clientv3.WithPrevKV()); err != nil {
-		return d, errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID)
+		return d, backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeTXT, path, leaseID))
 	}
 
 	return b.GetText(opts)
 }
 
 func (b *Backend) DeleteText(opts *model.DomainOptions) error {
-	logrus.Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
+	b.log().Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
 
 	path := getPath(b.Prefix, opts.Fqdn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
-	if _, err := b.C.Delete(ctx, path); err != nil {
-		return errors.Wrapf(err, errDeleteRecord, typeTXT, path)
+	resp, err := b.C.Delete(ctx, path)
+	if err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeTXT, path))
+	}
+
+	if resp.Deleted <= 0 {
+		return backend.NotFound(errors.Errorf(errEmptyRecord, typeTXT, path))
 	}
 
 	return nil
 }
 
 func (b *Backend) GetToken(fqdn string) (string, error) {
-	logrus.Debugf("get %s record for fqdn: %s", typeToken, fqdn)
+	b.log().Debugf("get %s record for fqdn: %s", typeToken, fqdn)
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	kv, err := b.getTokenKey(fqdn)
+	if err != nil {
+		return "", err
+	}
+
+	return string(kv.Value), nil
+}
+
+func (b *Backend) UpdateToken(fqdn, token string) error {
+	b.log().Debugf("update %s record for fqdn: %s", typeToken, fqdn)
+
+	kv, err := b.getTokenKey(fqdn)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	path := getTokenPath(fqdn)
+	if _, err := b.C.Put(ctx, path, token, clientv3.WithLease(clientv3.LeaseID(kv.Lease))); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeToken, path, kv.Lease))
+	}
 
-	resp, err := b.C.Get(ctx, path)
+	return nil
+}
+
+func (b *Backend) SetSubToken(fqdn string, st *model.SubToken) error {
+	b.log().Debugf("set %s record %s for fqdn: %s", typeSubToken, st.SID, fqdn)
+
+	// sub tokens share the lease of the token, so they are renewed and expired with the domain
+	kv, err := b.getTokenKey(fqdn)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	if resp.Count <= 0 {
-		return "", errors.Errorf(errEmptyRecord, typeToken, path)
+	value, err := json.Marshal(st)
+	if err != nil {
+		return err
 	}
 
-	if resp.Count > 1 {
-		return "", errors.Errorf(errMultiRecords, typeToken, path)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	path := getSubTokenPath(fqdn, st.SID)
+	if _, err := b.C.Put(ctx, path, string(value), clientv3.WithLease(clientv3.LeaseID(kv.Lease))); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeSubToken, path, kv.Lease))
 	}
 
-	return string(resp.Kvs[0].Value), nil
+	return nil
+}
+
+func (b *Backend) GetSubTokens(fqdn string) ([]*model.SubToken, error) {
+	if _, err := b.getTokenKey(fqdn); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	path := getSubTokenPath(fqdn, "")
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, backend.Unavailable(errors.Wrapf(err, errLookupRecords, typeSubToken, path))
+	}
+
+	ts := make([]*model.SubToken, 0)
+	for _, v := range resp.Kvs {
+		t := &model.SubToken{}
+		if err := json.Unmarshal(v.Value, t); err != nil {
+			return nil, err
+		}
+		ts = append(ts, t)
+	}
+
+	return ts, nil
+}
+
+func (b *Backend) DeleteSubToken(fqdn, sid string) error {
+	b.log().Debugf("delete %s record %s for fqdn: %s", typeSubToken, sid, fqdn)
+
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	path := getSubTokenPath(fqdn, sid)
+	resp, err := b.C.Delete(ctx, path)
+	if err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeSubToken, path))
+	}
+
+	if resp.Deleted <= 0 {
+		return backend.NotFound(errors.Errorf(errEmptyRecord, typeSubToken, path))
+	}
+
+	return nil
 }
 
 func (b *Backend) GetTokenCount() (int64, error) {
-	logrus.Debugf("get %s record count", typeToken)
+	b.log().Debugf("get %s record count", typeToken)
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	resp, err := b.C.Get(ctx, tokenPath, clientv3.WithPrefix())
@@ -518,7 +726,7 @@ func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, "", clientv3.WithLease(clientv3.LeaseID(id))); err != nil {
@@ -536,7 +744,7 @@ func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, opts.Token, clientv3.WithLease(clientv3.LeaseID(id))); err != nil {
@@ -571,7 +779,7 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 		}
 
 		// make sure domain record is exist, although no hosts value
-		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 		defer cancel()
 
 		_, err = b.C.Put(ctx, path, formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
@@ -642,6 +850,204 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 	return nil
 }
 
+func (b *Backend) List(opts *model.ListOptions) (l model.DomainList, err error) {
+	l.Items = make([]model.DomainInfo, 0)
+
+	// tokens of all zones are kept in the same path, the marker is the fqdn of the last domain
+	start := tokenPath + "/"
+	end := clientv3.GetPrefixRangeEnd(start)
+	if opts.Marker != "" {
+		start = getTokenPath(opts.Marker) + "\x00"
+	}
+	suffix := "_" + formatKey(b.Domain)
+
+	for {
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+		resp, err := b.C.Get(ctx, start, clientv3.WithRange(end), clientv3.WithLimit(int64(opts.Limit)))
+		cancel()
+		if err != nil {
+			return l, backend.Unavailable(errors.Wrapf(err, errLookupRecords, typeToken, start))
+		}
+
+		for _, kv := range resp.Kvs {
+			key := string(kv.Key)
+			start = key + "\x00"
+			if !strings.HasSuffix(key, suffix) {
+				continue
+			}
+
+			// the domains of a nested zone are listed with that zone
+			fqdn := convertTokenPath(key)
+			if z, err := backend.MatchZone(fqdn); err != nil || z.Name != b.Domain {
+				continue
+			}
+
+			d, err := b.inspectToken(fqdn, kv)
+			if err != nil {
+				return l, err
+			}
+			if !opts.Match(&d) {
+				continue
+			}
+
+			l.Items = append(l.Items, d)
+			if len(l.Items) >= opts.Limit {
+				l.Next = fqdn
+				return l, nil
+			}
+		}
+
+		if !resp.More {
+			return l, nil
+		}
+	}
+}
+
+func (b *Backend) Inspect(fqdn string) (d model.DomainInfo, err error) {
+	kv, err := b.getTokenKey(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = b.inspectToken(fqdn, kv)
+	if err != nil {
+		return d, err
+	}
+
+	// TXT records are the children of the domain path, e.g. /rdnsv3/cloud/rancher/lb/sample/_acme-challenge
+	path := getPath(b.Prefix, fqdn) + "/"
+
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errLookupRecords, typeTXT, path))
+	}
+
+	for _, v := range resp.Kvs {
+		m, err := unmarshalToMap(v.Value)
+		if err != nil {
+			continue
+		}
+		if _, ok := m["text"]; !ok {
+			continue
+		}
+		if d.Texts == nil {
+			d.Texts = make(map[string]string)
+		}
+		d.Texts[convertToFqdn(strings.TrimPrefix(string(v.Key), b.Prefix))] = m["text"]
+	}
+
+	return d, nil
+}
+
+func (b *Backend) ForceDelete(fqdn string) error {
+	b.log().Debugf("force delete records for fqdn: %s", fqdn)
+
+	kv, err := b.getTokenKey(fqdn)
+	if err != nil {
+		return err
+	}
+
+	// records share the lease of the token, revoking the lease deletes them all
+	if kv.Lease != 0 {
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+		_, err := b.C.Revoke(ctx, clientv3.LeaseID(kv.Lease))
+		cancel()
+		if err != nil && err != rpctypes.ErrLeaseNotFound {
+			return backend.Unavailable(errors.Wrapf(err, errRevokeLease, kv.Lease))
+		}
+	}
+
+	// records which are not bound to the lease, e.g. migrated ones
+	path := getPath(b.Prefix, fqdn)
+	ops := []clientv3.Op{
+		clientv3.OpDelete(path),
+		clientv3.OpDelete(path+"/", clientv3.WithPrefix()),
+		clientv3.OpDelete(getTokenPath(fqdn)),
+		clientv3.OpDelete(getSubTokenPath(fqdn, ""), clientv3.WithPrefix()),
+	}
+	for _, op := range ops {
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+		_, err := b.C.Do(ctx, op)
+		cancel()
+		if err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errDeleteRecord, typeA, path))
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) ForceRenew(fqdn string) (d model.DomainInfo, err error) {
+	b.log().Debugf("force renew records for fqdn: %s", fqdn)
+
+	kv, err := b.getTokenKey(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	if _, _, err := b.keepaliveOnce(kv.Lease); err != nil {
+		return d, err
+	}
+
+	return b.Inspect(fqdn)
+}
+
+// Watch watches the records and the tokens of all zones, so the renewals which only put the token are sent as well.
+// The revision is the etcd revision, a domain changed by several events of a response is sent once by the last revision.
+func (b *Backend) Watch(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error) {
+	start, end := b.watchRange()
+
+	// the revision is read before watching, so a compacted or future revision is rejected before the stream starts
+	opts := []clientv3.OpOption{clientv3.WithRange(end), clientv3.WithCountOnly()}
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+	getCtx, cancel := context.WithTimeout(ctx, operationTimeout)
+	resp, err := b.C.Get(getCtx, start, opts...)
+	cancel()
+	switch {
+	case err == rpctypes.ErrCompacted:
+		return nil, backend.Expired(errors.Errorf(errCompactedRevision, revision))
+	case err == rpctypes.ErrFutureRev:
+		return nil, backend.Invalid(errors.Errorf(errFutureRevision, revision))
+	case err != nil:
+		return nil, backend.Unavailable(errors.Wrapf(err, errWatch, revision))
+	}
+
+	bookmark := revision == 0
+	if bookmark {
+		revision = resp.Header.Revision
+	}
+
+	ctx, cancel = context.WithCancel(ctx)
+	wch := b.C.Watch(clientv3.WithRequireLeader(ctx), start, clientv3.WithRange(end), clientv3.WithRev(revision+1))
+	ch := make(chan model.WatchChange)
+	go func() {
+		defer close(ch)
+		defer cancel()
+
+		if bookmark && !backend.SendChange(ctx, ch, model.WatchChange{Revision: revision}) {
+			return
+		}
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				backend.Logger(ctx).Errorf(errWatch+", err: %v", revision, err)
+				return
+			}
+			for _, c := range b.changedDomains(fqdn, resp.Events) {
+				revision = c.Revision
+				if !backend.SendChange(ctx, ch, c) {
+					return
+				}
+			}
+		}
+	}()
+	return ch, nil
+}
+
 func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool) (d model.Domain, err error) {
 	leaseID, leaseTTL, err := b.setToken(opts, exist)
 	if err != nil {
@@ -650,12 +1056,12 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 
 	if !exist {
 		// make sure domain record is exist, although no hosts value
-		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 		defer cancel()
 
 		_, err := b.C.Put(ctx, path, formatValue(""), clientv3.WithLease(clientv3.LeaseID(leaseID)))
 		if err != nil {
-			return d, err
+			return d, backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeA, path, leaseID))
 		}
 	}
 
@@ -711,11 +1117,11 @@ func (b *Backend) setRecord(path string, opts *model.DomainOptions, exist bool)
 	}
 
 	if err := b.syncRecords(opts.Hosts, hosts, path, clientv3.LeaseID(leaseID)); err != nil {
-		return d, errors.Wrapf(err, errSyncRecords, typeA, path)
+		return d, backend.Unavailable(errors.Wrapf(err, errSyncRecords, typeA, path))
 	}
 
 	if err := b.setSubRecords(opts, subs, leaseID); err != nil {
-		return d, errors.Wrapf(err, errSetSubRecordsWithLease, typeA, opts.Fqdn, leaseID)
+		return d, backend.Unavailable(errors.Wrapf(err, errSetSubRecordsWithLease, typeA, opts.Fqdn, leaseID))
 	}
 
 	d.Fqdn = opts.Fqdn
@@ -730,7 +1136,7 @@ func (b *Backend) setSubRecords(opts *model.DomainOptions, origins map[string][]
 	for prefix := range origins {
 		if _, ok := opts.SubDomain[prefix]; !ok {
 			path := getPath(b.Prefix, fmt.Sprintf("%s.%s", prefix, opts.Fqdn))
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+			ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 			_, err := b.C.Delete(ctx, path, clientv3.WithPrefix())
 			cancel()
 			if err != nil {
@@ -771,7 +1177,7 @@ func (b *Backend) syncRecords(new, old []string, path string, leaseID clientv3.L
 	for r := range right {
 		if _, ok := left[r]; !ok {
 			key := fmt.Sprintf("%s/%s", path, formatKey(r))
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+			ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 			_, err := b.C.Delete(ctx, key)
 			cancel()
 			if err != nil {
@@ -783,7 +1189,7 @@ func (b *Backend) syncRecords(new, old []string, path string, leaseID clientv3.L
 	for l := range left {
 		if _, ok := right[l]; !ok {
 			key := fmt.Sprintf("%s/%s", path, formatKey(l))
-			ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+			ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 			_, err := b.C.Put(ctx, key, formatValue(l), clientv3.WithLease(leaseID))
 			cancel()
 			if err != nil {
@@ -796,7 +1202,7 @@ func (b *Backend) syncRecords(new, old []string, path string, leaseID clientv3.L
 }
 
 func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64, error) {
-	logrus.Debugf("set %s for fqdn: %s", typeToken, opts.String())
+	b.log().Debugf("set %s for fqdn: %s", typeToken, opts.String())
 
 	var token string
 	var leaseID, leaseTTL int64
@@ -804,16 +1210,16 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 	path := getTokenPath(opts.Fqdn)
 
 	if exist {
-		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 		defer cancel()
 
 		resp, err := b.C.Get(ctx, path)
 		if err != nil {
-			return 0, -1, errors.Wrapf(err, errEmptyRecord, typeToken, path)
+			return 0, -1, backend.Unavailable(errors.Wrapf(err, errEmptyRecord, typeToken, path))
 		}
 
 		if resp.Count <= 0 {
-			return 0, -1, errors.Errorf(errEmptyRecord, typeToken, path)
+			return 0, -1, backend.NotFound(errors.Errorf(errEmptyRecord, typeToken, path))
 		}
 
 		token = string(resp.Kvs[0].Value)
@@ -826,7 +1232,8 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 		leaseID = int64(lease.ID)
 		leaseTTL = lease.TTL
 	} else {
-		token = util.RandStringWithAll(tokenLength)
+		// the token is replaced when it is responded to the client
+		token = util.HashToken(util.RandStringWithAll(tokenLength))
 
 		id, ttl, err := b.grantLease(int64(b.LeaseTime.Seconds()))
 		if err != nil {
@@ -837,18 +1244,57 @@ func (b *Backend) setToken(opts *model.DomainOptions, exist bool) (int64, int64,
 		leaseTTL = ttl
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, token, clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
-		return 0, -1, errors.Wrapf(err, errSetRecordWithLease, typeToken, path, leaseID)
+		return 0, -1, backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeToken, path, leaseID))
 	}
 
 	return leaseID, leaseTTL, nil
 }
 
+// Used to set CNAME records with lease, the domain path answers CNAME queries
+// and the child path answers A & wildcard queries which only lookup children
+// e.g. sample.lb.rancher.cloud => /rdnsv3/cloud/rancher/lb/sample & /rdnsv3/cloud/rancher/lb/sample/_cname
+func (b *Backend) setCNAMERecord(opts *model.DomainOptions, leaseID int64) error {
+	path := getPath(b.Prefix, opts.Fqdn)
+
+	for _, key := range []string{path, fmt.Sprintf("%s/%s", path, cnameKey)} {
+		ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+		_, err := b.C.Put(ctx, key, formatValue(opts.CNAME), clientv3.WithLease(clientv3.LeaseID(leaseID)))
+		cancel()
+		if err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeCNAME, key, leaseID))
+		}
+	}
+
+	return nil
+}
+
+// Used to allocate a slug which is neither frozen nor used by any record
+func (b *Backend) allocateSlug(name string) (string, error) {
+	// the requested name is used as it is, a random slug is generated if no name is requested
+	if name != "" {
+		fqdn := fmt.Sprintf("%s.%s", name, b.Domain)
+		if b.checkSlugName(name) || b.checkPathExist(getPath(b.Prefix, fqdn)) || b.checkPathExist(getTokenPath(fqdn)) {
+			return "", backend.Conflict(errors.Errorf(errExistSlug, name))
+		}
+		return name, nil
+	}
+
+	s, err := slug.Allocate(func(name string) (bool, error) {
+		if b.checkSlugName(name) {
+			return false, nil
+		}
+		return !b.checkPathExist(getPath(b.Prefix, fmt.Sprintf("%s.%s", name, b.Domain))), nil
+	})
+
+	return s, backend.Unavailable(err)
+}
+
 func (b *Backend) lockSlugName(fqdn, slug string, exist bool) error {
-	logrus.Debugf("lock slug name: %s", fqdn)
+	b.log().Debugf("lock slug name: %s", fqdn)
 
 	path := fmt.Sprintf("%s%s/%s", b.Prefix, frozenPath, slug)
 
@@ -860,7 +1306,7 @@ func (b *Backend) lockSlugName(fqdn, slug string, exist bool) error {
 		}
 
 		if len(kvs) <= 0 {
-			return errors.Errorf(errEmptyRecord, typeA, path)
+			return backend.NotFound(errors.Errorf(errEmptyRecord, typeA, path))
 		}
 
 		leaseID = kvs[0].Lease
@@ -873,23 +1319,142 @@ func (b *Backend) lockSlugName(fqdn, slug string, exist bool) error {
 		leaseID = id
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	if _, err := b.C.Put(ctx, path, "", clientv3.WithLease(clientv3.LeaseID(leaseID))); err != nil {
-		return errors.Wrapf(err, errSetRecordWithLease, typeFrozen, path, leaseID)
+		return backend.Unavailable(errors.Wrapf(err, errSetRecordWithLease, typeFrozen, path, leaseID))
 	}
 
 	return nil
 }
 
+// Used to get the token key of the fqdn, the lease of the token is shared by the records of the domain
+func (b *Backend) getTokenKey(fqdn string) (*mvccpb.KeyValue, error) {
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
+	defer cancel()
+
+	path := getTokenPath(fqdn)
+
+	resp, err := b.C.Get(ctx, path)
+	if err != nil {
+		return nil, backend.Unavailable(errors.Wrapf(err, errLookupRecords, typeToken, path))
+	}
+
+	if resp.Count <= 0 {
+		return nil, backend.NotFound(errors.Errorf(errEmptyRecord, typeToken, path))
+	}
+
+	if resp.Count > 1 {
+		return nil, errors.Errorf(errMultiRecords, typeToken, path)
+	}
+
+	return resp.Kvs[0], nil
+}
+
+// Used to convert the token key to the domain, the lease start is calculated from the remaining TTL of the token lease
+func (b *Backend) inspectToken(fqdn string, kv *mvccpb.KeyValue) (d model.DomainInfo, err error) {
+	d.Fqdn = fqdn
+
+	if kv.Lease != 0 {
+		lease, err := b.getLease(kv.Lease)
+		if err != nil {
+			return d, err
+		}
+		granted := time.Duration(lease.GrantedTTL) * time.Second
+		d.Token = model.NewTokenInfo(time.Now().Add(time.Duration(lease.TTL)*time.Second-granted), granted)
+	}
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+
+	// the CNAME target is kept in the domain path, so it must be checked before A records
+	c, err := b.GetCNAME(opts)
+	if err == nil {
+		d.Type = typeCNAME
+		d.CNAME = c.CNAME
+		return d, nil
+	}
+	if backend.KindOf(err) != backend.KindNotFound {
+		return d, err
+	}
+
+	a, err := b.Get(opts)
+	if err == nil {
+		d.Type = typeA
+		d.Hosts = a.Hosts
+		d.SubDomain = a.SubDomain
+		return d, nil
+	}
+	if backend.KindOf(err) != backend.KindNotFound {
+		return d, err
+	}
+
+	return d, nil
+}
+
+// Used to get the key range holding the records and the tokens, the frozen slugs and sub tokens in the range are skipped
+func (b *Backend) watchRange() (string, string) {
+	start, end := b.Prefix+"/", clientv3.GetPrefixRangeEnd(b.Prefix+"/")
+	if s := tokenPath + "/"; s < start {
+		start = s
+	}
+	if e := clientv3.GetPrefixRangeEnd(tokenPath + "/"); e > end {
+		end = e
+	}
+	return start, end
+}
+
+// Used to find the domains changed by the events of the watch, the domains are ordered by their last revision
+func (b *Backend) changedDomains(fqdn string, events []*clientv3.Event) []model.WatchChange {
+	cs := make([]model.WatchChange, 0)
+	index := make(map[string]int)
+	for _, e := range events {
+		name := b.changedDomain(string(e.Kv.Key))
+		if name == "" || (fqdn != "" && name != fqdn) {
+			continue
+		}
+		if i, ok := index[name]; ok {
+			cs[i].Revision = e.Kv.ModRevision
+			continue
+		}
+		index[name] = len(cs)
+		cs = append(cs, model.WatchChange{Revision: e.Kv.ModRevision, Fqdn: name})
+	}
+
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Revision < cs[j].Revision
+	})
+	return cs
+}
+
+// Used to find the domain changed by the key, empty is returned if the key is neither a record nor a token of a domain
+// e.g. /rdnsv3/cloud/rancher/lb/sample/x1/1_1_1_1 => sample.lb.rancher.cloud
+// e.g. /tokenv3/sample_lb_rancher_cloud => sample.lb.rancher.cloud
+func (b *Backend) changedDomain(key string) string {
+	var name string
+	switch {
+	case strings.HasPrefix(key, tokenPath+"/"):
+		name = convertTokenPath(key)
+	case strings.HasPrefix(key, b.Prefix+"/"):
+		name = convertToFqdn(strings.TrimPrefix(key, b.Prefix))
+	default:
+		return ""
+	}
+
+	z, err := backend.MatchZone(name)
+	if err != nil || z.Name == name {
+		return ""
+	}
+	return z.Domain(name)
+}
+
 func (b *Backend) lookupKeys(path string) ([]*mvccpb.KeyValue, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	resp, err := b.C.Get(ctx, path, clientv3.WithPrefix())
 	if err != nil {
-		return nil, errors.Wrapf(err, errLookupRecords, typeA, path)
+		return nil, backend.Unavailable(errors.Wrapf(err, errLookupRecords, typeA, path))
 	}
 
 	kvs := make([]*mvccpb.KeyValue, 0)
@@ -912,36 +1477,36 @@ func (b *Backend) lookupKeys(path string) ([]*mvccpb.KeyValue, error) {
 }
 
 func (b *Backend) getLease(id int64) (*clientv3.LeaseTimeToLiveResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	lease, err := b.C.TimeToLive(ctx, clientv3.LeaseID(id))
 	if err != nil {
-		return nil, err
+		return nil, backend.Unavailable(errors.Wrapf(err, errGetLease, id))
 	}
 
 	return lease, nil
 }
 
 func (b *Backend) grantLease(ttl int64) (int64, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	lease, err := b.C.Grant(ctx, ttl)
 	if err != nil {
-		return 0, -1, errors.Errorf(errGrantLease)
+		return 0, -1, backend.Unavailable(errors.Wrap(err, errGrantLease))
 	}
 
 	return int64(lease.ID), lease.TTL, nil
 }
 
 func (b *Backend) keepaliveOnce(id int64) (int64, int64, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	keepalive, err := b.C.KeepAliveOnce(ctx, clientv3.LeaseID(id))
 	if err != nil {
-		return 0, -1, errors.Errorf(errKeepaliveOnce, id)
+		return 0, -1, backend.Unavailable(errors.Wrapf(err, errKeepaliveOnce, id))
 	}
 
 	return int64(keepalive.ID), keepalive.TTL, nil
@@ -953,7 +1518,7 @@ func (b *Backend) keepaliveOnce(id int64) (int64, int64, error) {
 func (b *Backend) checkSlugName(slug string) bool {
 	path := fmt.Sprintf("%s%s/%s", b.Prefix, frozenPath, slug)
 
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	resp, err := b.C.Get(ctx, path)
@@ -966,7 +1531,7 @@ func (b *Backend) checkSlugName(slug string) bool {
 
 // Used to check whether path exist.
 func (b *Backend) checkPathExist(path string) bool {
-	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	ctx, cancel := context.WithTimeout(b.callContext(), operationTimeout)
 	defer cancel()
 
 	resp, err := b.C.Get(ctx, path)
@@ -994,21 +1559,46 @@ func convertToPath(domain string) string {
 	return "/" + strings.Join(ss, "/")
 }
 
+// Used to convert a path to domain
+// e.g. /cloud/rancher/lb/sample => sample.lb.rancher.cloud
+func convertToFqdn(path string) string {
+	ss := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	last := len(ss) - 1
+	for i := 0; i < len(ss)/2; i++ {
+		ss[i], ss[last-i] = ss[last-i], ss[i]
+	}
+	return strings.Join(ss, ".")
+}
+
 // Used to get a token path as etcd preferred
 // e.g. sample.lb.rancher.cloud => /tokenv3/sample_lb_rancher_cloud
 func getTokenPath(fqdn string) string {
 	return fmt.Sprintf("%s/%s", tokenPath, formatKey(fqdn))
 }
 
+// Used to convert a token path to domain, labels of the domain never contain underscores
+// e.g. /tokenv3/sample_lb_rancher_cloud => sample.lb.rancher.cloud
+func convertTokenPath(path string) string {
+	return strings.Replace(strings.TrimPrefix(path, tokenPath+"/"), "_", ".", -1)
+}
+
+// Used to get a sub token path as etcd preferred, the path of all sub tokens is returned if the sid is empty
+// e.g. sample.lb.rancher.cloud => /subtokenv3/sample_lb_rancher_cloud/<sid>
+func getSubTokenPath(fqdn, sid string) string {
+	return fmt.Sprintf("%s/%s/%s", subTokenPath, formatKey(fqdn), sid)
+}
+
 // Used to format a key as etcd preferred
 // e.g. 1.1.1.1 => 1_1_1_1
+// e.g. 2001:db8::1 => 2001_db8__1
 // e.g. sample.lb.rancher.cloud => sample_lb_rancher_cloud
 func formatKey(key string) string {
-	return strings.Replace(key, ".", "_", -1)
+	return strings.NewReplacer(".", "_", ":", "_").Replace(key)
 }
 
-// Used to format a A value as dns preferred
+// Used to format a A/AAAA value as dns preferred, coredns serves IPv6 host as AAAA record
 // e.g. 1.1.1.1 => {"host": "1.1.1.1"}
+// e.g. 2001:db8::1 => {"host": "2001:db8::1"}
 func formatValue(value string) string {
 	return fmt.Sprintf("{\"host\":\"%s\"}", value)
 }
@@ -1019,11 +1609,6 @@ func formatTextValue(value string) string {
 	return fmt.Sprintf("{\"text\":\"%s\"}", value)
 }
 
-// Used to generate a random slug
-func generateSlug() string {
-	return util.RandStringWithSmall(slugLength)
-}
-
 // Used to find slug name
 // e.g. yyyy.xxxx.qrn7oq.lb.rancher.cloud => qrn7oq
 func findSlugWithZone(fqdn, domain string) string {
@@ -0,0 +1,151 @@
+package etcdv3
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/pkg/errors"
+)
+
+const (
+	typeWebhook = "WEBHOOK"
+	webhookPath = "/webhookv3"
+
+	// IDs are random, so an insert is retried if the key is taken
+	maxInsertAttempts = 3
+)
+
+// WebhookQueue keeps the webhook deliveries in etcd, the deliveries of all zones are kept in the same path.
+// A delivery is claimed by comparing its stored value, so a delivery listed by several servers is posted by one of them.
+type WebhookQueue struct {
+	C *clientv3.Client
+}
+
+func NewWebhookQueue(c *clientv3.Client) *WebhookQueue {
+	return &WebhookQueue{C: c}
+}
+
+func (q *WebhookQueue) Insert(ctx context.Context, d *model.WebhookDelivery) (int64, error) {
+	for i := 0; i < maxInsertAttempts; i++ {
+		d.ID = rand.Int63()
+		value, err := json.Marshal(d)
+		if err != nil {
+			return 0, err
+		}
+
+		path := getWebhookPath(d.ID)
+		tctx, cancel := context.WithTimeout(ctx, operationTimeout)
+		resp, err := q.C.Txn(tctx).
+			If(clientv3.Compare(clientv3.CreateRevision(path), "=", 0)).
+			Then(clientv3.OpPut(path, string(value))).
+			Commit()
+		cancel()
+		if err != nil {
+			return 0, errors.Wrapf(err, errSetRecord, typeWebhook, path)
+		}
+		if resp.Succeeded {
+			return d.ID, nil
+		}
+	}
+	return 0, errors.Errorf(errExistRecord, typeWebhook, webhookPath)
+}
+
+func (q *WebhookQueue) ListDue(due *time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := q.C.Get(ctx, webhookPath+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, errors.Wrapf(err, errLookupRecords, typeWebhook, webhookPath)
+	}
+
+	result := make([]*model.WebhookDelivery, 0)
+	for _, kv := range resp.Kvs {
+		d := &model.WebhookDelivery{}
+		if err := json.Unmarshal(kv.Value, d); err != nil {
+			return nil, errors.Wrapf(err, errLookupRecords, typeWebhook, string(kv.Key))
+		}
+		if d.NextAttemptOn <= due.UnixNano() {
+			result = append(result, d)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NextAttemptOn < result[j].NextAttemptOn
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (q *WebhookQueue) Claim(d *model.WebhookDelivery, until int64) (bool, error) {
+	listed, err := json.Marshal(d)
+	if err != nil {
+		return false, err
+	}
+	claimed := *d
+	claimed.NextAttemptOn = until
+	value, err := json.Marshal(&claimed)
+	if err != nil {
+		return false, err
+	}
+
+	path := getWebhookPath(d.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	resp, err := q.C.Txn(ctx).
+		If(clientv3.Compare(clientv3.Value(path), "=", string(listed))).
+		Then(clientv3.OpPut(path, string(value))).
+		Commit()
+	if err != nil {
+		return false, errors.Wrapf(err, errSetRecord, typeWebhook, path)
+	}
+	if resp.Succeeded {
+		d.NextAttemptOn = until
+	}
+	return resp.Succeeded, nil
+}
+
+func (q *WebhookQueue) Update(d *model.WebhookDelivery) error {
+	value, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	path := getWebhookPath(d.ID)
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	// a delivery given up or deleted meanwhile is not put back
+	if _, err := q.C.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(path), ">", 0)).
+		Then(clientv3.OpPut(path, string(value))).
+		Commit(); err != nil {
+		return errors.Wrapf(err, errSetRecord, typeWebhook, path)
+	}
+	return nil
+}
+
+func (q *WebhookQueue) Delete(id int64) error {
+	path := getWebhookPath(id)
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if _, err := q.C.Delete(ctx, path); err != nil {
+		return errors.Wrapf(err, errDeleteRecord, typeWebhook, path)
+	}
+	return nil
+}
+
+func getWebhookPath(id int64) string {
+	return webhookPath + "/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,18 @@
+package memory
+
+const (
+	errDeleteSubTokenFromDatabase = "failed to delete sub token %s from database"
+	errExistName                  = "name %s already exist"
+	errExistRecord                = "%s record: %s already exist"
+	errExpiredToken               = "token of %s is expired"
+	errGenerateName               = "failed to generate valid record: %s"
+	errInsertFrozenToDatabase     = "failed to insert %s's frozen to database"
+	errInsertRecordToDatabase     = "failed to insert %s record: %s to database"
+	errInsertTokenToDatabase      = "failed to insert %s's token to database"
+	errNoRecord                   = "failed to found %s record: %s"
+	errNoSubToken                 = "failed to found sub token %s of %s"
+	errQueryTextFromDatabase      = "failed to query %s's TXT records from database"
+	errQueryTokenFromDatabase     = "failed to query %s's token record from database"
+	errRenewFromDatabase          = "failed to renew %s's records from database"
+	errUpdateTokenToDatabase      = "failed to update %s's token to database"
+)
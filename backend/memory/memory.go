@@ -0,0 +1,699 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/slug"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Name            = "memory"
+	typeA           = "A"
+	typeTXT         = "TXT"
+	typeCNAME       = "CNAME"
+	recordTypeTXT   = 0
+	recordTypeA     = 1
+	recordTypeSubA  = 2
+	recordTypeCNAME = 3
+	tokenLength     = 32
+)
+
+// Backend keeps the records in the current database only, no DNS server is serving them.
+// It is used for tests and local development together with the memory database.
+type Backend struct {
+	LeaseTime time.Duration
+	Zone      string
+
+	ctx context.Context
+}
+
+func NewBackend(opts *backend.ZoneOptions) (*Backend, error) {
+	return &Backend{
+		LeaseTime: opts.LeaseTime,
+		Zone:      strings.ToLower(strings.TrimRight(opts.Name, ".")),
+	}, nil
+}
+
+func (b *Backend) GetName() string {
+	return Name
+}
+
+// WithContext returns a copy of the backend which logs the request ID of the context and traces its database statements under it.
+func (b *Backend) WithContext(ctx context.Context) backend.Backend {
+	c := *b
+	c.ctx = ctx
+	return &c
+}
+
+// Ping always succeeds, the records are kept by the database which is checked on its own.
+func (b *Backend) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Used to get the logger of the request bound to the backend
+func (b *Backend) log() *logrus.Entry {
+	return backend.Logger(b.ctx)
+}
+
+// Used to get the database bound to the request, so its statements are traced under the request
+func (b *Backend) db() database.Database {
+	return database.GetDatabase().WithContext(b.ctx)
+}
+
+func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("get %s record for domain options: %s", typeA, opts.String())
+
+	token, err := b.queryToken(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	a, err := b.db().QueryA(opts.Fqdn)
+	if err != nil || a.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
+	}
+
+	subs, err := b.db().ListSubA(a.ID)
+	if err != nil {
+		return d, err
+	}
+
+	ss := make(map[string][]string, 0)
+	for _, sub := range subs {
+		ss[strings.Split(sub.Fqdn, ".")[0]] = splitContent(sub.Content)
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.Hosts = splitContent(a.Content)
+	d.SubDomain = ss
+	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), b.LeaseTime)
+
+	return d, nil
+}
+
+func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("set %s record for domain options: %s", typeA, opts.String())
+
+	tID, err := b.allocate(opts)
+	if err != nil {
+		return d, err
+	}
+
+	pID, err := b.db().InsertA(&model.RecordA{
+		Type:      recordTypeA,
+		Fqdn:      opts.Fqdn,
+		Content:   strings.Join(opts.Hosts, ","),
+		CreatedOn: time.Now().Unix(),
+		TID:       tID,
+	})
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, opts.Fqdn))
+	}
+
+	if err := b.setSubRecords(opts, pID); err != nil {
+		return d, err
+	}
+
+	return b.Get(opts)
+}
+
+func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("update %s record for domain options: %s", typeA, opts.String())
+
+	if _, err := b.queryToken(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	a, err := b.db().QueryA(opts.Fqdn)
+	if err != nil || a.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
+	}
+
+	a.Content = strings.Join(opts.Hosts, ",")
+	if _, err := b.db().UpdateA(a); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, opts.Fqdn))
+	}
+
+	if err := b.setSubRecords(opts, a.ID); err != nil {
+		return d, err
+	}
+
+	return b.Get(opts)
+}
+
+func (b *Backend) Delete(opts *model.DomainOptions) error {
+	b.log().Debugf("delete %s record for domain options: %s", typeA, opts.String())
+
+	a, err := b.db().QueryA(opts.Fqdn)
+	if err != nil || a.Fqdn == "" {
+		return backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
+	}
+
+	// sub domain A records are deleted with the parent A record
+	return b.db().DeleteA(opts.Fqdn)
+}
+
+func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("renew records for domain options: %s", opts.String())
+
+	if _, err := b.queryToken(opts.Fqdn); err != nil {
+		return d, err
+	}
+
+	_, t, err := b.db().RenewToken(opts.Fqdn)
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFromDatabase, opts.Fqdn))
+	}
+
+	if err := b.db().RenewFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFromDatabase, opts.Fqdn))
+	}
+
+	return model.Domain{
+		Fqdn:       opts.Fqdn,
+		Expiration: convertExpiration(time.Unix(0, t), b.LeaseTime),
+	}, nil
+}
+
+func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("set %s record for domain options: %s", typeTXT, opts.String())
+
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return d, err
+	}
+
+	r, err := b.db().QueryTXT(opts.Fqdn)
+	if err != nil {
+		return d, err
+	}
+	if r.Fqdn != "" {
+		return d, backend.Conflict(errors.Errorf(errExistRecord, typeTXT, opts.Fqdn))
+	}
+
+	if _, err := b.db().InsertTXT(&model.RecordTXT{
+		Type:      recordTypeTXT,
+		Fqdn:      opts.Fqdn,
+		Content:   opts.Text,
+		CreatedOn: time.Now().Unix(),
+		TID:       token.ID,
+	}); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeTXT, opts.Fqdn))
+	}
+
+	return b.GetText(opts)
+}
+
+func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("get %s record for domain options: %s", typeTXT, opts.String())
+
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return d, err
+	}
+
+	r, err := b.db().QueryTXT(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeTXT, opts.Fqdn))
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.Text = r.Content
+	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), b.LeaseTime)
+
+	return d, nil
+}
+
+func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("update %s record for domain options: %s", typeTXT, opts.String())
+
+	if _, err := b.queryToken(b.findSlugWithZone(opts.Fqdn)); err != nil {
+		return d, err
+	}
+
+	r, err := b.db().QueryTXT(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeTXT, opts.Fqdn))
+	}
+
+	r.Content = opts.Text
+	if _, err := b.db().UpdateTXT(r); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeTXT, opts.Fqdn))
+	}
+
+	return b.GetText(opts)
+}
+
+func (b *Backend) DeleteText(opts *model.DomainOptions) error {
+	b.log().Debugf("delete %s record for domain options: %s", typeTXT, opts.String())
+
+	r, err := b.db().QueryTXT(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return backend.NotFound(errors.Errorf(errNoRecord, typeTXT, opts.Fqdn))
+	}
+
+	return b.db().DeleteTXT(opts.Fqdn)
+}
+
+func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("set %s record for domain options: %s", typeCNAME, opts.String())
+
+	tID, err := b.allocate(opts)
+	if err != nil {
+		return d, err
+	}
+
+	if _, err := b.db().InsertCNAME(&model.RecordCNAME{
+		Type:      recordTypeCNAME,
+		Fqdn:      opts.Fqdn,
+		Content:   opts.CNAME,
+		CreatedOn: time.Now().Unix(),
+		TID:       tID,
+	}); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeCNAME, opts.Fqdn))
+	}
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("get %s record for domain options: %s", typeCNAME, opts.String())
+
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
+	if err != nil {
+		return d, err
+	}
+
+	r, err := b.db().QueryCNAME(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeCNAME, opts.Fqdn))
+	}
+
+	d.Fqdn = opts.Fqdn
+	d.CNAME = r.Content
+	d.Expiration = convertExpiration(time.Unix(0, token.CreatedOn), b.LeaseTime)
+
+	return d, nil
+}
+
+func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
+	b.log().Debugf("update %s record for domain options: %s", typeCNAME, opts.String())
+
+	if _, err := b.queryToken(b.findSlugWithZone(opts.Fqdn)); err != nil {
+		return d, err
+	}
+
+	r, err := b.db().QueryCNAME(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeCNAME, opts.Fqdn))
+	}
+
+	r.Content = opts.CNAME
+	if _, err := b.db().UpdateCNAME(r); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeCNAME, opts.Fqdn))
+	}
+
+	return b.GetCNAME(opts)
+}
+
+func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
+	b.log().Debugf("delete %s record for domain options: %s", typeCNAME, opts.String())
+
+	r, err := b.db().QueryCNAME(opts.Fqdn)
+	if err != nil || r.Fqdn == "" {
+		return backend.NotFound(errors.Errorf(errNoRecord, typeCNAME, opts.Fqdn))
+	}
+
+	return b.db().DeleteCNAME(opts.Fqdn)
+}
+
+func (b *Backend) GetToken(fqdn string) (string, error) {
+	t, err := b.queryToken(fqdn)
+	return t.Token, err
+}
+
+func (b *Backend) GetTokenCount() (int64, error) {
+	return b.db().QueryTokenCount()
+}
+
+func (b *Backend) UpdateToken(fqdn, token string) error {
+	b.log().Debugf("update token for fqdn: %s", fqdn)
+
+	if _, err := b.findToken(fqdn); err != nil {
+		return err
+	}
+
+	if err := b.db().UpdateToken(token, fqdn); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errUpdateTokenToDatabase, fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) SetSubToken(fqdn string, st *model.SubToken) error {
+	b.log().Debugf("set sub token %s for fqdn: %s", st.SID, fqdn)
+
+	t, err := b.queryToken(fqdn)
+	if err != nil {
+		return err
+	}
+
+	st.TID = t.ID
+	if _, err := b.db().InsertSubToken(st); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errInsertTokenToDatabase, fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetSubTokens(fqdn string) ([]*model.SubToken, error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := b.db().ListSubTokens(t.ID)
+	if err != nil {
+		return nil, backend.Unavailable(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+
+	return ts, nil
+}
+
+func (b *Backend) DeleteSubToken(fqdn, sid string) error {
+	b.log().Debugf("delete sub token %s for fqdn: %s", sid, fqdn)
+
+	ts, err := b.GetSubTokens(fqdn)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range ts {
+		if t.SID != sid {
+			continue
+		}
+		if err := b.db().DeleteSubToken(sid); err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errDeleteSubTokenFromDatabase, sid))
+		}
+		return nil
+	}
+
+	return backend.NotFound(errors.Errorf(errNoSubToken, sid, fqdn))
+}
+
+func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	return b.db().MigrateFrozen(opts.Path, opts.Expiration.UnixNano())
+}
+
+func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
+	return b.db().MigrateToken(opts.Token, opts.Path, opts.Expiration.UnixNano())
+}
+
+func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
+	if opts.Text != "" {
+		// migrate TXT record
+		dopts := &model.DomainOptions{
+			Fqdn: opts.Fqdn,
+			Text: opts.Text,
+		}
+		_, err := b.SetText(dopts)
+		return err
+	}
+
+	dopts := &model.DomainOptions{
+		Fqdn:      opts.Fqdn,
+		Hosts:     opts.Hosts,
+		SubDomain: opts.SubDomain,
+	}
+
+	t, err := b.db().QueryToken(b.findSlugWithZone(dopts.Fqdn))
+	if err != nil {
+		return errors.Wrapf(err, errQueryTokenFromDatabase, dopts.Fqdn)
+	}
+
+	pID, err := b.db().InsertA(&model.RecordA{
+		Type:      recordTypeA,
+		Fqdn:      dopts.Fqdn,
+		Content:   strings.Join(dopts.Hosts, ","),
+		CreatedOn: time.Now().Unix(),
+		TID:       t.ID,
+	})
+	if err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, dopts.Fqdn))
+	}
+
+	return b.setSubRecords(dopts, pID)
+}
+
+func (b *Backend) List(opts *model.ListOptions) (model.DomainList, error) {
+	return backend.ListDatabaseDomains(b.ctx, b.Zone, b.LeaseTime, opts, b.inspectToken)
+}
+
+func (b *Backend) Inspect(fqdn string) (d model.DomainInfo, err error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = b.inspectToken(t)
+	if err != nil {
+		return d, err
+	}
+
+	ts, err := b.db().QueryExpiredTXTs(t.ID)
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryTextFromDatabase, fqdn))
+	}
+	if len(ts) > 0 {
+		d.Texts = make(map[string]string)
+		for _, r := range ts {
+			d.Texts[r.Fqdn] = r.Content
+		}
+	}
+
+	return d, nil
+}
+
+func (b *Backend) ForceDelete(fqdn string) error {
+	b.log().Debugf("force delete records for fqdn: %s", fqdn)
+
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return err
+	}
+
+	return backend.PurgeDomain(b.ctx, b, t)
+}
+
+func (b *Backend) ForceRenew(fqdn string) (d model.DomainInfo, err error) {
+	b.log().Debugf("force renew records for fqdn: %s", fqdn)
+
+	// the token is renewed by the fqdn of the token, which holds the records of the requested fqdn
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	if _, _, err := b.db().RenewToken(t.Fqdn); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFromDatabase, t.Fqdn))
+	}
+
+	if err := b.db().RenewFrozen(strings.Split(t.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFromDatabase, t.Fqdn))
+	}
+
+	return b.Inspect(t.Fqdn)
+}
+
+// Watch polls the changes recorded in the database, the backend must be wrapped by backend.WithChanges to record them.
+func (b *Backend) Watch(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error) {
+	return backend.WatchDatabase(ctx, fqdn, revision)
+}
+
+// Used to allocate a random fqdn with its frozen prefix and token, returns the token ID
+func (b *Backend) allocate(opts *model.DomainOptions) (int64, error) {
+	opts.Fqdn = ""
+
+	// the requested name is used as it is, a random slug is generated if no name is requested
+	if opts.Name != "" {
+		ok, err := b.checkName(opts.Name)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return 0, backend.Conflict(errors.Errorf(errExistName, opts.Name))
+		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Zone)
+	}
+
+	if opts.Fqdn == "" {
+		name, err := slug.Allocate(b.checkName)
+		if err != nil {
+			return 0, backend.Unavailable(errors.Wrapf(err, errGenerateName, opts.String()))
+		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", name, b.Zone)
+	}
+
+	// save the slug name to the database in case of the name will be re-generate
+	name := strings.Split(opts.Fqdn, ".")[0]
+	if err := b.db().InsertFrozen(name); err != nil {
+		return 0, backend.Unavailable(errors.Wrapf(err, errInsertFrozenToDatabase, name))
+	}
+
+	tID, err := b.db().InsertToken(generateToken(), opts.Fqdn)
+	if err != nil {
+		return 0, backend.Unavailable(errors.Wrapf(err, errInsertTokenToDatabase, opts.Fqdn))
+	}
+
+	return tID, nil
+}
+
+// Used to sync sub domain A records of the parent A record
+func (b *Backend) setSubRecords(opts *model.DomainOptions, pID int64) error {
+	origins, err := b.db().ListSubA(pID)
+	if err != nil {
+		return err
+	}
+
+	for _, origin := range origins {
+		if _, ok := opts.SubDomain[strings.Split(origin.Fqdn, ".")[0]]; !ok {
+			if err := b.db().DeleteSubA(origin.Fqdn); err != nil {
+				return err
+			}
+		}
+	}
+
+	for k, v := range opts.SubDomain {
+		r := &model.SubRecordA{
+			Type:      recordTypeSubA,
+			Fqdn:      fmt.Sprintf("%s.%s", k, opts.Fqdn),
+			Content:   strings.Join(v, ","),
+			CreatedOn: time.Now().Unix(),
+			PID:       pID,
+		}
+
+		result, _ := b.db().QuerySubA(r.Fqdn)
+		if result != nil && result.Fqdn != "" {
+			_, err = b.db().UpdateSubA(r)
+		} else {
+			_, err = b.db().InsertSubA(r)
+		}
+		if err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, r.Fqdn))
+		}
+	}
+
+	return nil
+}
+
+// Used to query token which is not expired, the expired token is purged by the purger later
+func (b *Backend) queryToken(fqdn string) (*model.Token, error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return t, err
+	}
+
+	if convertExpiration(time.Unix(0, t.CreatedOn), b.LeaseTime).Before(time.Now()) {
+		return t, backend.Expired(errors.Errorf(errExpiredToken, fqdn))
+	}
+
+	return t, nil
+}
+
+// Used to find token regardless of the lease
+func (b *Backend) findToken(fqdn string) (*model.Token, error) {
+	t, err := b.db().QueryToken(fqdn)
+	if err == sql.ErrNoRows {
+		return t, backend.NotFound(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+	if err != nil {
+		return t, backend.Unavailable(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+	return t, nil
+}
+
+// Used to convert the token to the domain with its records in the database regardless of the lease
+func (b *Backend) inspectToken(t *model.Token) (d model.DomainInfo, err error) {
+	d.Fqdn = t.Fqdn
+	d.Token = model.NewTokenInfo(time.Unix(0, t.CreatedOn), b.LeaseTime)
+
+	if a, err := b.db().QueryA(t.Fqdn); err == nil && a.Fqdn != "" {
+		subs, err := b.db().ListSubA(a.ID)
+		if err != nil {
+			return d, backend.Unavailable(err)
+		}
+
+		d.Type = typeA
+		d.Hosts = splitContent(a.Content)
+		d.SubDomain = make(map[string][]string, 0)
+		for _, sub := range subs {
+			d.SubDomain[strings.Split(sub.Fqdn, ".")[0]] = splitContent(sub.Content)
+		}
+	}
+
+	if c, err := b.db().QueryCNAME(t.Fqdn); err == nil && c.Fqdn != "" {
+		d.Type = typeCNAME
+		d.CNAME = c.Content
+	}
+
+	return d, nil
+}
+
+// Used to check whether the slug name can be used or not, the name is not valid if it is frozen or used by a domain
+func (b *Backend) checkName(name string) (bool, error) {
+	r, err := b.db().QueryFrozen(name)
+	if err != nil && err != sql.ErrNoRows {
+		return false, backend.Unavailable(err)
+	}
+	if r != "" {
+		return false, nil
+	}
+
+	fqdn := fmt.Sprintf("%s.%s", name, b.Zone)
+	if t, err := b.db().QueryToken(fqdn); err == nil && t.Fqdn != "" {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Used to find slug name with zone
+// e.g. yyyy.xxxx.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
+func (b *Backend) findSlugWithZone(fqdn string) string {
+	ss := strings.Split(fqdn, ".")
+	n := len(strings.Split(b.Zone, ".")) + 1
+	if len(ss) <= n {
+		return fqdn
+	}
+	return strings.Join(ss[len(ss)-n:], ".")
+}
+
+// Used to generate the hashed token of a new domain, the token is replaced when it is responded to the client
+func generateToken() string {
+	return util.HashToken(util.RandStringWithAll(tokenLength))
+}
+
+// Used to split the database content to hosts
+func splitContent(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(content, ",")
+}
+
+// Used to convert expiration
+func convertExpiration(create time.Time, ttl time.Duration) *time.Time {
+	e := create.Add(ttl)
+	return &e
+}
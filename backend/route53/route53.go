@@ -1,19 +1,23 @@
 package route53
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/metric"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/slug"
 	"github.com/rancher/rdns-server/util"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/pkg/errors"
@@ -21,13 +25,12 @@ import (
 )
 
 const (
-	Name             = "route53"
-	typeA            = "A"
-	typeTXT          = "TXT"
-	typeCNAME        = "CNAME"
-	maxSlugHashTimes = 100
-	slugLength       = 6
-	tokenLength      = 32
+	Name        = "route53"
+	typeA       = "A"
+	typeAAAA    = "AAAA"
+	typeTXT     = "TXT"
+	typeCNAME   = "CNAME"
+	tokenLength = 32
 )
 
 type Backend struct {
@@ -37,9 +40,11 @@ type Backend struct {
 	TTL       int64
 
 	Svc *route53.Route53
+
+	ctx context.Context
 }
 
-func NewBackend() (*Backend, error) {
+func NewBackend(opts *backend.ZoneOptions) (*Backend, error) {
 	c := credentials.NewEnvCredentials()
 
 	s, err := session.NewSession()
@@ -51,30 +56,29 @@ func NewBackend() (*Backend, error) {
 		Credentials: c,
 		MaxRetries:  aws.Int(3),
 	})
+	// throttles are observed by attempts since the SDK retries them, the latency includes the retries
+	svc.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+		if request.IsErrorThrottle(r.Error) {
+			metric.ObserveBackendThrottle(Name, r.Operation.Name)
+		}
+	})
+	svc.Handlers.Complete.PushBack(func(r *request.Request) {
+		metric.ObserveBackendRequest(Name, r.Operation.Name, time.Since(r.Time), r.Error != nil)
+	})
 
 	z, err := svc.GetHostedZone(&route53.GetHostedZoneInput{
-		Id: aws.String(os.Getenv("AWS_HOSTED_ZONE_ID")),
+		Id: aws.String(opts.Name),
 	})
 	if err != nil {
 		return &Backend{}, err
 	}
 
-	d, err := time.ParseDuration(os.Getenv("DATABASE_LEASE_TIME"))
-	if err != nil {
-		return &Backend{}, errors.Wrapf(err, errParseFlag, "database_lease_time")
-	}
-
-	ttl, err := strconv.ParseInt(os.Getenv("TTL"), 10, 64)
-	if err != nil {
-		return &Backend{}, errors.Wrapf(err, errParseFlag, "ttl")
-	}
-
 	return &Backend{
-		LeaseTime: d,
+		LeaseTime: opts.LeaseTime,
 		Zone:      strings.TrimRight(aws.StringValue(z.HostedZone.Name), "."),
 		ZoneID:    aws.StringValue(z.HostedZone.Id),
 		Svc:       svc,
-		TTL:       ttl,
+		TTL:       opts.TTL,
 	}, nil
 }
 
@@ -82,17 +86,38 @@ func (b *Backend) GetName() string {
 	return Name
 }
 
-func (b *Backend) GetZone() string {
-	return b.Zone
+// WithContext returns a copy of the backend which logs the request ID of the context and traces its database statements under it.
+func (b *Backend) WithContext(ctx context.Context) backend.Backend {
+	c := *b
+	c.ctx = ctx
+	return &c
+}
+
+// Ping gets the hosted zone, so expired credentials are found, the database is checked on its own.
+func (b *Backend) Ping(ctx context.Context) error {
+	if _, err := b.Svc.GetHostedZoneWithContext(ctx, &route53.GetHostedZoneInput{Id: aws.String(b.ZoneID)}); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errPing, b.ZoneID))
+	}
+	return nil
+}
+
+// Used to get the logger of the request bound to the backend
+func (b *Backend) log() *logrus.Entry {
+	return backend.Logger(b.ctx)
+}
+
+// Used to get the database bound to the request, so its statements are traced under the request
+func (b *Backend) db() database.Database {
+	return database.GetDatabase().WithContext(b.ctx)
 }
 
 func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("get A record for domain options: %s", opts.String())
+	b.log().Debugf("get A/AAAA record for domain options: %s", opts.String())
 
 	// get token from database
-	token, err := database.GetDatabase().QueryToken(opts.Fqdn)
+	token, err := b.queryToken(opts.Fqdn)
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	records, err := b.getRecords(opts, typeA)
@@ -104,12 +129,15 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 	if !v {
 		emptyName := fmt.Sprintf("%s.%s", "empty", opts.Fqdn)
 
-		e, err := database.GetDatabase().QueryA(emptyName)
-		if err != nil || e.Fqdn == "" {
-			return d, errors.Wrapf(err, errQueryAFromDatabase, emptyName)
+		e, err := b.db().QueryA(emptyName)
+		if err != nil {
+			return d, backend.Unavailable(errors.Wrapf(err, errQueryAFromDatabase, emptyName))
+		}
+		if e.Fqdn == "" {
+			return d, backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
 		}
 
-		subs, _ := database.GetDatabase().ListSubA(e.ID)
+		subs, _ := b.db().ListSubA(e.ID)
 		if len(subs) > 0 {
 			ss := make(map[string][]string, 0)
 			for _, sub := range subs {
@@ -130,7 +158,7 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 		return d, nil
 	}
 
-	// convert A & sub domain records to map
+	// convert A/AAAA & sub domain records to map
 	ca, cs := b.convertARecords(a, s)
 
 	d.Fqdn = opts.Fqdn
@@ -142,45 +170,35 @@ func (b *Backend) Get(opts *model.DomainOptions) (d model.Domain, err error) {
 }
 
 func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("set A record for domain options: %s", opts.String())
+	b.log().Debugf("set A/AAAA record for domain options: %s", opts.String())
 
-	for i := 0; i < maxSlugHashTimes; i++ {
-		fqdn := fmt.Sprintf("%s.%s", generateSlug(), b.Zone)
+	opts.Fqdn = ""
 
-		// check whether this slug name can be used or not, if not found the slug name is valid, others not valid
-		r, err := database.GetDatabase().QueryFrozen(strings.Split(fqdn, ".")[0])
-		if err != nil && err != sql.ErrNoRows {
+	// the requested name is used as it is, a random slug is generated if no name is requested
+	if opts.Name != "" {
+		if err := b.checkName(opts.Name); err != nil {
 			return d, err
 		}
-		if r != "" {
-			logrus.Debugf(errNotValidGenerateName, strings.Split(fqdn, ".")[0])
-			continue
-		}
-
-		o := &model.DomainOptions{
-			Fqdn: fqdn,
-		}
-
-		d, err := b.Get(o)
-		if err != nil || d.Fqdn == "" {
-			opts.Fqdn = fqdn
-			break
-		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Zone)
 	}
 
 	if opts.Fqdn == "" {
-		return d, errors.Errorf(errGenerateName, opts.String())
+		name, err := b.allocateName(b.Get)
+		if err != nil {
+			return d, backend.Unavailable(errors.Wrapf(err, errGenerateName, opts.String()))
+		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", name, b.Zone)
 	}
 
 	// save the slug name to the database in case of the name will be re-generate
-	if err := database.GetDatabase().InsertFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
-		return d, errors.Wrapf(err, errInsertFrozenToDatabase, strings.Split(opts.Fqdn, ".")[0])
+	if err := b.db().InsertFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertFrozenToDatabase, strings.Split(opts.Fqdn, ".")[0]))
 	}
 
 	// save token to the database
 	tID, err := b.SetToken(opts, false)
 	if err != nil {
-		return d, errors.Wrapf(err, errInsertTokenToDatabase, opts.Fqdn)
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertTokenToDatabase, opts.Fqdn))
 	}
 
 	// set empty A record, sometimes we need to hold domain records although domain has no hosts value
@@ -196,44 +214,21 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 	}
 	pID, err := b.setRecordToDatabase(rrs, typeA, tID, 0, false)
 	if err != nil {
-		return d, errors.Wrapf(err, errInsertRecordToDatabase, typeA, aws.StringValue(rrs.Name))
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, aws.StringValue(rrs.Name)))
 	}
 
-	// set A and wildcard A record
-	rr := make([]*route53.ResourceRecord, 0)
-	for _, h := range opts.Hosts {
-		rr = append(rr, &route53.ResourceRecord{
-			Value: aws.String(h),
-		})
-	}
-	rrs.Name = aws.String(opts.Fqdn)
-	rrs.ResourceRecords = rr
-	if _, err := b.setRecord(rrs, opts, typeA, tID, pID, false); err != nil {
+	// set A/AAAA and wildcard A/AAAA records
+	if _, err := b.setHostRecords(opts.Fqdn, opts.Hosts, opts, tID, pID, false); err != nil {
 		return d, err
 	}
 
-	rrs.Name = aws.String(fmt.Sprintf("\\052.%s", opts.Fqdn))
-	if _, err := b.setRecord(rrs, opts, typeA, tID, pID, false); err != nil {
+	if _, err := b.setHostRecords(fmt.Sprintf("\\052.%s", opts.Fqdn), opts.Hosts, opts, tID, pID, false); err != nil {
 		return d, err
 	}
 
-	// set sub domain A record
+	// set sub domain A/AAAA records
 	for k, v := range opts.SubDomain {
-		rr := make([]*route53.ResourceRecord, 0)
-		for _, h := range v {
-			rr = append(rr, &route53.ResourceRecord{
-				Value: aws.String(h),
-			})
-		}
-
-		rrs := &route53.ResourceRecordSet{
-			Type:            aws.String(typeA),
-			Name:            aws.String(fmt.Sprintf("%s.%s", k, opts.Fqdn)),
-			ResourceRecords: rr,
-			TTL:             aws.Int64(int64(b.TTL)),
-		}
-
-		if _, err := b.setRecord(rrs, opts, typeA, tID, pID, true); err != nil {
+		if _, err := b.setHostRecords(fmt.Sprintf("%s.%s", k, opts.Fqdn), v, opts, tID, pID, true); err != nil {
 			return d, err
 		}
 	}
@@ -242,7 +237,7 @@ func (b *Backend) Set(opts *model.DomainOptions) (d model.Domain, err error) {
 }
 
 func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("update A record for domain options: %s", opts.String())
+	b.log().Debugf("update A/AAAA record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeA)
 	if err != nil {
@@ -251,115 +246,71 @@ func (b *Backend) Update(opts *model.DomainOptions) (d model.Domain, err error)
 
 	_, a, s, _, _ := b.filterRecords(records.ResourceRecordSets, opts, typeA)
 
-	// convert A & sub domain records to map
-	as, cs := b.convertARecords(a, s)
-
-	rr := make([]*route53.ResourceRecord, 0)
-	for _, h := range opts.Hosts {
-		rr = append(rr, &route53.ResourceRecord{
-			Value: aws.String(h),
-		})
-	}
-
-	rrs := &route53.ResourceRecordSet{
-		Type:            aws.String(typeA),
-		Name:            aws.String(opts.Fqdn),
-		ResourceRecords: rr,
-		TTL:             aws.Int64(int64(b.TTL)),
+	e, err := b.db().QueryA(fmt.Sprintf("empty.%s", opts.Fqdn))
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryAFromDatabase, opts.Fqdn))
 	}
-
-	e, err := database.GetDatabase().QueryA(fmt.Sprintf("empty.%s", opts.Fqdn))
-	if err != nil || e.Fqdn == "" {
-		return d, errors.Wrapf(err, errQueryAFromDatabase, opts.Fqdn)
+	if e.Fqdn == "" {
+		return d, backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
 	}
 
-	// update A and wildcard A records
-	if _, err := b.setRecord(rrs, opts, typeA, e.TID, e.ID, false); err != nil {
+	// update A/AAAA and wildcard A/AAAA records
+	if _, err := b.setHostRecords(opts.Fqdn, opts.Hosts, opts, e.TID, e.ID, false); err != nil {
 		return d, err
 	}
-	rrs.Name = aws.String(fmt.Sprintf("\\052.%s", opts.Fqdn))
-	if _, err := b.setRecord(rrs, opts, typeA, e.TID, e.ID, false); err != nil {
+	if _, err := b.setHostRecords(fmt.Sprintf("\\052.%s", opts.Fqdn), opts.Hosts, opts, e.TID, e.ID, false); err != nil {
 		return d, err
 	}
 
-	// update sub domain A records
+	// update sub domain A/AAAA records
 	for k, v := range opts.SubDomain {
-		rr := make([]*route53.ResourceRecord, 0)
-		for _, h := range v {
-			rr = append(rr, &route53.ResourceRecord{
-				Value: aws.String(h),
-			})
+		if _, err := b.setHostRecords(fmt.Sprintf("%s.%s", k, opts.Fqdn), v, opts, e.TID, e.ID, true); err != nil {
+			return d, err
 		}
+	}
 
-		rrs := &route53.ResourceRecordSet{
-			Type:            aws.String(typeA),
-			Name:            aws.String(fmt.Sprintf("%s.%s", k, opts.Fqdn)),
-			ResourceRecords: rr,
-			TTL:             aws.Int64(int64(b.TTL)),
+	// delete useless domain A/AAAA records, the database record is already updated with the rest hosts
+	for _, rs := range a {
+		rType := aws.StringValue(rs.Type)
+		if hasHostsOfType(opts.Hosts, rType) {
+			continue
 		}
-
-		if _, err := b.setRecord(rrs, opts, typeA, e.TID, e.ID, true); err != nil {
+		if err := b.deleteRoute53Record(rs, opts, rType); err != nil {
 			return d, err
 		}
 	}
 
-	// delete useless domain A records
-	if len(opts.Hosts) <= 0 {
-		name := opts.Fqdn
-		if _, ok := as[name]; ok {
-			rr := make([]*route53.ResourceRecord, 0)
-			for _, h := range as[name] {
-				rr = append(rr, &route53.ResourceRecord{
-					Value: aws.String(h),
-				})
-			}
-
-			rrs := &route53.ResourceRecordSet{
-				Name:            aws.String(name),
-				Type:            aws.String(typeA),
-				ResourceRecords: rr,
-				TTL:             aws.Int64(int64(b.TTL)),
-			}
-
-			if err := b.deleteRecord(rrs, opts, typeA, true); err != nil {
-				return d, err
-			}
-			rrs.Name = aws.String(fmt.Sprintf("\\052.%s", opts.Fqdn))
-			if err := b.deleteRecord(rrs, opts, typeA, true); err != nil {
-				return d, err
-			}
+	// delete useless sub domain A/AAAA records, a sub domain keeps one database record for both types
+	// and may have no route53 record at all, so the database records are compared on their own
+	for _, rs := range s {
+		rType := aws.StringValue(rs.Type)
+		prefix := strings.Split(aws.StringValue(rs.Name), ".")[0]
+		if hosts, ok := opts.SubDomain[prefix]; ok && hasHostsOfType(hosts, rType) {
+			continue
+		}
+		if err := b.deleteRoute53Record(rs, opts, rType); err != nil {
+			return d, err
 		}
 	}
 
-	// delete useless sub domain A records
-	for k, v := range cs {
-		if _, ok := opts.SubDomain[k]; !ok {
-			rr := make([]*route53.ResourceRecord, 0)
-			for _, h := range v {
-				rr = append(rr, &route53.ResourceRecord{
-					Value: aws.String(h),
-				})
-			}
-
-			rrs := &route53.ResourceRecordSet{
-				Name:            aws.String(fmt.Sprintf("%s.%s", k, opts.Fqdn)),
-				Type:            aws.String(typeA),
-				ResourceRecords: rr,
-				TTL:             aws.Int64(int64(b.TTL)),
-			}
-
-			if err := b.deleteRecord(rrs, opts, typeA, true); err != nil {
-				return d, err
-			}
+	subs, err := b.db().ListSubA(e.ID)
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryAFromDatabase, opts.Fqdn))
+	}
+	for _, sub := range subs {
+		if _, ok := opts.SubDomain[strings.Split(sub.Fqdn, ".")[0]]; ok {
 			continue
 		}
+		if err := b.db().DeleteSubA(sub.Fqdn); err != nil {
+			return d, backend.Unavailable(errors.Wrapf(err, errDeleteRecordsFromDatabase, typeA, opts.Fqdn))
+		}
 	}
 
 	return b.Get(opts)
 }
 
 func (b *Backend) Delete(opts *model.DomainOptions) error {
-	logrus.Debugf("delete A record for domain options: %s", opts.String())
+	b.log().Debugf("delete A/AAAA record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeA)
 	if err != nil {
@@ -368,95 +319,96 @@ func (b *Backend) Delete(opts *model.DomainOptions) error {
 
 	_, a, s, _, _ := b.filterRecords(records.ResourceRecordSets, opts, typeA)
 
-	// delete wildcard A records
+	// domain without any hosts only has the empty record in database
+	emptyName := fmt.Sprintf("%s.%s", "empty", opts.Fqdn)
+	if len(a) == 0 && len(s) == 0 {
+		e, err := b.db().QueryA(emptyName)
+		if err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errQueryAFromDatabase, emptyName))
+		}
+		if e.Fqdn == "" {
+			return backend.NotFound(errors.Errorf(errNoRecord, typeA, opts.Fqdn))
+		}
+	}
+
+	// delete A/AAAA and wildcard A/AAAA records
 	for _, rr := range a {
-		if err := b.deleteRecord(rr, opts, typeA, false); err != nil {
+		if err := b.deleteRecord(rr, opts, aws.StringValue(rr.Type), false); err != nil {
 			return err
 		}
 	}
 
-	// delete sub domain A records
+	// delete sub domain A/AAAA records
 	if len(s) > 0 {
 		for _, rr := range s {
-			if err := b.deleteRecord(rr, opts, typeA, true); err != nil {
+			if err := b.deleteRecord(rr, opts, aws.StringValue(rr.Type), true); err != nil {
 				return err
 			}
 		}
 	}
 
 	// delete empty record from database
-	emptyName := fmt.Sprintf("%s.%s", "empty", opts.Fqdn)
-	if err := database.GetDatabase().DeleteA(emptyName); err != nil {
-		return errors.Wrapf(err, errDeleteAFromDatabase, emptyName)
+	if err := b.db().DeleteA(emptyName); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errDeleteAFromDatabase, emptyName))
 	}
 
 	return nil
 }
 
 func (b *Backend) Renew(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("renew records for domain options: %s", opts.String())
+	b.log().Debugf("renew records for domain options: %s", opts.String())
 
 	// renew token record
-	t, err := database.GetDatabase().QueryToken(opts.Fqdn)
+	t, err := b.queryToken(opts.Fqdn)
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
-	_, _, err = database.GetDatabase().RenewToken(t.Fqdn)
+	_, renewed, err := b.db().RenewToken(t.Fqdn)
 	if err != nil {
-		return d, errors.Wrapf(err, errRenewTokenFromDatabase, opts.Fqdn)
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewTokenFromDatabase, opts.Fqdn))
 	}
 
 	// renew frozen record
-	if err := database.GetDatabase().RenewFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
-		return d, errors.Wrapf(err, errRenewFrozenFromDatabase, opts.Fqdn)
+	if err := b.db().RenewFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFrozenFromDatabase, opts.Fqdn))
 	}
 
 	return model.Domain{
 		Fqdn:       opts.Fqdn,
-		Expiration: convertExpiration(time.Unix(0, t.CreatedOn), int(b.LeaseTime.Nanoseconds())),
+		Expiration: convertExpiration(time.Unix(0, renewed), int(b.LeaseTime.Nanoseconds())),
 	}, nil
 }
 
 func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("set CNAME record for domain options: %s", opts.String())
+	b.log().Debugf("set CNAME record for domain options: %s", opts.String())
 
-	for i := 0; i < maxSlugHashTimes; i++ {
-		fqdn := fmt.Sprintf("%s.%s", generateSlug(), b.Zone)
+	opts.Fqdn = ""
 
-		// check whether this slug name can be used or not, if not found the slug name is valid, others not valid
-		r, err := database.GetDatabase().QueryFrozen(strings.Split(fqdn, ".")[0])
-		if err != nil && err != sql.ErrNoRows {
+	// the requested name is used as it is, a random slug is generated if no name is requested
+	if opts.Name != "" {
+		if err := b.checkName(opts.Name); err != nil {
 			return d, err
 		}
-		if r != "" {
-			logrus.Debugf(errNotValidGenerateName, strings.Split(fqdn, ".")[0])
-			continue
-		}
-
-		o := &model.DomainOptions{
-			Fqdn: fqdn,
-		}
-
-		d, err := b.GetCNAME(o)
-		if err != nil || d.Fqdn == "" {
-			opts.Fqdn = fqdn
-			break
-		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", opts.Name, b.Zone)
 	}
 
 	if opts.Fqdn == "" {
-		return d, errors.Errorf(errGenerateName, opts.String())
+		name, err := b.allocateName(b.GetCNAME)
+		if err != nil {
+			return d, backend.Unavailable(errors.Wrapf(err, errGenerateName, opts.String()))
+		}
+		opts.Fqdn = fmt.Sprintf("%s.%s", name, b.Zone)
 	}
 
 	// save the slug name to the database in case of the name will be re-generate
-	if err := database.GetDatabase().InsertFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
-		return d, errors.Wrapf(err, errInsertFrozenToDatabase, strings.Split(opts.Fqdn, ".")[0])
+	if err := b.db().InsertFrozen(strings.Split(opts.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertFrozenToDatabase, strings.Split(opts.Fqdn, ".")[0]))
 	}
 
 	// save token to the database
 	tID, err := b.SetToken(opts, false)
 	if err != nil {
-		return d, errors.Wrapf(err, errInsertTokenToDatabase, opts.Fqdn)
+		return d, backend.Unavailable(errors.Wrapf(err, errInsertTokenToDatabase, opts.Fqdn))
 	}
 
 	rrs := &route53.ResourceRecordSet{
@@ -485,7 +437,7 @@ func (b *Backend) SetCNAME(opts *model.DomainOptions) (d model.Domain, err error
 }
 
 func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("get CNAME record for domain options: %s", opts.String())
+	b.log().Debugf("get CNAME record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeCNAME)
 	if err != nil {
@@ -494,13 +446,13 @@ func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error
 
 	valid, _, _, _, c := b.filterRecords(records.ResourceRecordSets, opts, typeCNAME)
 	if !valid || len(c) < 1 {
-		return d, errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn)
+		return d, backend.NotFound(errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn))
 	}
 
 	// get token from database
-	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	d.Fqdn = opts.Fqdn
@@ -511,7 +463,7 @@ func (b *Backend) GetCNAME(opts *model.DomainOptions) (d model.Domain, err error
 }
 
 func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("update CNAME record for domain options: %s", opts.String())
+	b.log().Debugf("update CNAME record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeCNAME)
 	if err != nil {
@@ -519,12 +471,12 @@ func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err er
 	}
 
 	if valid, _, _, _, _ := b.filterRecords(records.ResourceRecordSets, opts, typeCNAME); !valid {
-		return d, errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn)
+		return d, backend.NotFound(errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn))
 	}
 
-	r, err := database.GetDatabase().QueryCNAME(opts.Fqdn)
+	r, err := b.db().QueryCNAME(opts.Fqdn)
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryCNAMEFromDatabase, opts.Fqdn)
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryCNAMEFromDatabase, opts.Fqdn))
 	}
 
 	rrs := &route53.ResourceRecordSet{
@@ -549,9 +501,9 @@ func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err er
 	}
 
 	// get token from database
-	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	d.Fqdn = opts.Fqdn
@@ -562,7 +514,7 @@ func (b *Backend) UpdateCNAME(opts *model.DomainOptions) (d model.Domain, err er
 }
 
 func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
-	logrus.Debugf("delete CNAME record for domain options: %s", opts.String())
+	b.log().Debugf("delete CNAME record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeCNAME)
 	if err != nil {
@@ -571,7 +523,7 @@ func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
 
 	v, _, _, _, c := b.filterRecords(records.ResourceRecordSets, opts, typeCNAME)
 	if !v {
-		return errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn)
+		return backend.NotFound(errors.Errorf(errFilterRecords, typeCNAME, opts.Fqdn))
 	}
 
 	for _, rr := range c {
@@ -584,7 +536,7 @@ func (b *Backend) DeleteCNAME(opts *model.DomainOptions) error {
 }
 
 func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("get TXT record for domain options: %s", opts.String())
+	b.log().Debugf("get TXT record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
@@ -593,13 +545,13 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 
 	valid, _, _, t, _ := b.filterRecords(records.ResourceRecordSets, opts, typeTXT)
 	if !valid || len(t) < 1 {
-		return d, errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn)
+		return d, backend.NotFound(errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn))
 	}
 
 	// get token from database
-	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	d.Fqdn = opts.Fqdn
@@ -610,7 +562,7 @@ func (b *Backend) GetText(opts *model.DomainOptions) (d model.Domain, err error)
 }
 
 func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("set TXT record for domain options: %s", opts.String())
+	b.log().Debugf("set TXT record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
@@ -618,12 +570,12 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 	}
 
 	if valid, _, _, _, _ := b.filterRecords(records.ResourceRecordSets, opts, typeTXT); valid {
-		return d, errors.Errorf(errExistRecord, typeTXT, opts.Fqdn)
+		return d, backend.Conflict(errors.Errorf(errExistRecord, typeTXT, opts.Fqdn))
 	}
 
-	r, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	r, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	rrs := &route53.ResourceRecordSet{
@@ -645,7 +597,7 @@ func (b *Backend) SetText(opts *model.DomainOptions) (d model.Domain, err error)
 }
 
 func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err error) {
-	logrus.Debugf("update TXT record for domain options: %s", opts.String())
+	b.log().Debugf("update TXT record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
@@ -653,12 +605,12 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 	}
 
 	if valid, _, _, _, _ := b.filterRecords(records.ResourceRecordSets, opts, typeTXT); !valid {
-		return d, errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn)
+		return d, backend.NotFound(errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn))
 	}
 
-	r, err := database.GetDatabase().QueryTXT(opts.Fqdn)
+	r, err := b.db().QueryTXT(opts.Fqdn)
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTXTFromDatabase, opts.Fqdn)
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryTXTFromDatabase, opts.Fqdn))
 	}
 
 	rrs := &route53.ResourceRecordSet{
@@ -677,9 +629,9 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 	}
 
 	// get token from database
-	token, err := database.GetDatabase().QueryToken(b.findSlugWithZone(opts.Fqdn))
+	token, err := b.queryToken(b.findSlugWithZone(opts.Fqdn))
 	if err != nil {
-		return d, errors.Wrapf(err, errQueryTokenFromDatabase, opts.Fqdn)
+		return d, err
 	}
 
 	d.Fqdn = opts.Fqdn
@@ -691,7 +643,7 @@ func (b *Backend) UpdateText(opts *model.DomainOptions) (d model.Domain, err err
 }
 
 func (b *Backend) DeleteText(opts *model.DomainOptions) error {
-	logrus.Debugf("delete TXT record for domain options: %s", opts.String())
+	b.log().Debugf("delete TXT record for domain options: %s", opts.String())
 
 	records, err := b.getRecords(opts, typeTXT)
 	if err != nil {
@@ -700,7 +652,7 @@ func (b *Backend) DeleteText(opts *model.DomainOptions) error {
 
 	v, _, _, t, _ := b.filterRecords(records.ResourceRecordSets, opts, typeTXT)
 	if !v {
-		return errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn)
+		return backend.NotFound(errors.Errorf(errFilterRecords, typeTXT, opts.Fqdn))
 	}
 
 	for _, rr := range t {
@@ -713,32 +665,97 @@ func (b *Backend) DeleteText(opts *model.DomainOptions) error {
 }
 
 func (b *Backend) GetToken(fqdn string) (string, error) {
-	t, err := database.GetDatabase().QueryToken(fqdn)
+	t, err := b.db().QueryToken(fqdn)
 	return t.Token, err
 }
 
 func (b *Backend) GetTokenCount() (int64, error) {
-	return database.GetDatabase().QueryTokenCount()
+	return b.db().QueryTokenCount()
+}
+
+func (b *Backend) UpdateToken(fqdn, token string) error {
+	b.log().Debugf("update token for fqdn: %s", fqdn)
+
+	if _, err := b.findToken(fqdn); err != nil {
+		return err
+	}
+
+	if err := b.db().UpdateToken(token, fqdn); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errUpdateTokenToDatabase, fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) SetSubToken(fqdn string, st *model.SubToken) error {
+	b.log().Debugf("set sub token %s for fqdn: %s", st.SID, fqdn)
+
+	t, err := b.queryToken(fqdn)
+	if err != nil {
+		return err
+	}
+
+	st.TID = t.ID
+	if _, err := b.db().InsertSubToken(st); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errInsertTokenToDatabase, fqdn))
+	}
+
+	return nil
+}
+
+func (b *Backend) GetSubTokens(fqdn string) ([]*model.SubToken, error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := b.db().ListSubTokens(t.ID)
+	if err != nil {
+		return nil, backend.Unavailable(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+
+	return ts, nil
+}
+
+func (b *Backend) DeleteSubToken(fqdn, sid string) error {
+	b.log().Debugf("delete sub token %s for fqdn: %s", sid, fqdn)
+
+	ts, err := b.GetSubTokens(fqdn)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range ts {
+		if t.SID != sid {
+			continue
+		}
+		if err := b.db().DeleteSubToken(sid); err != nil {
+			return backend.Unavailable(errors.Wrapf(err, errDeleteSubTokenFromDatabase, sid))
+		}
+		return nil
+	}
+
+	return backend.NotFound(errors.Errorf(errNoSubToken, sid, fqdn))
 }
 
 func (b *Backend) SetToken(opts *model.DomainOptions, exist bool) (int64, error) {
 	if exist {
-		id, _, err := database.GetDatabase().RenewToken(opts.Fqdn)
+		id, _, err := b.db().RenewToken(opts.Fqdn)
 		if err != nil {
 			return 0, err
 		}
 		return id, err
 	}
 
-	return database.GetDatabase().InsertToken(generateToken(), opts.Fqdn)
+	return b.db().InsertToken(generateToken(), opts.Fqdn)
 }
 
 func (b *Backend) MigrateFrozen(opts *model.MigrateFrozen) error {
-	return database.GetDatabase().MigrateFrozen(opts.Path, opts.Expiration.UnixNano())
+	return b.db().MigrateFrozen(opts.Path, opts.Expiration.UnixNano())
 }
 
 func (b *Backend) MigrateToken(opts *model.MigrateToken) error {
-	return database.GetDatabase().MigrateToken(opts.Token, opts.Path, opts.Expiration.UnixNano())
+	return b.db().MigrateToken(opts.Token, opts.Path, opts.Expiration.UnixNano())
 }
 
 func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
@@ -757,7 +774,7 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 			Hosts:     opts.Hosts,
 			SubDomain: opts.SubDomain,
 		}
-		t, err := database.GetDatabase().QueryToken(b.findSlugWithZone(dopts.Fqdn))
+		t, err := b.db().QueryToken(b.findSlugWithZone(dopts.Fqdn))
 		if err != nil {
 			return errors.Wrapf(err, errQueryTokenFromDatabase, dopts.Fqdn)
 		}
@@ -775,50 +792,21 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 		}
 		pID, err := b.setRecordToDatabase(rrs, typeA, t.ID, 0, false)
 		if err != nil {
-			return errors.Wrapf(err, errInsertRecordToDatabase, typeA, aws.StringValue(rrs.Name))
+			return backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, aws.StringValue(rrs.Name)))
 		}
 
-		// set A and wildcard A record
-		rr := make([]*route53.ResourceRecord, 0)
-		for _, h := range dopts.Hosts {
-			rr = append(rr, &route53.ResourceRecord{
-				Value: aws.String(h),
-			})
-		}
-
-		rrs = &route53.ResourceRecordSet{
-			Type:            aws.String(typeA),
-			Name:            aws.String(dopts.Fqdn),
-			ResourceRecords: rr,
-			TTL:             aws.Int64(int64(b.TTL)),
-		}
-
-		if _, err := b.setRecord(rrs, dopts, typeA, t.ID, pID, false); err != nil {
+		// set A/AAAA and wildcard A/AAAA records
+		if _, err := b.setHostRecords(dopts.Fqdn, dopts.Hosts, dopts, t.ID, pID, false); err != nil {
 			return err
 		}
 
-		rrs.Name = aws.String(fmt.Sprintf("\\052.%s", dopts.Fqdn))
-		if _, err := b.setRecord(rrs, dopts, typeA, t.ID, pID, false); err != nil {
+		if _, err := b.setHostRecords(fmt.Sprintf("\\052.%s", dopts.Fqdn), dopts.Hosts, dopts, t.ID, pID, false); err != nil {
 			return err
 		}
 
-		// set sub domain A record
+		// set sub domain A/AAAA records
 		for k, v := range dopts.SubDomain {
-			rr := make([]*route53.ResourceRecord, 0)
-			for _, h := range v {
-				rr = append(rr, &route53.ResourceRecord{
-					Value: aws.String(h),
-				})
-			}
-
-			rrs := &route53.ResourceRecordSet{
-				Type:            aws.String(typeA),
-				Name:            aws.String(fmt.Sprintf("%s.%s", k, dopts.Fqdn)),
-				ResourceRecords: rr,
-				TTL:             aws.Int64(int64(b.TTL)),
-			}
-
-			if _, err := b.setRecord(rrs, dopts, typeA, t.ID, pID, true); err != nil {
+			if _, err := b.setHostRecords(fmt.Sprintf("%s.%s", k, dopts.Fqdn), v, dopts, t.ID, pID, true); err != nil {
 				return err
 			}
 		}
@@ -826,6 +814,71 @@ func (b *Backend) MigrateRecord(opts *model.MigrateRecord) error {
 	return nil
 }
 
+func (b *Backend) List(opts *model.ListOptions) (model.DomainList, error) {
+	return backend.ListDatabaseDomains(b.ctx, b.Zone, b.LeaseTime, opts, b.inspectToken)
+}
+
+func (b *Backend) Inspect(fqdn string) (d model.DomainInfo, err error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = b.inspectToken(t)
+	if err != nil {
+		return d, err
+	}
+
+	ts, err := b.db().QueryExpiredTXTs(t.ID)
+	if err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errQueryTXTFromDatabase, fqdn))
+	}
+	if len(ts) > 0 {
+		d.Texts = make(map[string]string)
+		for _, r := range ts {
+			d.Texts[r.Fqdn] = strings.Trim(r.Content, "\"")
+		}
+	}
+
+	return d, nil
+}
+
+func (b *Backend) ForceDelete(fqdn string) error {
+	b.log().Debugf("force delete records for fqdn: %s", fqdn)
+
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return err
+	}
+
+	return backend.PurgeDomain(b.ctx, b, t)
+}
+
+func (b *Backend) ForceRenew(fqdn string) (d model.DomainInfo, err error) {
+	b.log().Debugf("force renew records for fqdn: %s", fqdn)
+
+	// the token is renewed by the fqdn of the token, which holds the records of the requested fqdn
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return d, err
+	}
+
+	if _, _, err := b.db().RenewToken(t.Fqdn); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewTokenFromDatabase, t.Fqdn))
+	}
+
+	if err := b.db().RenewFrozen(strings.Split(t.Fqdn, ".")[0]); err != nil {
+		return d, backend.Unavailable(errors.Wrapf(err, errRenewFrozenFromDatabase, t.Fqdn))
+	}
+
+	return b.Inspect(t.Fqdn)
+}
+
+// Watch polls the changes recorded in the database, the backend must be wrapped by backend.WithChanges to record them.
+func (b *Backend) Watch(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error) {
+	return backend.WatchDatabase(ctx, fqdn, revision)
+}
+
 // Used to set record to database
 func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType string, tID, pID int64, sub bool) (int64, error) {
 	content := make([]string, 0)
@@ -842,11 +895,11 @@ func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType stri
 			CreatedOn: time.Now().Unix(),
 		}
 
-		result, _ := database.GetDatabase().QueryA(aws.StringValue(rrs.Name))
+		result, _ := b.db().QueryA(aws.StringValue(rrs.Name))
 		if result != nil && result.Fqdn != "" {
-			return database.GetDatabase().UpdateA(dr)
+			return b.db().UpdateA(dr)
 		}
-		return database.GetDatabase().InsertA(dr)
+		return b.db().InsertA(dr)
 	}
 
 	if rType == typeA && sub {
@@ -858,11 +911,11 @@ func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType stri
 			CreatedOn: time.Now().Unix(),
 		}
 
-		result, _ := database.GetDatabase().QuerySubA(aws.StringValue(rrs.Name))
+		result, _ := b.db().QuerySubA(aws.StringValue(rrs.Name))
 		if result != nil && result.Fqdn != "" {
-			return database.GetDatabase().UpdateSubA(dr)
+			return b.db().UpdateSubA(dr)
 		}
-		return database.GetDatabase().InsertSubA(dr)
+		return b.db().InsertSubA(dr)
 	}
 
 	if rType == typeTXT {
@@ -874,11 +927,11 @@ func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType stri
 			CreatedOn: time.Now().Unix(),
 		}
 
-		result, _ := database.GetDatabase().QueryTXT(aws.StringValue(rrs.Name))
+		result, _ := b.db().QueryTXT(aws.StringValue(rrs.Name))
 		if result != nil && result.Fqdn != "" {
-			return database.GetDatabase().UpdateTXT(dr)
+			return b.db().UpdateTXT(dr)
 		}
-		return database.GetDatabase().InsertTXT(dr)
+		return b.db().InsertTXT(dr)
 	}
 
 	if rType == typeCNAME {
@@ -890,11 +943,11 @@ func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType stri
 			CreatedOn: time.Now().Unix(),
 		}
 
-		result, _ := database.GetDatabase().QueryCNAME(aws.StringValue(rrs.Name))
+		result, _ := b.db().QueryCNAME(aws.StringValue(rrs.Name))
 		if result != nil && result.Fqdn != "" {
-			return database.GetDatabase().UpdateCNAME(dr)
+			return b.db().UpdateCNAME(dr)
 		}
-		return database.GetDatabase().InsertCNAME(dr)
+		return b.db().InsertCNAME(dr)
 	}
 
 	return 0, nil
@@ -903,20 +956,20 @@ func (b *Backend) setRecordToDatabase(rrs *route53.ResourceRecordSet, rType stri
 // Used to delete record from database
 func (b *Backend) deleteRecordFromDatabase(rrs *route53.ResourceRecordSet, rType string, sub bool) error {
 	name := strings.TrimRight(aws.StringValue(rrs.Name), ".")
-	if rType == typeA && !sub {
-		return database.GetDatabase().DeleteA(name)
+	if isHostType(rType) && !sub {
+		return b.db().DeleteA(name)
 	}
 
-	if rType == typeA && sub {
-		return database.GetDatabase().DeleteSubA(name)
+	if isHostType(rType) && sub {
+		return b.db().DeleteSubA(name)
 	}
 
 	if rType == typeTXT {
-		return database.GetDatabase().DeleteTXT(name)
+		return b.db().DeleteTXT(name)
 	}
 
 	if rType == typeCNAME {
-		return database.GetDatabase().DeleteCNAME(name)
+		return b.db().DeleteCNAME(name)
 	}
 
 	return nil
@@ -932,7 +985,7 @@ func (b *Backend) getRecords(opts *model.DomainOptions, rType string) (*route53.
 
 	output, err := b.Svc.ListResourceRecordSets(&input)
 	if err != nil {
-		return output, errors.Wrapf(err, errNoRoute53Record, rType, opts.Fqdn)
+		return output, wrapRoute53Error(err, errNoRoute53Record, rType, opts.Fqdn)
 	}
 
 	return output, nil
@@ -945,38 +998,84 @@ func (b *Backend) getRecords(opts *model.DomainOptions, rType string) (*route53.
 //     pID: reference parent ID
 //     sub: whether is sub domain or not
 func (b *Backend) setRecord(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string, tID, pID int64, sub bool) (int64, error) {
-	if len(rrs.ResourceRecords) >= 1 {
-		input := route53.ChangeResourceRecordSetsInput{
-			HostedZoneId: aws.String(b.ZoneID),
-			ChangeBatch: &route53.ChangeBatch{
-				Changes: []*route53.Change{
-					{
-						Action:            aws.String("UPSERT"),
-						ResourceRecordSet: rrs,
-					},
-				},
-			},
-		}
-
-		if _, err := b.Svc.ChangeResourceRecordSets(&input); err != nil {
-			return 0, errors.Wrapf(err, errUpsertRoute53Record, rType, opts.Fqdn)
-		}
+	if err := b.upsertRoute53Record(rrs, opts, rType); err != nil {
+		return 0, err
 	}
 
 	// set record to database
 	id, err := b.setRecordToDatabase(rrs, rType, tID, pID, sub)
 	if err != nil {
-		return 0, errors.Wrapf(err, errInsertRecordToDatabase, rType, opts.Fqdn)
+		return 0, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, rType, opts.Fqdn))
 	}
 
 	return id, nil
 }
 
+// Used to set A and AAAA records of the hosts, IPv4 hosts are set as A record and IPv6 hosts are set as AAAA record,
+// the database keeps all hosts of the name in one record
+func (b *Backend) setHostRecords(name string, hosts []string, opts *model.DomainOptions, tID, pID int64, sub bool) (int64, error) {
+	v4, v6 := util.SplitHostsByFamily(hosts)
+
+	if err := b.upsertRoute53Record(b.newHostRecordSet(name, typeA, v4), opts, typeA); err != nil {
+		return 0, err
+	}
+	if err := b.upsertRoute53Record(b.newHostRecordSet(name, typeAAAA, v6), opts, typeAAAA); err != nil {
+		return 0, err
+	}
+
+	// set record to database
+	id, err := b.setRecordToDatabase(b.newHostRecordSet(name, typeA, hosts), typeA, tID, pID, sub)
+	if err != nil {
+		return 0, backend.Unavailable(errors.Wrapf(err, errInsertRecordToDatabase, typeA, opts.Fqdn))
+	}
+
+	return id, nil
+}
+
+// Used to upsert route53 record, record set without any value is ignored
+func (b *Backend) upsertRoute53Record(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string) error {
+	if len(rrs.ResourceRecords) < 1 {
+		return nil
+	}
+
+	input := route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(b.ZoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String("UPSERT"),
+					ResourceRecordSet: rrs,
+				},
+			},
+		},
+	}
+
+	if _, err := b.Svc.ChangeResourceRecordSets(&input); err != nil {
+		return wrapRoute53Error(err, errUpsertRoute53Record, rType, opts.Fqdn)
+	}
+
+	return nil
+}
+
 // Used to delete record
 //   parameters:
 //     rType: record's type(0: TXT, 1: A, 2: SUB)
 //     sub: whether is sub domain or not
 func (b *Backend) deleteRecord(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string, sub bool) error {
+	if err := b.deleteRoute53Record(rrs, opts, rType); err != nil {
+		return err
+	}
+
+	// delete record from database
+	if err := b.deleteRecordFromDatabase(rrs, rType, sub); err != nil {
+		return backend.Unavailable(errors.Wrapf(err, errDeleteRecordsFromDatabase, rType, opts.Fqdn))
+	}
+
+	return nil
+}
+
+// Used to delete route53 record only, the database record is kept
+func (b *Backend) deleteRoute53Record(rrs *route53.ResourceRecordSet, opts *model.DomainOptions, rType string) error {
 	input := route53.ChangeResourceRecordSetsInput{
 		HostedZoneId: aws.String(b.ZoneID),
 		ChangeBatch: &route53.ChangeBatch{
@@ -994,12 +1093,7 @@ func (b *Backend) deleteRecord(rrs *route53.ResourceRecordSet, opts *model.Domai
 		},
 	}
 	if _, err := b.Svc.ChangeResourceRecordSets(&input); err != nil {
-		return errors.Wrapf(err, errDeleteRoute53Record, rType, opts.Fqdn)
-	}
-
-	// delete record from database
-	if err := b.deleteRecordFromDatabase(rrs, rType, sub); err != nil {
-		return errors.Wrapf(err, errDeleteRecordsFromDatabase, rType, opts.Fqdn)
+		return wrapRoute53Error(err, errDeleteRoute53Record, rType, opts.Fqdn)
 	}
 
 	return nil
@@ -1009,7 +1103,7 @@ func (b *Backend) deleteRecord(rrs *route53.ResourceRecordSet, opts *model.Domai
 //   TXT records:
 //     valid:
 //       1. Only TXT record which equal to the opts.Fqdn is valid
-//   A records:
+//   A records (AAAA records are filtered together with A records):
 //     valid:
 //       1. wildcard record is valid
 //       2. A record which equal to the opts.Fqdn is valid
@@ -1027,12 +1121,12 @@ func (b *Backend) filterRecords(rrs []*route53.ResourceRecordSet, opts *model.Do
 			name := strings.TrimRight(aws.StringValue(rs.Name), ".")
 			nss := strings.Split(name, ".")
 			oss := strings.Split(opts.Fqdn, ".")
-			if (name == opts.Fqdn || name == fmt.Sprintf("\\052.%s", opts.Fqdn)) && aws.StringValue(rs.Type) == rType {
+			if (name == opts.Fqdn || name == fmt.Sprintf("\\052.%s", opts.Fqdn)) && isHostType(aws.StringValue(rs.Type)) {
 				v = true
 				a = append(a, rs)
 				continue
 			}
-			if (len(nss)-len(oss)) == 1 && strings.Contains(name, opts.Fqdn) && aws.StringValue(rs.Type) == rType && !strings.Contains(name, "\\052") {
+			if (len(nss)-len(oss)) == 1 && strings.Contains(name, opts.Fqdn) && isHostType(aws.StringValue(rs.Type)) && !strings.Contains(name, "\\052") {
 				s = append(s, rs)
 				continue
 			}
@@ -1063,32 +1157,99 @@ func (b *Backend) filterRecords(rrs []*route53.ResourceRecordSet, opts *model.Do
 	}
 }
 
-// Used to convert route53 A & sub domain A records to map
+// Used to convert route53 A/AAAA & sub domain A/AAAA records to map, hosts of the same name are merged
 func (b *Backend) convertARecords(a, s []*route53.ResourceRecordSet) (aOutput, sOutput map[string][]string) {
 	aOutput = make(map[string][]string, 0)
 	sOutput = make(map[string][]string, 0)
 
 	for _, rs := range a {
 		name := strings.TrimRight(aws.StringValue(rs.Name), ".")
-		temp := make([]string, 0)
 		for _, r := range rs.ResourceRecords {
-			temp = append(temp, aws.StringValue(r.Value))
+			aOutput[name] = append(aOutput[name], aws.StringValue(r.Value))
 		}
-		aOutput[name] = temp
 	}
 
 	for _, rs := range s {
 		prefix := strings.Split(aws.StringValue(rs.Name), ".")[0]
-		temp := make([]string, 0)
 		for _, r := range rs.ResourceRecords {
-			temp = append(temp, aws.StringValue(r.Value))
+			sOutput[prefix] = append(sOutput[prefix], aws.StringValue(r.Value))
 		}
-		sOutput[prefix] = temp
 	}
 
 	return
 }
 
+// Used to build A or AAAA record set of the hosts
+func (b *Backend) newHostRecordSet(name, rType string, hosts []string) *route53.ResourceRecordSet {
+	rr := make([]*route53.ResourceRecord, 0)
+	for _, h := range hosts {
+		rr = append(rr, &route53.ResourceRecord{
+			Value: aws.String(h),
+		})
+	}
+
+	return &route53.ResourceRecordSet{
+		Type:            aws.String(rType),
+		Name:            aws.String(name),
+		ResourceRecords: rr,
+		TTL:             aws.Int64(int64(b.TTL)),
+	}
+}
+
+// Used to check whether the record type holds hosts or not
+func isHostType(rType string) bool {
+	return rType == typeA || rType == typeAAAA
+}
+
+// Used to check whether the hosts have any address of the record type
+func hasHostsOfType(hosts []string, rType string) bool {
+	v4, v6 := util.SplitHostsByFamily(hosts)
+	if rType == typeAAAA {
+		return len(v6) > 0
+	}
+	return len(v4) > 0
+}
+
+// Used to allocate a random slug name, the name is valid if it is not frozen and the getter finds no records of it
+func (b *Backend) allocateName(get func(opts *model.DomainOptions) (model.Domain, error)) (string, error) {
+	return slug.Allocate(func(name string) (bool, error) {
+		r, err := b.db().QueryFrozen(name)
+		if err != nil && err != sql.ErrNoRows {
+			return false, err
+		}
+		if r != "" {
+			return false, nil
+		}
+
+		d, err := get(&model.DomainOptions{
+			Fqdn: fmt.Sprintf("%s.%s", name, b.Zone),
+		})
+		return err != nil || d.Fqdn == "", nil
+	})
+}
+
+// Used to check whether the requested name can be used or not, the name is taken if it is frozen or used by a domain
+func (b *Backend) checkName(name string) error {
+	r, err := b.db().QueryFrozen(name)
+	if err != nil && err != sql.ErrNoRows {
+		return backend.Unavailable(err)
+	}
+	if r != "" {
+		return backend.Conflict(errors.Errorf(errExistName, name))
+	}
+
+	fqdn := fmt.Sprintf("%s.%s", name, b.Zone)
+	t, err := b.db().QueryToken(fqdn)
+	if err != nil && err != sql.ErrNoRows {
+		return backend.Unavailable(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+	if err == nil && t.Fqdn != "" {
+		return backend.Conflict(errors.Errorf(errExistName, name))
+	}
+
+	return nil
+}
+
 // Used to find slug name:
 //   e.g. yyyy.xxxx.qrn7oq.lb.rancher.cloud => qrn7oq.lb.rancher.cloud
 func (b *Backend) findSlugWithZone(fqdn string) string {
@@ -1100,14 +1261,86 @@ func (b *Backend) findSlugWithZone(fqdn string) string {
 	return ss[1]
 }
 
-// Used to generate a random slug
-func generateSlug() string {
-	return util.RandStringWithSmall(slugLength)
+// Used to query token from database, the token which is out of lease but not purged yet is expired
+func (b *Backend) queryToken(fqdn string) (*model.Token, error) {
+	t, err := b.findToken(fqdn)
+	if err != nil {
+		return t, err
+	}
+
+	if convertExpiration(time.Unix(0, t.CreatedOn), int(b.LeaseTime.Nanoseconds())).Before(time.Now()) {
+		return t, backend.Expired(errors.Errorf(errExpiredToken, fqdn))
+	}
+
+	return t, nil
+}
+
+// Used to find token from database regardless of the lease
+func (b *Backend) findToken(fqdn string) (*model.Token, error) {
+	t, err := b.db().QueryToken(fqdn)
+	if err == sql.ErrNoRows {
+		return t, backend.NotFound(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+	if err != nil {
+		return t, backend.Unavailable(errors.Wrapf(err, errQueryTokenFromDatabase, fqdn))
+	}
+	return t, nil
+}
+
+// Used to convert the token to the domain with the records kept in database regardless of the lease,
+// the empty A record is the parent of sub domain records and the A record of the fqdn keeps all hosts
+func (b *Backend) inspectToken(t *model.Token) (d model.DomainInfo, err error) {
+	d.Fqdn = t.Fqdn
+	d.Token = model.NewTokenInfo(time.Unix(0, t.CreatedOn), b.LeaseTime)
+
+	emptyName := fmt.Sprintf("%s.%s", "empty", t.Fqdn)
+	if e, err := b.db().QueryA(emptyName); err == nil && e.Fqdn != "" {
+		subs, err := b.db().ListSubA(e.ID)
+		if err != nil {
+			return d, backend.Unavailable(errors.Wrapf(err, errQueryAFromDatabase, emptyName))
+		}
+
+		d.Type = typeA
+		d.SubDomain = make(map[string][]string, 0)
+		for _, sub := range subs {
+			d.SubDomain[strings.Split(sub.Fqdn, ".")[0]] = splitContent(sub.Content)
+		}
+		if a, err := b.db().QueryA(t.Fqdn); err == nil && a.Fqdn != "" {
+			d.Hosts = splitContent(a.Content)
+		}
+	}
+
+	if c, err := b.db().QueryCNAME(t.Fqdn); err == nil && c.Fqdn != "" {
+		d.Type = typeCNAME
+		d.CNAME = c.Content
+	}
+
+	return d, nil
 }
 
-// Used to generate a random token
+// Used to wrap route53 error, the request is invalid if route53 rejects it, others are treated as unavailable
+func wrapRoute53Error(err error, format string, args ...interface{}) error {
+	e := errors.Wrapf(err, format, args...)
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case route53.ErrCodeInvalidChangeBatch, route53.ErrCodeInvalidInput:
+			return backend.Invalid(e)
+		}
+	}
+	return backend.Unavailable(e)
+}
+
+// Used to generate the hashed token of a new domain, the token is replaced when it is responded to the client
 func generateToken() string {
-	return util.RandStringWithAll(tokenLength)
+	return util.HashToken(util.RandStringWithAll(tokenLength))
+}
+
+// Used to split the database content to hosts
+func splitContent(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(content, ",")
 }
 
 // Used to convert expiration
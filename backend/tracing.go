@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"context"
+
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// WithTracing returns the backend tracing each call of b under the request bound by WithContext,
+// the calls made in the background are not traced.
+func WithTracing(b Backend) Backend {
+	return &tracedBackend{b: b}
+}
+
+type tracedBackend struct {
+	b   Backend
+	ctx context.Context
+}
+
+// Used to start the span of the call, b is bound to the span so the calls it makes are traced under it
+func (t *tracedBackend) start(op, fqdn string) (Backend, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("rdns.backend", t.b.GetName())}
+	if fqdn != "" {
+		attrs = append(attrs, attribute.String("rdns.fqdn", fqdn))
+	}
+	ctx, span := tracing.StartChild(t.ctx, "backend."+op, trace.WithAttributes(attrs...))
+	return t.b.WithContext(ctx), span
+}
+
+func (t *tracedBackend) GetName() string {
+	return t.b.GetName()
+}
+
+func (t *tracedBackend) Ping(ctx context.Context) error {
+	return t.b.Ping(ctx)
+}
+
+// Watch is not traced, the watch lasts as long as the stream and the changes are read in the background
+func (t *tracedBackend) Watch(ctx context.Context, fqdn string, revision int64) (<-chan model.WatchChange, error) {
+	return t.b.Watch(ctx, fqdn, revision)
+}
+
+func (t *tracedBackend) WithContext(ctx context.Context) Backend {
+	return &tracedBackend{b: t.b, ctx: ctx}
+}
+
+func (t *tracedBackend) Get(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("Get", opts.Fqdn)
+	v, err := b.Get(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) Set(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("Set", opts.Fqdn)
+	v, err := b.Set(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) Update(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("Update", opts.Fqdn)
+	v, err := b.Update(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) Delete(opts *model.DomainOptions) error {
+	b, span := t.start("Delete", opts.Fqdn)
+	err := b.Delete(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) Renew(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("Renew", opts.Fqdn)
+	v, err := b.Renew(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) SetText(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("SetText", opts.Fqdn)
+	v, err := b.SetText(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) GetText(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("GetText", opts.Fqdn)
+	v, err := b.GetText(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) UpdateText(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("UpdateText", opts.Fqdn)
+	v, err := b.UpdateText(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) DeleteText(opts *model.DomainOptions) error {
+	b, span := t.start("DeleteText", opts.Fqdn)
+	err := b.DeleteText(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) SetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("SetCNAME", opts.Fqdn)
+	v, err := b.SetCNAME(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) GetCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("GetCNAME", opts.Fqdn)
+	v, err := b.GetCNAME(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) UpdateCNAME(opts *model.DomainOptions) (model.Domain, error) {
+	b, span := t.start("UpdateCNAME", opts.Fqdn)
+	v, err := b.UpdateCNAME(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) DeleteCNAME(opts *model.DomainOptions) error {
+	b, span := t.start("DeleteCNAME", opts.Fqdn)
+	err := b.DeleteCNAME(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) GetToken(fqdn string) (string, error) {
+	b, span := t.start("GetToken", fqdn)
+	v, err := b.GetToken(fqdn)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) GetTokenCount() (int64, error) {
+	b, span := t.start("GetTokenCount", "")
+	v, err := b.GetTokenCount()
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) UpdateToken(fqdn, token string) error {
+	b, span := t.start("UpdateToken", fqdn)
+	err := b.UpdateToken(fqdn, token)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) SetSubToken(fqdn string, st *model.SubToken) error {
+	b, span := t.start("SetSubToken", fqdn)
+	err := b.SetSubToken(fqdn, st)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) GetSubTokens(fqdn string) ([]*model.SubToken, error) {
+	b, span := t.start("GetSubTokens", fqdn)
+	v, err := b.GetSubTokens(fqdn)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) DeleteSubToken(fqdn, sid string) error {
+	b, span := t.start("DeleteSubToken", fqdn)
+	err := b.DeleteSubToken(fqdn, sid)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) MigrateFrozen(opts *model.MigrateFrozen) error {
+	b, span := t.start("MigrateFrozen", "")
+	err := b.MigrateFrozen(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) MigrateToken(opts *model.MigrateToken) error {
+	b, span := t.start("MigrateToken", "")
+	err := b.MigrateToken(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) MigrateRecord(opts *model.MigrateRecord) error {
+	b, span := t.start("MigrateRecord", "")
+	err := b.MigrateRecord(opts)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) List(opts *model.ListOptions) (model.DomainList, error) {
+	b, span := t.start("List", "")
+	v, err := b.List(opts)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) Inspect(fqdn string) (model.DomainInfo, error) {
+	b, span := t.start("Inspect", fqdn)
+	v, err := b.Inspect(fqdn)
+	tracing.End(span, err)
+	return v, err
+}
+
+func (t *tracedBackend) ForceDelete(fqdn string) error {
+	b, span := t.start("ForceDelete", fqdn)
+	err := b.ForceDelete(fqdn)
+	tracing.End(span, err)
+	return err
+}
+
+func (t *tracedBackend) ForceRenew(fqdn string) (model.DomainInfo, error) {
+	b, span := t.start("ForceRenew", fqdn)
+	v, err := b.ForceRenew(fqdn)
+	tracing.End(span, err)
+	return v, err
+}
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Zone is a DNS zone served by the server, each zone has its own backend and lease time.
+type Zone struct {
+	Name      string
+	LeaseTime time.Duration
+	Backend   Backend
+}
+
+// ZoneOptions is the per-zone settings parsed from the zone flag, the name is the domain or the route53 hosted zone ID.
+type ZoneOptions struct {
+	Name      string
+	LeaseTime time.Duration
+	TTL       int64
+}
+
+// zones is filled at startup before serving requests and only read afterwards, the first zone is the default zone.
+var zones = make([]*Zone, 0)
+
+// AddZone registers the backend to serve the zone.
+func AddZone(z *Zone) error {
+	z.Name = normalizeName(z.Name)
+	if z.Name == "" {
+		return errors.New("zone name must not be empty")
+	}
+	for _, e := range zones {
+		if e.Name == z.Name {
+			return errors.Errorf("zone %s is already served", z.Name)
+		}
+	}
+	zones = append(zones, z)
+	return nil
+}
+
+// GetZone returns the zone of the name, the default zone is returned if the name is empty.
+func GetZone(name string) (*Zone, error) {
+	if len(zones) == 0 {
+		return nil, Unavailable(errors.New("not found any zone"))
+	}
+	if name == "" {
+		return zones[0], nil
+	}
+
+	name = normalizeName(name)
+	for _, z := range zones {
+		if z.Name == name {
+			return z, nil
+		}
+	}
+	return nil, Invalid(errors.Errorf("zone %s is not served", name))
+}
+
+// MatchZone returns the zone which the fqdn belongs to, the longest zone wins if zones are nested.
+func MatchZone(fqdn string) (*Zone, error) {
+	fqdn = normalizeName(fqdn)
+
+	var match *Zone
+	for _, z := range zones {
+		if fqdn != z.Name && !strings.HasSuffix(fqdn, "."+z.Name) {
+			continue
+		}
+		if match == nil || len(z.Name) > len(match.Name) {
+			match = z
+		}
+	}
+	if match == nil {
+		return nil, NotFound(errors.Errorf("no zone serves %s", fqdn))
+	}
+	return match, nil
+}
+
+// Domain returns the domain holding the fqdn in the zone, the records under a domain (e.g. TXT records) are held by it.
+// e.g. _acme-challenge.sample.lb.rancher.cloud => sample.lb.rancher.cloud
+func (z *Zone) Domain(fqdn string) string {
+	fqdnLen := len(strings.Split(fqdn, "."))
+	rootDomainLen := len(strings.Split(z.Name, "."))
+	diffLen := fqdnLen - rootDomainLen
+	if diffLen > 1 {
+		sp := strings.SplitAfterN(fqdn, ".", diffLen)
+		fqdn = sp[len(sp)-1]
+	}
+	return fqdn
+}
+
+// GetZones returns all served zones, the default zone is the first one.
+func GetZones() []*Zone {
+	return zones
+}
+
+// ParseZoneOptions parses the zone flag, zones are separated by commas and each zone may override the defaults
+// e.g. "lb.rancher.cloud,lb.example.com;lease_time=48h;ttl=30"
+func ParseZoneOptions(value, leaseTime, ttl string) ([]*ZoneOptions, error) {
+	result := make([]*ZoneOptions, 0)
+	for _, entry := range strings.Split(value, ",") {
+		ss := strings.Split(strings.TrimSpace(entry), ";")
+		if ss[0] == "" {
+			return result, errors.Errorf("zone name must not be empty: %q", entry)
+		}
+
+		settings := map[string]string{
+			"lease_time": leaseTime,
+			"ttl":        ttl,
+		}
+		for _, s := range ss[1:] {
+			kv := strings.SplitN(s, "=", 2)
+			if _, ok := settings[kv[0]]; !ok || len(kv) != 2 {
+				return result, errors.Errorf("invalid setting %q of zone %s, expected lease_time=<duration> or ttl=<seconds>", s, ss[0])
+			}
+			settings[kv[0]] = kv[1]
+		}
+
+		opts := &ZoneOptions{
+			Name: ss[0],
+		}
+		d, err := time.ParseDuration(settings["lease_time"])
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to parse lease_time of zone %s", ss[0])
+		}
+		opts.LeaseTime = d
+		if settings["ttl"] != "" {
+			t, err := strconv.ParseInt(settings["ttl"], 10, 64)
+			if err != nil {
+				return result, errors.Wrapf(err, "failed to parse ttl of zone %s", ss[0])
+			}
+			opts.TTL = t
+		}
+
+		result = append(result, opts)
+	}
+	return result, nil
+}
+
+// MinLeaseTime returns the shortest lease time of all zones.
+func MinLeaseTime() time.Duration {
+	var min time.Duration
+	for i, z := range zones {
+		if i == 0 || z.LeaseTime < min {
+			min = z.LeaseTime
+		}
+	}
+	return min
+}
+
+// Used to compare names regardless of case and the trailing dot
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(name), "."))
+}
@@ -1,19 +1,24 @@
 package etcdv3
 
 import (
-	"net/http"
+	"context"
 	"os"
 	"strconv"
 	"strings"
 	"text/template"
 
+	"github.com/rancher/rdns-server/audit"
 	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/backend/etcdv3"
 	"github.com/rancher/rdns-server/coredns"
+	"github.com/rancher/rdns-server/lifecycle"
 	"github.com/rancher/rdns-server/metric"
 	"github.com/rancher/rdns-server/model"
 	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/webhook"
 
+	"github.com/coreos/etcd/clientv3"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
@@ -21,7 +26,7 @@ import (
 
 var (
 	flags = map[string]map[string]string{
-		"DOMAIN":           {"used to set etcd root domain.": "lb.rancher.cloud"},
+		"DOMAIN":           {"used to set etcd root domains separated by commas, a domain may override lease time and ttl (e.g. lb.rancher.cloud,lb.example.com;lease_time=48h;ttl=30).": "lb.rancher.cloud"},
 		"ETCD_ENDPOINTS":   {"used to set etcd endpoints.": "http://127.0.0.1:2379"},
 		"ETCD_PREFIX_PATH": {"used to set etcd prefix path.": "/rdnsv3"},
 		"ETCD_LEASE_TIME":  {"used to set etcd lease time.": "240h"},
@@ -55,36 +60,62 @@ func Action(c *cli.Context) error {
 		return errors.Wrapf(err, "failed to set environments")
 	}
 
-	b, err := setBackend()
+	// etcd backend keeps no database
+	if audit.UsesDatabase() {
+		return errors.New("audit sink database is not supported by etcdv3 backend, please use stdout or file")
+	}
+
+	// components are stopped in the reverse order, the etcd client is closed after the requests are done
+	m := lifecycle.NewManager(c.GlobalDuration("shutdown_timeout"))
+	defer m.Stop()
+	m.OnStop("tracer", tracing.Shutdown)
+
+	client, zs, err := setBackend()
 	if err != nil {
 		return err
 	}
+	m.OnStop("etcd-v3 client", func(context.Context) error {
+		return client.Close()
+	})
+	m.OnStop("audit sink", func(context.Context) error {
+		audit.SetSink(nil)
+		return nil
+	})
+
+	// the deliveries are kept in etcd, domains expire by the leases so there are no expiring and purged events
+	if webhook.Enabled() {
+		webhook.SetQueue(etcdv3.NewWebhookQueue(client))
+		wd := webhook.StartDispatcher()
+		m.OnStop("webhook dispatcher", wd.Stop)
+	}
 
-	defer func() {
-		if err := b.C.Close(); err != nil {
-			logrus.Fatalf("failed to close etcd-v3 client: %v", err)
-		}
-	}()
-
-	if err := generateCoreFile(); err != nil {
+	if err := generateCoreFile(zs); err != nil {
 		return err
 	}
 
 	done := make(chan struct{})
+	go metric.StartMetricDaemon(done, backend.GetBackend().GetTokenCount)
+	m.OnStop("metric daemon", func(context.Context) error {
+		close(done)
+		return nil
+	})
 
-	go metric.StartMetricDaemon(done)
-
-	go coredns.StartCoreDNSDaemon()
-
-	go func() {
-		if err := http.ListenAndServe(c.GlobalString("listen"), service.NewRouter()); err != nil {
-			logrus.Error(err)
-			done <- struct{}{}
-		}
-	}()
-
-	<-done
-	return nil
+	instance, err := coredns.StartCoreDNS()
+	if err != nil {
+		return errors.Wrapf(err, "failed to start coredns")
+	}
+	m.OnStop("coredns", func(context.Context) error {
+		return coredns.StopCoreDNS(instance)
+	})
+	service.AddReadinessCheck("coredns", func(ctx context.Context) error {
+		return coredns.PingCoreDNS(ctx, instance)
+	})
+
+	s := service.NewServer(c.GlobalString("listen"))
+	m.Go("api server", s.ListenAndServe)
+	m.OnStop("api server", s.Shutdown)
+
+	return m.Wait()
 }
 
 func setEnvironments(c *cli.Context) error {
@@ -107,17 +138,33 @@ func setEnvironments(c *cli.Context) error {
 	return os.Setenv("FROZEN", c.GlobalString("frozen"))
 }
 
-func setBackend() (*etcdv3.Backend, error) {
-	b, err := etcdv3.NewBackend()
+func setBackend() (*clientv3.Client, []*backend.ZoneOptions, error) {
+	zs, err := backend.ParseZoneOptions(os.Getenv("DOMAIN"), os.Getenv("ETCD_LEASE_TIME"), os.Getenv("TTL"))
+	if err != nil {
+		return nil, zs, err
+	}
+
+	c, err := etcdv3.NewClient()
 	if err != nil {
-		return b, err
+		return nil, zs, err
 	}
-	backend.SetBackend(b)
 
-	return b, nil
+	for _, opts := range zs {
+		b, err := etcdv3.NewBackend(c, opts)
+		if err != nil {
+			c.Close()
+			return nil, zs, err
+		}
+		if err := backend.AddZone(&backend.Zone{Name: b.Domain, LeaseTime: b.LeaseTime, Backend: backend.WithTracing(b)}); err != nil {
+			c.Close()
+			return nil, zs, err
+		}
+	}
+
+	return c, zs, nil
 }
 
-func generateCoreFile() error {
+func generateCoreFile(zs []*backend.ZoneOptions) error {
 	fp := os.Getenv("CORE_DNS_FILE")
 	if fp == "" {
 		return errors.New("failed to get core dns file")
@@ -128,11 +175,16 @@ func generateCoreFile() error {
 		cf := &model.CoreFile{
 			CoreDNSDBFile:  os.Getenv("CORE_DNS_DB_FILE"),
 			CoreDNSDBZone:  os.Getenv("CORE_DNS_DB_ZONE"),
-			Domain:         os.Getenv("DOMAIN"),
 			EtcdPrefixPath: os.Getenv("ETCD_PREFIX_PATH"),
 			EtcdEndpoints:  strings.Join(strings.Split(os.Getenv("ETCD_ENDPOINTS"), ","), " "),
-			TTL:            os.Getenv("TTL"),
-			WildCardBound:  strconv.Itoa(len(strings.Split(strings.TrimRight(os.Getenv("DOMAIN"), "."), ".")) + 1),
+		}
+		for _, opts := range zs {
+			domain := strings.ToLower(strings.TrimRight(opts.Name, "."))
+			cf.Zones = append(cf.Zones, model.CoreFileZone{
+				Domain:        domain,
+				TTL:           strconv.FormatInt(opts.TTL, 10),
+				WildCardBound: strconv.Itoa(len(strings.Split(domain, ".")) + 1),
+			})
 		}
 		p := template.Must(template.New("corefile-tmpl").Parse(model.CoreFileTmpl))
 		f, err := os.OpenFile(fp, os.O_WRONLY|os.O_CREATE, os.ModePerm)
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/rancher/rdns-server/audit"
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/memory"
+	"github.com/rancher/rdns-server/database"
+	memorydb "github.com/rancher/rdns-server/database/memory"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/purge"
+	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/webhook"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var (
+	flags = map[string]map[string]string{
+		"DOMAIN":              {"used to set root domains separated by commas, a domain may override lease time (e.g. lb.rancher.cloud,lb.example.com;lease_time=48h).": "lb.rancher.cloud"},
+		"DATABASE_LEASE_TIME": {"used to set database lease time.": "240h"},
+	}
+)
+
+func Flags() []cli.Flag {
+	fgs := make([]cli.Flag, 0)
+	for key, value := range flags {
+		for k, v := range value {
+			f := cli.StringFlag{
+				Name:   strings.ToLower(key),
+				EnvVar: key,
+				Usage:  k,
+				Value:  v,
+			}
+			fgs = append(fgs, f)
+		}
+	}
+	return fgs
+}
+
+func Action(c *cli.Context) error {
+	if err := setEnvironments(c); err != nil {
+		return errors.Wrapf(err, "failed to set environments")
+	}
+
+	m := lifecycle.NewManager(c.GlobalDuration("shutdown_timeout"))
+	defer m.Stop()
+	m.OnStop("tracer", tracing.Shutdown)
+
+	d := setDatabase()
+	m.OnStop("database", func(context.Context) error {
+		return d.Close()
+	})
+	service.AddReadinessCheck("database", d.Ping)
+	m.OnStop("audit sink", func(context.Context) error {
+		audit.SetSink(nil)
+		return nil
+	})
+
+	if err := setBackend(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go metric.StartMetricDaemon(done, backend.GetBackend().GetTokenCount)
+	m.OnStop("metric daemon", func(context.Context) error {
+		close(done)
+		return nil
+	})
+
+	// the purger queues events, so it is stopped before the dispatcher
+	if webhook.Enabled() {
+		wd := webhook.StartDispatcher()
+		m.OnStop("webhook dispatcher", wd.Stop)
+	}
+
+	p := purge.StartPurgerDaemon()
+	m.OnStop("purger", p.Stop)
+
+	s := service.NewServer(c.GlobalString("listen"))
+	m.Go("api server", s.ListenAndServe)
+	m.OnStop("api server", s.Shutdown)
+
+	return m.Wait()
+}
+
+func setEnvironments(c *cli.Context) error {
+	if c.GlobalBool("debug") {
+		logrus.SetLevel(logrus.DebugLevel)
+	}
+
+	for k := range flags {
+		if err := os.Setenv(k, c.String(strings.ToLower(k))); err != nil {
+			return err
+		}
+		if os.Getenv(k) == "" {
+			return errors.Errorf("expected argument: %s", strings.ToLower(k))
+		}
+	}
+
+	return os.Setenv("FROZEN", c.GlobalString("frozen"))
+}
+
+func setDatabase() *memorydb.Database {
+	d := memorydb.NewDatabase()
+	database.SetDatabase(database.WithMetrics(d, memorydb.DriverName))
+
+	return d
+}
+
+func setBackend() error {
+	zs, err := backend.ParseZoneOptions(os.Getenv("DOMAIN"), os.Getenv("DATABASE_LEASE_TIME"), "")
+	if err != nil {
+		return err
+	}
+
+	for _, opts := range zs {
+		b, err := memory.NewBackend(opts)
+		if err != nil {
+			return err
+		}
+		if err := backend.AddZone(&backend.Zone{Name: b.Zone, LeaseTime: b.LeaseTime, Backend: backend.WithTracing(backend.WithChanges(b))}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/database/migrations"
+	"github.com/rancher/rdns-server/database/mysql"
+	"github.com/rancher/rdns-server/database/postgres"
+	"github.com/rancher/rdns-server/database/sqlite"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var (
+	flags = map[string]map[string]string{
+		"DATABASE": {"used to set database driver (mysql, postgres or sqlite).": "mysql"},
+		"DSN":      {"used to set database dsn.": ""},
+	}
+)
+
+func Flags() []cli.Flag {
+	fgs := make([]cli.Flag, 0)
+	for key, value := range flags {
+		for k, v := range value {
+			f := cli.StringFlag{
+				Name:   strings.ToLower(key),
+				EnvVar: key,
+				Usage:  k,
+				Value:  v,
+			}
+			fgs = append(fgs, f)
+		}
+	}
+	return fgs
+}
+
+func Subcommands() []cli.Command {
+	limit := cli.IntFlag{
+		Name:  "limit",
+		Usage: "used to set the max number of migrations to apply or roll back, 0 means no limit.",
+	}
+
+	return []cli.Command{
+		{
+			Name:   "up",
+			Usage:  "apply pending migrations",
+			Flags:  append(Flags(), limit),
+			Action: Up,
+		},
+		{
+			Name:   "down",
+			Usage:  "roll back applied migrations",
+			Flags:  append(Flags(), cli.IntFlag{Name: limit.Name, Usage: limit.Usage, Value: 1}),
+			Action: Down,
+		},
+		{
+			Name:   "status",
+			Usage:  "show migration status",
+			Flags:  Flags(),
+			Action: Status,
+		},
+	}
+}
+
+func Up(c *cli.Context) error {
+	d, db, err := NewDatabase(c.String("database"), c.String("dsn"))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	n, err := migrations.Up(db, c.String("database"), c.Int("limit"))
+	if err != nil {
+		return err
+	}
+	logrus.Infof("applied %d migrations", n)
+
+	return nil
+}
+
+func Down(c *cli.Context) error {
+	d, db, err := NewDatabase(c.String("database"), c.String("dsn"))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	n, err := migrations.Down(db, c.String("database"), c.Int("limit"))
+	if err != nil {
+		return err
+	}
+	logrus.Infof("rolled back %d migrations", n)
+
+	return nil
+}
+
+func Status(c *cli.Context) error {
+	d, db, err := NewDatabase(c.String("database"), c.String("dsn"))
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ss, err := migrations.GetStatus(db, c.String("database"))
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.App.Writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED")
+	for _, s := range ss {
+		applied := "no"
+		if s.AppliedOn > 0 {
+			applied = time.Unix(0, s.AppliedOn).UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", s.ID, applied)
+	}
+
+	return w.Flush()
+}
+
+// NewDatabase opens the sql database of the driver, the underlying connection pool is returned for migrations.
+func NewDatabase(driver, dsn string) (database.Database, *sql.DB, error) {
+	if dsn == "" {
+		return nil, nil, errors.New("expected argument: dsn")
+	}
+
+	switch driver {
+	case mysql.DriverName:
+		d, err := mysql.NewDatabase(dsn)
+		return d, d.Db, err
+	case postgres.DriverName:
+		d, err := postgres.NewDatabase(dsn)
+		return d, d.Db, err
+	case sqlite.DriverName:
+		d, err := sqlite.NewDatabase(dsn)
+		return d, d.Db, err
+	}
+
+	return nil, nil, errors.New("no suitable database found")
+}
@@ -1,17 +1,22 @@
 package route53
 
 import (
-	"net/http"
+	"context"
 	"os"
 	"strings"
 
+	"github.com/rancher/rdns-server/audit"
 	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/backend/route53"
+	"github.com/rancher/rdns-server/command/migrate"
 	"github.com/rancher/rdns-server/database"
-	"github.com/rancher/rdns-server/database/mysql"
+	"github.com/rancher/rdns-server/database/migrations"
+	"github.com/rancher/rdns-server/lifecycle"
 	"github.com/rancher/rdns-server/metric"
 	"github.com/rancher/rdns-server/purge"
 	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/webhook"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -20,10 +25,10 @@ import (
 
 var (
 	flags = map[string]map[string]string{
-		"AWS_HOSTED_ZONE_ID":    {"used to set aws hosted zone ID.": ""},
+		"AWS_HOSTED_ZONE_ID":    {"used to set aws hosted zone IDs separated by commas, a zone may override lease time and ttl (e.g. Z1,Z2;lease_time=48h;ttl=30).": ""},
 		"AWS_ACCESS_KEY_ID":     {"used to set aws access key ID.": ""},
 		"AWS_SECRET_ACCESS_KEY": {"used to set aws secret access key.": ""},
-		"DATABASE":              {"used to set database driver.": "mysql"},
+		"DATABASE":              {"used to set database driver (mysql, postgres or sqlite).": "mysql"},
 		"DATABASE_LEASE_TIME":   {"used to set database lease time.": "240h"},
 		"DSN":                   {"used to set database dsn.": ""},
 		"TTL":                   {"used to set route53 ttl.": "10"},
@@ -43,6 +48,11 @@ func Flags() []cli.Flag {
 			fgs = append(fgs, f)
 		}
 	}
+	fgs = append(fgs, cli.BoolFlag{
+		Name:   "skip_migrations",
+		EnvVar: "SKIP_MIGRATIONS",
+		Usage:  "used to skip applying pending database migrations at startup.",
+	})
 	return fgs
 }
 
@@ -51,31 +61,49 @@ func Action(c *cli.Context) error {
 		return errors.Wrapf(err, "failed to set environments")
 	}
 
+	// components are stopped in the reverse order, the database is closed after the requests and the purger are done
+	m := lifecycle.NewManager(c.GlobalDuration("shutdown_timeout"))
+	defer m.Stop()
+	m.OnStop("tracer", tracing.Shutdown)
+
 	d, err := setDatabase(c)
 	if err != nil {
 		return err
 	}
-	defer d.Close()
+	m.OnStop("database", func(context.Context) error {
+		return d.Close()
+	})
+	service.AddReadinessCheck("database", d.Ping)
+	m.OnStop("audit sink", func(context.Context) error {
+		audit.SetSink(nil)
+		return nil
+	})
 
 	if err := setBackend(); err != nil {
 		return err
 	}
 
 	done := make(chan struct{})
+	go metric.StartMetricDaemon(done, backend.GetBackend().GetTokenCount)
+	m.OnStop("metric daemon", func(context.Context) error {
+		close(done)
+		return nil
+	})
+
+	// the purger queues events, so it is stopped before the dispatcher
+	if webhook.Enabled() {
+		wd := webhook.StartDispatcher()
+		m.OnStop("webhook dispatcher", wd.Stop)
+	}
 
-	go metric.StartMetricDaemon(done)
-
-	go purge.StartPurgerDaemon(done)
+	p := purge.StartPurgerDaemon()
+	m.OnStop("purger", p.Stop)
 
-	go func() {
-		if err := http.ListenAndServe(c.GlobalString("listen"), service.NewRouter()); err != nil {
-			logrus.Error(err)
-			done <- struct{}{}
-		}
-	}()
+	s := service.NewServer(c.GlobalString("listen"))
+	m.Go("api server", s.ListenAndServe)
+	m.OnStop("api server", s.Shutdown)
 
-	<-done
-	return nil
+	return m.Wait()
 }
 
 func setEnvironments(c *cli.Context) error {
@@ -95,27 +123,41 @@ func setEnvironments(c *cli.Context) error {
 	return os.Setenv("FROZEN", c.GlobalString("frozen"))
 }
 
-func setDatabase(c *cli.Context) (d *mysql.Database, err error) {
-	switch c.String("database") {
-	case mysql.DriverName:
-		d, err = mysql.NewDatabase(c.String("dsn"))
+func setDatabase(c *cli.Context) (database.Database, error) {
+	d, db, err := migrate.NewDatabase(c.String("database"), c.String("dsn"))
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.Bool("skip_migrations") {
+		n, err := migrations.Up(db, c.String("database"), 0)
 		if err != nil {
+			d.Close()
 			return nil, err
 		}
-		database.SetDatabase(d)
-	default:
-		return nil, errors.New("no suitable database found")
+		logrus.Infof("applied %d migrations", n)
 	}
 
+	database.SetDatabase(database.WithMetrics(d, c.String("database")))
+
 	return d, nil
 }
 
 func setBackend() error {
-	b, err := route53.NewBackend()
+	zs, err := backend.ParseZoneOptions(os.Getenv("AWS_HOSTED_ZONE_ID"), os.Getenv("DATABASE_LEASE_TIME"), os.Getenv("TTL"))
 	if err != nil {
 		return err
 	}
-	backend.SetBackend(b)
+
+	for _, opts := range zs {
+		b, err := route53.NewBackend(opts)
+		if err != nil {
+			return err
+		}
+		if err := backend.AddZone(&backend.Zone{Name: b.Zone, LeaseTime: b.LeaseTime, Backend: backend.WithTracing(backend.WithChanges(b))}); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
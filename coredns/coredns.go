@@ -1,8 +1,10 @@
 package coredns
 
 import (
+	"context"
 	"flag"
 	"io/ioutil"
+	"net"
 	"os"
 	"runtime"
 	"strconv"
@@ -41,7 +43,9 @@ func init() {
 	dnsserver.Directives = plugin.Directives
 }
 
-func StartCoreDNSDaemon() {
+// StartCoreDNS starts the CoreDNS instance of the Corefile, signals are handled by the caller instead of caddy
+// so the instance is stopped in order with the others.
+func StartCoreDNS() (*caddy.Instance, error) {
 	prepareFlags()
 
 	caddy.RegisterCaddyfileLoader("flag", caddy.LoaderFunc(confLoader))
@@ -52,23 +56,47 @@ func StartCoreDNSDaemon() {
 		Action:     rdns.Setup,
 	})
 
-	caddy.TrapSignals()
-
 	if err := setCPU(cpu); err != nil {
-		logrus.Fatal(err)
+		return nil, err
 	}
 
 	f, err := caddy.LoadCaddyfile(CoreType)
 	if err != nil {
-		logrus.Fatal(err)
+		return nil, err
 	}
 
-	instance, err := caddy.Start(f)
-	if err != nil {
-		logrus.Fatal(err)
+	return caddy.Start(f)
+}
+
+// StopCoreDNS stops the servers of the instance and runs its shutdown callbacks.
+func StopCoreDNS(instance *caddy.Instance) error {
+	err := instance.Stop()
+	for _, e := range instance.ShutdownCallbacks() {
+		logrus.Error(e)
 	}
+	return err
+}
 
-	instance.Wait()
+// PingCoreDNS reports whether the TCP listeners of the instance accept connections.
+func PingCoreDNS(ctx context.Context, instance *caddy.Instance) error {
+	n := 0
+	for _, s := range instance.Servers() {
+		addr := s.Addr()
+		if addr == nil {
+			continue
+		}
+		var d net.Dialer
+		c, err := d.DialContext(ctx, addr.Network(), addr.String())
+		if err != nil {
+			return errors.Wrapf(err, "failed to connect coredns listener %s", addr)
+		}
+		c.Close()
+		n++
+	}
+	if n == 0 {
+		return errors.New("coredns has no listener")
+	}
+	return nil
 }
 
 func confLoader(serverType string) (caddy.Input, error) {
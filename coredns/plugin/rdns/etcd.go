@@ -92,7 +92,9 @@ func (e *ETCD) Records(ctx context.Context, state request.Request, exact bool) (
 	}
 
 	path, star := msg.PathWithWildcard(name, e.PathPrefix)
-	r, err := e.get(ctx, path, !exact)
+	// Wildcard names are only matched by the children of the path, so an exact
+	// lookup (e.g. CNAME) must be recursive as well to find the CNAME child record.
+	r, err := e.get(ctx, path, !exact || star)
 	if err != nil {
 		return nil, err
 	}
@@ -4,15 +4,31 @@ import (
 	"context"
 
 	"github.com/rancher/rdns-server/coredns/plugin"
+	"github.com/rancher/rdns-server/tracing"
 
 	"github.com/coredns/coredns/request"
 	"github.com/miekg/dns"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ServeDNS implements the plugin.Handler interface.
 func (e *ETCD) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	opt := plugin.Options{}
 	state := request.Request{W: w, Req: r}
+	ctx, span := tracing.Start(ctx, "rdns.ServeDNS",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("dns.question.name", state.Name()), attribute.String("dns.question.type", state.Type())))
+
+	rcode, err := e.serveDNS(ctx, state)
+	span.SetAttributes(attribute.String("dns.response.rcode", dns.RcodeToString[rcode]))
+	tracing.End(span, err)
+	return rcode, err
+}
+
+// Used to look up the records of the request
+func (e *ETCD) serveDNS(ctx context.Context, state request.Request) (int, error) {
+	opt := plugin.Options{}
+	w, r := state.W, state.Req
 
 	zone := plugin.Zones(e.Zones).Matches(state.Name())
 	if zone == "" {
@@ -4,6 +4,8 @@ import (
 	"crypto/tls"
 	"strconv"
 
+	"github.com/rancher/rdns-server/tracing"
+
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
@@ -11,6 +13,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 	etcdcv3 "github.com/coreos/etcd/clientv3"
 	"github.com/mholt/caddy"
+	"google.golang.org/grpc"
 )
 
 var log = clog.NewWithPlugin("rdns")
@@ -120,6 +123,8 @@ func newEtcdClient(endpoints []string, cc *tls.Config, username, password string
 	etcdCfg := etcdcv3.Config{
 		Endpoints: endpoints,
 		TLS:       cc,
+		// the lookups are traced under the span of ServeDNS
+		DialOptions: []grpc.DialOption{grpc.WithUnaryInterceptor(tracing.UnaryClientInterceptor)},
 	}
 	if username != "" && password != "" {
 		etcdCfg.Username = username
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"time"
 
 	"github.com/rancher/rdns-server/model"
@@ -21,9 +22,15 @@ type Database interface {
 	QueryTokenCount() (int64, error)
 	QueryToken(name string) (*model.Token, error)
 	QueryExpiredTokens(*time.Time) ([]*model.Token, error)
+	// ListTokens pages tokens by ID, createdBefore is not limited if it is nil
+	ListTokens(suffix string, createdBefore *time.Time, afterID int64, limit int) ([]*model.Token, error)
 	RenewToken(name string) (int64, int64, error)
+	UpdateToken(token, name string) error
 	DeleteToken(prefix string) error
 	MigrateToken(token, name string, expiration int64) error
+	InsertSubToken(*model.SubToken) (int64, error)
+	ListSubTokens(id int64) ([]*model.SubToken, error)
+	DeleteSubToken(sid string) error
 	InsertA(*model.RecordA) (int64, error)
 	UpdateA(*model.RecordA) (int64, error)
 	QueryA(name string) (*model.RecordA, error)
@@ -42,6 +49,27 @@ type Database interface {
 	QueryTXT(name string) (*model.RecordTXT, error)
 	QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error)
 	DeleteTXT(name string) error
+	InsertAuditLog(*model.AuditLog) (int64, error)
+	// ListAuditLogs returns the newest logs of the fqdn first, since and until are not limited if they are nil
+	ListAuditLogs(fqdn string, since, until *time.Time, limit int) ([]*model.AuditLog, error)
+	InsertWebhookDelivery(*model.WebhookDelivery) (int64, error)
+	// ListDueWebhookDeliveries returns the deliveries whose next attempt is due, the earliest first
+	ListDueWebhookDeliveries(due *time.Time, limit int) ([]*model.WebhookDelivery, error)
+	// ClaimWebhookDelivery moves the next attempt of the delivery to until, false is returned if another server claimed it first
+	ClaimWebhookDelivery(d *model.WebhookDelivery, until int64) (bool, error)
+	UpdateWebhookDelivery(*model.WebhookDelivery) error
+	DeleteWebhookDelivery(id int64) error
+	InsertDomainChange(*model.DomainChange) (int64, error)
+	// ListDomainChanges pages the changes of the fqdn by ID, the changes of all domains are listed if the fqdn is empty
+	ListDomainChanges(fqdn string, afterID int64, limit int) ([]*model.DomainChange, error)
+	// QueryDomainChangeRange returns the IDs of the oldest and the newest changes, both are 0 if there is no change
+	QueryDomainChangeRange() (int64, int64, error)
+	// DeleteExpiredDomainChanges deletes the changes created before the time, the newest change is kept so its ID is never reused
+	DeleteExpiredDomainChanges(*time.Time) error
+	// Ping checks whether the database is reachable, it is used by the readiness check
+	Ping(ctx context.Context) error
+	// WithContext returns a copy of the database bound to the context of a request, its statements may be traced under the request
+	WithContext(ctx context.Context) Database
 	Close() error
 }
 
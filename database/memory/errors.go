@@ -0,0 +1,5 @@
+package memory
+
+const (
+	errDuplicateEntry = "duplicate entry %s for %s"
+)
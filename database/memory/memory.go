@@ -0,0 +1,771 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DriverName = "memory"
+)
+
+// Database keeps the same tables as the mysql schema in maps, it is used for tests and local development.
+// Records referencing a token or a parent A record are deleted with them (ON DELETE CASCADE),
+// expired tokens and frozen prefixes are found by their created_on just like the mysql driver.
+type Database struct {
+	lock   sync.RWMutex
+	lastID int64
+
+	frozen map[string]*model.FrozenPrefix
+	tokens map[int64]*model.Token
+	as     map[int64]*model.RecordA
+	subAs  map[int64]*model.SubRecordA
+	cnames map[int64]*model.RecordCNAME
+	txts   map[int64]*model.RecordTXT
+
+	subTokens map[int64]*model.SubToken
+	auditLogs []*model.AuditLog
+	webhooks  map[int64]*model.WebhookDelivery
+	changes   []*model.DomainChange
+}
+
+func NewDatabase() *Database {
+	return &Database{
+		frozen: make(map[string]*model.FrozenPrefix),
+		tokens: make(map[int64]*model.Token),
+		as:     make(map[int64]*model.RecordA),
+		subAs:  make(map[int64]*model.SubRecordA),
+		cnames: make(map[int64]*model.RecordCNAME),
+		txts:   make(map[int64]*model.RecordTXT),
+
+		subTokens: make(map[int64]*model.SubToken),
+		webhooks:  make(map[int64]*model.WebhookDelivery),
+	}
+}
+
+func (d *Database) InsertFrozen(prefix string) error {
+	return d.MigrateFrozen(prefix, time.Now().UnixNano())
+}
+
+func (d *Database) QueryFrozen(prefix string) (string, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if _, ok := d.frozen[prefix]; !ok {
+		return "", sql.ErrNoRows
+	}
+
+	return prefix, nil
+}
+
+func (d *Database) RenewFrozen(prefix string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if f, ok := d.frozen[prefix]; ok {
+		f.CreatedOn = time.Now().UnixNano()
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteFrozen(prefix string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.frozen, prefix)
+	return nil
+}
+
+func (d *Database) DeleteExpiredFrozen(t *time.Time) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.frozen {
+		if v.CreatedOn <= t.UnixNano() {
+			delete(d.frozen, k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) MigrateFrozen(prefix string, expiration int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.frozen[prefix]; ok {
+		return errors.Errorf(errDuplicateEntry, prefix, "frozen_prefix")
+	}
+
+	d.frozen[prefix] = &model.FrozenPrefix{
+		ID:        d.nextID(),
+		Prefix:    prefix,
+		CreatedOn: expiration,
+	}
+
+	return nil
+}
+
+func (d *Database) InsertToken(token, name string) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.insertToken(token, name, time.Now().UnixNano())
+}
+
+func (d *Database) QueryTokenCount() (int64, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return int64(len(d.tokens)), nil
+}
+
+func (d *Database) QueryToken(name string) (*model.Token, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, v := range d.tokens {
+		if v.Fqdn == name {
+			r := *v
+			return &r, nil
+		}
+	}
+
+	return &model.Token{}, sql.ErrNoRows
+}
+
+func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.Token, 0)
+	for _, v := range d.tokens {
+		if v.CreatedOn <= t.UnixNano() {
+			temp := *v
+			result = append(result, &temp)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Database) ListTokens(suffix string, createdBefore *time.Time, afterID int64, limit int) ([]*model.Token, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.Token, 0)
+	for _, v := range d.tokens {
+		if v.ID <= afterID || !strings.HasSuffix(v.Fqdn, suffix) {
+			continue
+		}
+		if createdBefore != nil && v.CreatedOn > createdBefore.UnixNano() {
+			continue
+		}
+		temp := *v
+		result = append(result, &temp)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func (d *Database) RenewToken(name string) (int64, int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	t := time.Now().UnixNano()
+	for _, v := range d.tokens {
+		if v.Fqdn == name {
+			v.CreatedOn = t
+			return v.ID, t, nil
+		}
+	}
+
+	return 0, t, nil
+}
+
+func (d *Database) UpdateToken(token, name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.tokens {
+		if v.Token == token && v.Fqdn != name {
+			return errors.Errorf(errDuplicateEntry, "token", "token")
+		}
+	}
+	for _, v := range d.tokens {
+		if v.Fqdn == name {
+			v.Token = token
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteToken(token string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for id, v := range d.tokens {
+		if v.Token != token {
+			continue
+		}
+
+		for k, a := range d.as {
+			if a.TID == id {
+				d.deleteA(k)
+			}
+		}
+		for k, c := range d.cnames {
+			if c.TID == id {
+				delete(d.cnames, k)
+			}
+		}
+		for k, t := range d.txts {
+			if t.TID == id {
+				delete(d.txts, k)
+			}
+		}
+		for k, t := range d.subTokens {
+			if t.TID == id {
+				delete(d.subTokens, k)
+			}
+		}
+		delete(d.tokens, id)
+	}
+
+	return nil
+}
+
+func (d *Database) MigrateToken(token, name string, expiration int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	_, err := d.insertToken(token, name, expiration)
+	return err
+}
+
+func (d *Database) InsertSubToken(t *model.SubToken) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.subTokens {
+		if v.SID == t.SID {
+			return 0, errors.Errorf(errDuplicateEntry, t.SID, "sid")
+		}
+	}
+
+	r := *t
+	r.ID = d.nextID()
+	d.subTokens[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) ListSubTokens(id int64) ([]*model.SubToken, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.SubToken, 0)
+	for _, v := range d.subTokens {
+		if v.TID == id {
+			temp := *v
+			result = append(result, &temp)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+func (d *Database) DeleteSubToken(sid string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.subTokens {
+		if v.SID == sid {
+			delete(d.subTokens, k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) InsertA(a *model.RecordA) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.as {
+		if v.Fqdn == a.Fqdn {
+			return 0, errors.Errorf(errDuplicateEntry, a.Fqdn, "record_a")
+		}
+	}
+
+	r := *a
+	r.ID = d.nextID()
+	d.as[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.as {
+		if v.Fqdn == a.Fqdn {
+			v.Type, v.Content, v.CreatedOn, v.TID = a.Type, a.Content, a.CreatedOn, a.TID
+			return v.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (d *Database) QueryA(name string) (*model.RecordA, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, v := range d.as {
+		if v.Fqdn == name {
+			r := *v
+			return &r, nil
+		}
+	}
+
+	return &model.RecordA{}, nil
+}
+
+func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	rs := make([]*model.SubRecordA, 0)
+	for _, v := range d.subAs {
+		if v.PID == id {
+			r := *v
+			rs = append(rs, &r)
+		}
+	}
+
+	return rs, nil
+}
+
+func (d *Database) DeleteA(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.as {
+		if v.Fqdn == name {
+			d.deleteA(k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.subAs {
+		if v.Fqdn == a.Fqdn {
+			return 0, errors.Errorf(errDuplicateEntry, a.Fqdn, "sub_record_a")
+		}
+	}
+
+	r := *a
+	r.ID = d.nextID()
+	d.subAs[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.subAs {
+		if v.Fqdn == a.Fqdn {
+			v.Type, v.Content, v.CreatedOn, v.PID = a.Type, a.Content, a.CreatedOn, a.PID
+			return v.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, v := range d.subAs {
+		if v.Fqdn == name {
+			r := *v
+			return &r, nil
+		}
+	}
+
+	return &model.SubRecordA{}, nil
+}
+
+func (d *Database) DeleteSubA(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.subAs {
+		if v.Fqdn == name {
+			delete(d.subAs, k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.cnames {
+		if v.Fqdn == c.Fqdn {
+			return 0, errors.Errorf(errDuplicateEntry, c.Fqdn, "record_cname")
+		}
+	}
+
+	r := *c
+	r.ID = d.nextID()
+	d.cnames[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.cnames {
+		if v.Fqdn == c.Fqdn {
+			v.Type, v.Content, v.CreatedOn, v.TID = c.Type, c.Content, c.CreatedOn, c.TID
+			return v.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (d *Database) QueryCNAME(name string) (*model.RecordCNAME, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, v := range d.cnames {
+		if v.Fqdn == name {
+			r := *v
+			return &r, nil
+		}
+	}
+
+	return &model.RecordCNAME{}, nil
+}
+
+func (d *Database) DeleteCNAME(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.cnames {
+		if v.Fqdn == name {
+			delete(d.cnames, k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) InsertTXT(t *model.RecordTXT) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.txts {
+		if v.Fqdn == t.Fqdn {
+			return 0, errors.Errorf(errDuplicateEntry, t.Fqdn, "record_txt")
+		}
+	}
+
+	r := *t
+	r.ID = d.nextID()
+	d.txts[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) UpdateTXT(t *model.RecordTXT) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, v := range d.txts {
+		if v.Fqdn == t.Fqdn {
+			v.Type, v.Content, v.CreatedOn, v.TID = t.Type, t.Content, t.CreatedOn, t.TID
+			return v.ID, nil
+		}
+	}
+
+	return 0, nil
+}
+
+func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, v := range d.txts {
+		if v.Fqdn == name {
+			r := *v
+			return &r, nil
+		}
+	}
+
+	return &model.RecordTXT{}, nil
+}
+
+func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.RecordTXT, 0)
+	for _, v := range d.txts {
+		if v.TID == id {
+			temp := *v
+			result = append(result, &temp)
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Database) DeleteTXT(name string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for k, v := range d.txts {
+		if v.Fqdn == name {
+			delete(d.txts, k)
+		}
+	}
+
+	return nil
+}
+
+func (d *Database) InsertAuditLog(l *model.AuditLog) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	r := *l
+	r.ID = d.nextID()
+	d.auditLogs = append(d.auditLogs, &r)
+
+	return r.ID, nil
+}
+
+func (d *Database) ListAuditLogs(fqdn string, since, until *time.Time, limit int) ([]*model.AuditLog, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.AuditLog, 0)
+	for i := len(d.auditLogs) - 1; i >= 0 && len(result) < limit; i-- {
+		v := d.auditLogs[i]
+		if v.Fqdn != fqdn {
+			continue
+		}
+		if (since != nil && v.CreatedOn < since.UnixNano()) || (until != nil && v.CreatedOn > until.UnixNano()) {
+			continue
+		}
+		temp := *v
+		result = append(result, &temp)
+	}
+
+	return result, nil
+}
+
+func (d *Database) InsertWebhookDelivery(w *model.WebhookDelivery) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	r := *w
+	r.ID = d.nextID()
+	d.webhooks[r.ID] = &r
+
+	return r.ID, nil
+}
+
+func (d *Database) ListDueWebhookDeliveries(due *time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := make([]*model.WebhookDelivery, 0)
+	for _, v := range d.webhooks {
+		if v.NextAttemptOn <= due.UnixNano() {
+			temp := *v
+			result = append(result, &temp)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].NextAttemptOn != result[j].NextAttemptOn {
+			return result[i].NextAttemptOn < result[j].NextAttemptOn
+		}
+		return result[i].ID < result[j].ID
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+func (d *Database) ClaimWebhookDelivery(w *model.WebhookDelivery, until int64) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	v, ok := d.webhooks[w.ID]
+	if !ok || v.NextAttemptOn != w.NextAttemptOn {
+		return false, nil
+	}
+	v.NextAttemptOn = until
+
+	return true, nil
+}
+
+func (d *Database) UpdateWebhookDelivery(w *model.WebhookDelivery) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if v, ok := d.webhooks[w.ID]; ok {
+		v.Attempts = w.Attempts
+		v.NextAttemptOn = w.NextAttemptOn
+		v.LastError = w.LastError
+	}
+
+	return nil
+}
+
+func (d *Database) DeleteWebhookDelivery(id int64) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.webhooks, id)
+
+	return nil
+}
+
+func (d *Database) InsertDomainChange(c *model.DomainChange) (int64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	r := *c
+	r.ID = d.nextID()
+	d.changes = append(d.changes, &r)
+
+	return r.ID, nil
+}
+
+func (d *Database) ListDomainChanges(fqdn string, afterID int64, limit int) ([]*model.DomainChange, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	// the changes are appended in the order of their IDs
+	result := make([]*model.DomainChange, 0)
+	for _, v := range d.changes {
+		if v.ID <= afterID || (fqdn != "" && v.Fqdn != fqdn) {
+			continue
+		}
+		temp := *v
+		result = append(result, &temp)
+		if len(result) >= limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (d *Database) QueryDomainChangeRange() (int64, int64, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	if len(d.changes) == 0 {
+		return 0, 0, nil
+	}
+	return d.changes[0].ID, d.changes[len(d.changes)-1].ID, nil
+}
+
+func (d *Database) DeleteExpiredDomainChanges(t *time.Time) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	i := 0
+	for i < len(d.changes)-1 && d.changes[i].CreatedOn <= t.UnixNano() {
+		i++
+	}
+	d.changes = d.changes[i:]
+
+	return nil
+}
+
+// WithContext returns the database itself, only the statements of mysql are traced.
+func (d *Database) WithContext(ctx context.Context) database.Database {
+	return d
+}
+
+func (d *Database) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (d *Database) Close() error {
+	return nil
+}
+
+// Used to insert token, caller must hold the lock
+func (d *Database) insertToken(token, name string, createdOn int64) (int64, error) {
+	for _, v := range d.tokens {
+		if v.Token == token {
+			return 0, errors.Errorf(errDuplicateEntry, "token", "token")
+		}
+	}
+
+	id := d.nextID()
+	d.tokens[id] = &model.Token{
+		ID:        id,
+		Token:     token,
+		Fqdn:      name,
+		CreatedOn: createdOn,
+	}
+
+	return id, nil
+}
+
+// Used to delete A record and its sub A records, caller must hold the lock
+func (d *Database) deleteA(id int64) {
+	for k, v := range d.subAs {
+		if v.PID == id {
+			delete(d.subAs, k)
+		}
+	}
+	delete(d.as, id)
+}
+
+// Used to generate an auto increment ID, caller must hold the lock
+func (d *Database) nextID() int64 {
+	d.lastID++
+	return d.lastID
+}
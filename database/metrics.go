@@ -0,0 +1,368 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/pkg/errors"
+)
+
+// WithMetrics returns the database observing the latency and errors of each call of d, the calls are labeled by their names.
+func WithMetrics(d Database, driver string) Database {
+	return &observedDatabase{d: d, driver: driver}
+}
+
+type observedDatabase struct {
+	d      Database
+	driver string
+}
+
+// Used to observe the call started at the start time, missing rows are expected by the callers so they are not errors
+func (o *observedDatabase) observe(query string, start time.Time, err error) {
+	metric.ObserveDatabaseQuery(o.driver, query, time.Since(start), err != nil && errors.Cause(err) != sql.ErrNoRows)
+}
+
+func (o *observedDatabase) InsertFrozen(prefix string) error {
+	start := time.Now()
+	err := o.d.InsertFrozen(prefix)
+	o.observe("InsertFrozen", start, err)
+	return err
+}
+
+func (o *observedDatabase) QueryFrozen(prefix string) (string, error) {
+	start := time.Now()
+	v, err := o.d.QueryFrozen(prefix)
+	o.observe("QueryFrozen", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) RenewFrozen(prefix string) error {
+	start := time.Now()
+	err := o.d.RenewFrozen(prefix)
+	o.observe("RenewFrozen", start, err)
+	return err
+}
+
+func (o *observedDatabase) DeleteFrozen(prefix string) error {
+	start := time.Now()
+	err := o.d.DeleteFrozen(prefix)
+	o.observe("DeleteFrozen", start, err)
+	return err
+}
+
+func (o *observedDatabase) DeleteExpiredFrozen(t *time.Time) error {
+	start := time.Now()
+	err := o.d.DeleteExpiredFrozen(t)
+	o.observe("DeleteExpiredFrozen", start, err)
+	return err
+}
+
+func (o *observedDatabase) MigrateFrozen(prefix string, expiration int64) error {
+	start := time.Now()
+	err := o.d.MigrateFrozen(prefix, expiration)
+	o.observe("MigrateFrozen", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertToken(token, name string) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertToken(token, name)
+	o.observe("InsertToken", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryTokenCount() (int64, error) {
+	start := time.Now()
+	v, err := o.d.QueryTokenCount()
+	o.observe("QueryTokenCount", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryToken(name string) (*model.Token, error) {
+	start := time.Now()
+	v, err := o.d.QueryToken(name)
+	o.observe("QueryToken", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
+	start := time.Now()
+	v, err := o.d.QueryExpiredTokens(t)
+	o.observe("QueryExpiredTokens", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListTokens(suffix string, createdBefore *time.Time, afterID int64, limit int) ([]*model.Token, error) {
+	start := time.Now()
+	v, err := o.d.ListTokens(suffix, createdBefore, afterID, limit)
+	o.observe("ListTokens", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) RenewToken(name string) (int64, int64, error) {
+	start := time.Now()
+	v1, v2, err := o.d.RenewToken(name)
+	o.observe("RenewToken", start, err)
+	return v1, v2, err
+}
+
+func (o *observedDatabase) UpdateToken(token, name string) error {
+	start := time.Now()
+	err := o.d.UpdateToken(token, name)
+	o.observe("UpdateToken", start, err)
+	return err
+}
+
+func (o *observedDatabase) DeleteToken(prefix string) error {
+	start := time.Now()
+	err := o.d.DeleteToken(prefix)
+	o.observe("DeleteToken", start, err)
+	return err
+}
+
+func (o *observedDatabase) MigrateToken(token, name string, expiration int64) error {
+	start := time.Now()
+	err := o.d.MigrateToken(token, name, expiration)
+	o.observe("MigrateToken", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertSubToken(t *model.SubToken) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertSubToken(t)
+	o.observe("InsertSubToken", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListSubTokens(id int64) ([]*model.SubToken, error) {
+	start := time.Now()
+	v, err := o.d.ListSubTokens(id)
+	o.observe("ListSubTokens", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) DeleteSubToken(sid string) error {
+	start := time.Now()
+	err := o.d.DeleteSubToken(sid)
+	o.observe("DeleteSubToken", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertA(a *model.RecordA) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertA(a)
+	o.observe("InsertA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) UpdateA(a *model.RecordA) (int64, error) {
+	start := time.Now()
+	v, err := o.d.UpdateA(a)
+	o.observe("UpdateA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryA(name string) (*model.RecordA, error) {
+	start := time.Now()
+	v, err := o.d.QueryA(name)
+	o.observe("QueryA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListSubA(id int64) ([]*model.SubRecordA, error) {
+	start := time.Now()
+	v, err := o.d.ListSubA(id)
+	o.observe("ListSubA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) DeleteA(name string) error {
+	start := time.Now()
+	err := o.d.DeleteA(name)
+	o.observe("DeleteA", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertSubA(a *model.SubRecordA) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertSubA(a)
+	o.observe("InsertSubA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) UpdateSubA(a *model.SubRecordA) (int64, error) {
+	start := time.Now()
+	v, err := o.d.UpdateSubA(a)
+	o.observe("UpdateSubA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QuerySubA(name string) (*model.SubRecordA, error) {
+	start := time.Now()
+	v, err := o.d.QuerySubA(name)
+	o.observe("QuerySubA", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) DeleteSubA(name string) error {
+	start := time.Now()
+	err := o.d.DeleteSubA(name)
+	o.observe("DeleteSubA", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertCNAME(c *model.RecordCNAME) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertCNAME(c)
+	o.observe("InsertCNAME", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
+	start := time.Now()
+	v, err := o.d.UpdateCNAME(c)
+	o.observe("UpdateCNAME", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryCNAME(name string) (*model.RecordCNAME, error) {
+	start := time.Now()
+	v, err := o.d.QueryCNAME(name)
+	o.observe("QueryCNAME", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) DeleteCNAME(name string) error {
+	start := time.Now()
+	err := o.d.DeleteCNAME(name)
+	o.observe("DeleteCNAME", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertTXT(t *model.RecordTXT) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertTXT(t)
+	o.observe("InsertTXT", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) UpdateTXT(t *model.RecordTXT) (int64, error) {
+	start := time.Now()
+	v, err := o.d.UpdateTXT(t)
+	o.observe("UpdateTXT", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryTXT(name string) (*model.RecordTXT, error) {
+	start := time.Now()
+	v, err := o.d.QueryTXT(name)
+	o.observe("QueryTXT", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
+	start := time.Now()
+	v, err := o.d.QueryExpiredTXTs(id)
+	o.observe("QueryExpiredTXTs", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) DeleteTXT(name string) error {
+	start := time.Now()
+	err := o.d.DeleteTXT(name)
+	o.observe("DeleteTXT", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertAuditLog(l *model.AuditLog) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertAuditLog(l)
+	o.observe("InsertAuditLog", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListAuditLogs(fqdn string, since, until *time.Time, limit int) ([]*model.AuditLog, error) {
+	start := time.Now()
+	v, err := o.d.ListAuditLogs(fqdn, since, until, limit)
+	o.observe("ListAuditLogs", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) InsertWebhookDelivery(w *model.WebhookDelivery) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertWebhookDelivery(w)
+	o.observe("InsertWebhookDelivery", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListDueWebhookDeliveries(due *time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	start := time.Now()
+	v, err := o.d.ListDueWebhookDeliveries(due, limit)
+	o.observe("ListDueWebhookDeliveries", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ClaimWebhookDelivery(w *model.WebhookDelivery, until int64) (bool, error) {
+	start := time.Now()
+	v, err := o.d.ClaimWebhookDelivery(w, until)
+	o.observe("ClaimWebhookDelivery", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) UpdateWebhookDelivery(w *model.WebhookDelivery) error {
+	start := time.Now()
+	err := o.d.UpdateWebhookDelivery(w)
+	o.observe("UpdateWebhookDelivery", start, err)
+	return err
+}
+
+func (o *observedDatabase) DeleteWebhookDelivery(id int64) error {
+	start := time.Now()
+	err := o.d.DeleteWebhookDelivery(id)
+	o.observe("DeleteWebhookDelivery", start, err)
+	return err
+}
+
+func (o *observedDatabase) InsertDomainChange(c *model.DomainChange) (int64, error) {
+	start := time.Now()
+	v, err := o.d.InsertDomainChange(c)
+	o.observe("InsertDomainChange", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) ListDomainChanges(fqdn string, afterID int64, limit int) ([]*model.DomainChange, error) {
+	start := time.Now()
+	v, err := o.d.ListDomainChanges(fqdn, afterID, limit)
+	o.observe("ListDomainChanges", start, err)
+	return v, err
+}
+
+func (o *observedDatabase) QueryDomainChangeRange() (int64, int64, error) {
+	start := time.Now()
+	first, last, err := o.d.QueryDomainChangeRange()
+	o.observe("QueryDomainChangeRange", start, err)
+	return first, last, err
+}
+
+func (o *observedDatabase) DeleteExpiredDomainChanges(t *time.Time) error {
+	start := time.Now()
+	err := o.d.DeleteExpiredDomainChanges(t)
+	o.observe("DeleteExpiredDomainChanges", start, err)
+	return err
+}
+
+func (o *observedDatabase) WithContext(ctx context.Context) Database {
+	return &observedDatabase{d: o.d.WithContext(ctx), driver: o.driver}
+}
+
+func (o *observedDatabase) Ping(ctx context.Context) error {
+	return o.d.Ping(ctx)
+}
+
+func (o *observedDatabase) Close() error {
+	return o.d.Close()
+}
@@ -0,0 +1,15 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT AUTO_INCREMENT,
+    fqdn VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    content TEXT NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX index_fqdn_created_on_audit (fqdn, created_on)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS audit_log;
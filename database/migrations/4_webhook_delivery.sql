@@ -0,0 +1,18 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id BIGINT AUTO_INCREMENT,
+    endpoint VARCHAR(2048) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    content TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_on BIGINT NOT NULL,
+    last_error TEXT NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX index_next_attempt_on_webhook (next_attempt_on)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS webhook_delivery;
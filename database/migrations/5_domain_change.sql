@@ -0,0 +1,14 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS domain_change (
+    id BIGINT AUTO_INCREMENT,
+    fqdn VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX index_fqdn_id_change (fqdn, id),
+    INDEX index_created_on_change (created_on)
+) ENGINE=INNODB DEFAULT CHARSET=utf8;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS domain_change;
@@ -0,0 +1,10 @@
+package migrations
+
+const (
+	errUnsupportedDriver     = "unsupported migration driver: %s"
+	errCreateTable           = "failed to create migration table %s"
+	errApplyMigration        = "failed to apply migration %s"
+	errRollbackMigration     = "failed to roll back migration %s"
+	errNoDirective           = "statement found before any migrate directive in %s"
+	errUnterminatedStatement = "statement is not terminated by a semicolon in %s"
+)
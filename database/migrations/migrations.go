@@ -0,0 +1,292 @@
+package migrations
+
+import (
+	"bufio"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Table keeps the ID of every applied migration, the ID is the file name (e.g. 1_initial.sql)
+	Table = "schema_migrations"
+
+	directiveUp             = "-- +migrate Up"
+	directiveDown           = "-- +migrate Down"
+	directiveStatementBegin = "-- +migrate StatementBegin"
+	directiveStatementEnd   = "-- +migrate StatementEnd"
+)
+
+// files are the migrations of each driver, the MySQL migrations must be idempotent since MySQL DDL is not transactional
+//
+//go:embed *.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// dirs maps a database driver name to the directory of its migrations
+var dirs = map[string]string{
+	"mysql":    ".",
+	"postgres": "postgres",
+	"sqlite":   "sqlite",
+}
+
+type Migration struct {
+	ID   string
+	Up   []string
+	Down []string
+}
+
+type Status struct {
+	ID        string
+	AppliedOn int64
+}
+
+// Migrations returns the embedded migrations of the driver ordered by their version prefix.
+func Migrations(driver string) ([]*Migration, error) {
+	dir, ok := dirs[driver]
+	if !ok {
+		return nil, errors.Errorf(errUnsupportedDriver, driver)
+	}
+
+	entries, err := files.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := make([]*Migration, 0)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sql") {
+			continue
+		}
+		b, err := files.ReadFile(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, err := parse(e.Name(), string(b))
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, m)
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return version(ms[i].ID) < version(ms[j].ID)
+	})
+
+	return ms, nil
+}
+
+// Up applies at most max pending migrations (all of them when max <= 0) and returns how many were applied.
+func Up(db *sql.DB, driver string, max int) (int, error) {
+	ms, applied, err := load(db, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range ms {
+		if _, ok := applied[m.ID]; ok {
+			continue
+		}
+		if max > 0 && count >= max {
+			break
+		}
+		logrus.Infof("applying migration %s", m.ID)
+		if err := apply(db, driver, m.ID, m.Up, true); err != nil {
+			return count, errors.Wrapf(err, errApplyMigration, m.ID)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// Down rolls back at most max applied migrations (all of them when max <= 0) from the newest one and returns how many were rolled back.
+func Down(db *sql.DB, driver string, max int) (int, error) {
+	ms, applied, err := load(db, driver)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(ms) - 1; i >= 0; i-- {
+		m := ms[i]
+		if _, ok := applied[m.ID]; !ok {
+			continue
+		}
+		if max > 0 && count >= max {
+			break
+		}
+		logrus.Infof("rolling back migration %s", m.ID)
+		if err := apply(db, driver, m.ID, m.Down, false); err != nil {
+			return count, errors.Wrapf(err, errRollbackMigration, m.ID)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// GetStatus returns all embedded migrations of the driver, AppliedOn is zero for the pending ones.
+func GetStatus(db *sql.DB, driver string) ([]*Status, error) {
+	ms, applied, err := load(db, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*Status, 0)
+	for _, m := range ms {
+		result = append(result, &Status{
+			ID:        m.ID,
+			AppliedOn: applied[m.ID],
+		})
+	}
+
+	return result, nil
+}
+
+// Used to load the embedded migrations and the applied ones, the schema table is created if it does not exist
+func load(db *sql.DB, driver string) ([]*Migration, map[string]int64, error) {
+	ms, err := Migrations(driver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id VARCHAR(255) NOT NULL PRIMARY KEY, applied_on BIGINT NOT NULL)", Table)); err != nil {
+		return nil, nil, errors.Wrapf(err, errCreateTable, Table)
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT id, applied_on FROM %s", Table))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[string]int64)
+	for rows.Next() {
+		var id string
+		var appliedOn int64
+		if err := rows.Scan(&id, &appliedOn); err != nil {
+			return nil, nil, err
+		}
+		applied[id] = appliedOn
+	}
+
+	return ms, applied, rows.Err()
+}
+
+// Used to execute the statements of a migration and record it in the schema table within one transaction.
+// The migration is atomic on postgres and sqlite only, DDL statements commit implicitly on MySQL, so a failed MySQL
+// migration may be applied partly without being recorded. MySQL migrations must be idempotent (e.g. IF NOT EXISTS)
+// so they can be applied again once the failure is fixed.
+func apply(db *sql.DB, driver, id string, statements []string, up bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range statements {
+		if _, err := tx.Exec(s); err != nil {
+			if e := tx.Rollback(); e != nil {
+				logrus.Error(e)
+			}
+			return err
+		}
+	}
+
+	if up {
+		_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (id, applied_on) VALUES (%s, %s)", Table, placeholder(driver, 1), placeholder(driver, 2)), id, time.Now().UnixNano())
+	} else {
+		_, err = tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE id = %s", Table, placeholder(driver, 1)), id)
+	}
+	if err != nil {
+		if e := tx.Rollback(); e != nil {
+			logrus.Error(e)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Used to parse the sql-migrate file format, a statement ends with a semicolon at the end of line
+// unless it is wrapped by StatementBegin and StatementEnd
+func parse(id, content string) (*Migration, error) {
+	m := &Migration{ID: id}
+
+	var current *[]string
+	var buf strings.Builder
+	ignoreSemicolons := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, directiveUp):
+			current = &m.Up
+			continue
+		case strings.HasPrefix(trimmed, directiveDown):
+			current = &m.Down
+			continue
+		case strings.HasPrefix(trimmed, directiveStatementBegin):
+			ignoreSemicolons = true
+			continue
+		case strings.HasPrefix(trimmed, directiveStatementEnd):
+			ignoreSemicolons = false
+			if current != nil && strings.TrimSpace(buf.String()) != "" {
+				*current = append(*current, buf.String())
+			}
+			buf.Reset()
+			continue
+		case strings.HasPrefix(trimmed, "--") || trimmed == "":
+			continue
+		}
+
+		if current == nil {
+			return nil, errors.Errorf(errNoDirective, id)
+		}
+
+		buf.WriteString(line)
+		buf.WriteString("\n")
+
+		if !ignoreSemicolons && strings.HasSuffix(trimmed, ";") {
+			*current = append(*current, buf.String())
+			buf.Reset()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(buf.String()) != "" {
+		return nil, errors.Errorf(errUnterminatedStatement, id)
+	}
+
+	return m, nil
+}
+
+// Used to get the version prefix of a migration ID (e.g. 1 of 1_initial.sql)
+func version(id string) int64 {
+	v, err := strconv.ParseInt(strings.SplitN(id, "_", 2)[0], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// Used to get the bind variable of the driver
+func placeholder(driver string, n int) string {
+	if driver == "postgres" {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
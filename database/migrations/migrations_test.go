@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	content := `-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE a (
+    id INT
+);
+
+CREATE INDEX index_a ON a (id);
+
+-- +migrate StatementBegin
+CREATE TRIGGER t AFTER INSERT ON a BEGIN
+    SELECT 1;
+END;
+-- +migrate StatementEnd
+
+-- +migrate Down
+DROP TABLE a;
+`
+	m, err := parse("1_a.sql", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := []string{
+		"CREATE TABLE a (\n    id INT\n);\n",
+		"CREATE INDEX index_a ON a (id);\n",
+		"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    SELECT 1;\nEND;\n",
+	}
+	if !reflect.DeepEqual(m.Up, up) {
+		t.Errorf("Up = %q, want %q", m.Up, up)
+	}
+	if down := []string{"DROP TABLE a;\n"}; !reflect.DeepEqual(m.Down, down) {
+		t.Errorf("Down = %q, want %q", m.Down, down)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"statement before directive": "CREATE TABLE a (id INT);\n-- +migrate Up\n",
+		"unterminated statement":     "-- +migrate Up\nCREATE TABLE a (id INT)\n",
+	}
+
+	for name, content := range tests {
+		if _, err := parse("1_a.sql", content); err == nil {
+			t.Errorf("%s: parse() is accepted", name)
+		}
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for driver := range dirs {
+		ms, err := Migrations(driver)
+		if err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		for i, m := range ms {
+			if v := version(m.ID); v != int64(i+1) {
+				t.Errorf("%s: migration %s has version %d, want %d", driver, m.ID, v, i+1)
+			}
+			if len(m.Up) == 0 || len(m.Down) == 0 {
+				t.Errorf("%s: migration %s has no Up or Down statements", driver, m.ID)
+			}
+		}
+	}
+
+	if _, err := Migrations("oracle"); err == nil {
+		t.Error("unsupported driver is accepted")
+	}
+}
+
+// MySQL commits DDL statements implicitly, so the tables must be created and dropped only if they (do not) exist
+func TestMySQLMigrationsAreIdempotent(t *testing.T) {
+	ms, err := Migrations("mysql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range ms {
+		for _, s := range append(append([]string{}, m.Up...), m.Down...) {
+			s = strings.ToUpper(s)
+			if (strings.HasPrefix(s, "CREATE TABLE") && !strings.HasPrefix(s, "CREATE TABLE IF NOT EXISTS")) ||
+				(strings.HasPrefix(s, "DROP TABLE") && !strings.HasPrefix(s, "DROP TABLE IF EXISTS")) {
+				t.Errorf("statement of %s is not idempotent: %s", m.ID, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS frozen_prefix (
+    id SERIAL PRIMARY KEY,
+    prefix VARCHAR(255) NOT NULL UNIQUE,
+    created_on BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_created_on_frozen ON frozen_prefix (created_on);
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS token (
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(255) NOT NULL UNIQUE,
+    fqdn VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_created_on_token ON token (created_on);
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS record_a (
+    id SERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL UNIQUE,
+    type SMALLINT NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    updated_on BIGINT,
+    tid INTEGER NOT NULL,
+    CONSTRAINT fk_token_a FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_created_on_a ON record_a (created_on);
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS sub_record_a (
+    id SERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL UNIQUE,
+    type SMALLINT NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    updated_on BIGINT,
+    pid INTEGER NOT NULL,
+    CONSTRAINT fk_record_a FOREIGN KEY(pid) REFERENCES record_a(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_created_on_sub_a ON sub_record_a (created_on);
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS record_cname (
+    id SERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL UNIQUE,
+    type SMALLINT NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    updated_on BIGINT,
+    tid INTEGER NOT NULL,
+    CONSTRAINT fk_token_cname FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_created_on_cname ON record_cname (created_on);
+
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS record_txt (
+    id SERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL UNIQUE,
+    type SMALLINT NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL,
+    updated_on BIGINT,
+    tid INTEGER NOT NULL,
+    CONSTRAINT fk_token_txt FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS index_created_on_txt ON record_txt (created_on);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS frozen_prefix;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS sub_record_a;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS record_a;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS record_cname;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS record_txt;
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS token;
\ No newline at end of file
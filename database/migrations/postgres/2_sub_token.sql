@@ -0,0 +1,16 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS sub_token (
+    id SERIAL PRIMARY KEY,
+    sid VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    scope VARCHAR(32) NOT NULL,
+    digest VARCHAR(64) NOT NULL,
+    created_on BIGINT NOT NULL,
+    tid INTEGER NOT NULL,
+    CONSTRAINT fk_token_sub FOREIGN KEY(tid) REFERENCES token(id) ON DELETE CASCADE
+);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS sub_token;
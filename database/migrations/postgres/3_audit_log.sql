@@ -0,0 +1,14 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    content TEXT NOT NULL,
+    created_on BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_fqdn_created_on_audit ON audit_log (fqdn, created_on);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS audit_log;
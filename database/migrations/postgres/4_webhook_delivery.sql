@@ -0,0 +1,17 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id BIGSERIAL PRIMARY KEY,
+    endpoint VARCHAR(2048) NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    content TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_on BIGINT NOT NULL,
+    last_error TEXT NOT NULL,
+    created_on BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_next_attempt_on_webhook ON webhook_delivery (next_attempt_on);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS webhook_delivery;
@@ -0,0 +1,13 @@
+-- +migrate Up
+-- SQL in section 'Up' is executed when this migration is applied
+CREATE TABLE IF NOT EXISTS domain_change (
+    id BIGSERIAL PRIMARY KEY,
+    fqdn VARCHAR(255) NOT NULL,
+    created_on BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS index_fqdn_id_change ON domain_change (fqdn, id);
+CREATE INDEX IF NOT EXISTS index_created_on_change ON domain_change (created_on);
+
+-- +migrate Down
+-- SQL section 'Down' is executed when this migration is rolled back
+DROP TABLE IF EXISTS domain_change;
@@ -1,13 +1,20 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
+	"math"
+	"strings"
 	"time"
 
+	"github.com/rancher/rdns-server/database"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/tracing"
 
 	// in order to make build through
 	_ "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -19,6 +26,8 @@ const (
 
 type Database struct {
 	Db *sql.DB
+
+	ctx context.Context
 }
 
 func NewDatabase(dsn string) (*Database, error) {
@@ -34,11 +43,63 @@ func NewDatabase(dsn string) (*Database, error) {
 		return &Database{}, err
 	}
 
-	return &Database{db}, err
+	return &Database{Db: db}, err
+}
+
+// WithContext returns a copy of the database whose statements are traced under the request,
+// the statements are not canceled with the request so a record is never left half written.
+func (d *Database) WithContext(ctx context.Context) database.Database {
+	c := *d
+	c.ctx = ctx
+	return &c
+}
+
+// statement is traced from being prepared to being closed, so the rows scanned are included in the span
+type statement struct {
+	*sql.Stmt
+	ctx  context.Context
+	span trace.Span
+}
+
+// Used to prepare the query in a span, the span is ended once the statement is closed
+func (d *Database) prepare(query string) (*statement, error) {
+	ctx, span := tracing.StartChild(d.ctx, DriverName+" "+strings.Fields(query)[0],
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", DriverName), attribute.String("db.statement", query)))
+	ctx = context.WithoutCancel(ctx)
+
+	st, err := d.Db.PrepareContext(ctx, query)
+	if err != nil {
+		tracing.End(span, err)
+		return nil, err
+	}
+	return &statement{Stmt: st, ctx: ctx, span: span}, nil
+}
+
+func (s *statement) Exec(args ...interface{}) (sql.Result, error) {
+	result, err := s.Stmt.ExecContext(s.ctx, args...)
+	tracing.RecordError(s.span, err)
+	return result, err
+}
+
+func (s *statement) Query(args ...interface{}) (*sql.Rows, error) {
+	rows, err := s.Stmt.QueryContext(s.ctx, args...)
+	tracing.RecordError(s.span, err)
+	return rows, err
+}
+
+func (s *statement) QueryRow(args ...interface{}) *sql.Row {
+	return s.Stmt.QueryRowContext(s.ctx, args...)
+}
+
+func (s *statement) Close() error {
+	err := s.Stmt.Close()
+	s.span.End()
+	return err
 }
 
 func (d *Database) InsertFrozen(prefix string) error {
-	st, err := d.Db.Prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
+	st, err := d.prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
 	if err != nil {
 		return err
 	}
@@ -49,7 +110,7 @@ func (d *Database) InsertFrozen(prefix string) error {
 }
 
 func (d *Database) QueryFrozen(prefix string) (string, error) {
-	st, err := d.Db.Prepare("SELECT prefix FROM frozen_prefix WHERE prefix = ?")
+	st, err := d.prepare("SELECT prefix FROM frozen_prefix WHERE prefix = ?")
 	if err != nil {
 		return "", err
 	}
@@ -64,7 +125,7 @@ func (d *Database) QueryFrozen(prefix string) (string, error) {
 }
 
 func (d *Database) RenewFrozen(prefix string) error {
-	st, err := d.Db.Prepare("UPDATE frozen_prefix SET created_on = ? WHERE prefix = ?")
+	st, err := d.prepare("UPDATE frozen_prefix SET created_on = ? WHERE prefix = ?")
 	if err != nil {
 		return err
 	}
@@ -75,7 +136,7 @@ func (d *Database) RenewFrozen(prefix string) error {
 }
 
 func (d *Database) DeleteFrozen(prefix string) error {
-	st, err := d.Db.Prepare("DELETE FROM frozen_prefix WHERE prefix = ?")
+	st, err := d.prepare("DELETE FROM frozen_prefix WHERE prefix = ?")
 	if err != nil {
 		return err
 	}
@@ -86,7 +147,7 @@ func (d *Database) DeleteFrozen(prefix string) error {
 }
 
 func (d *Database) DeleteExpiredFrozen(t *time.Time) error {
-	st, err := d.Db.Prepare("DELETE FROM frozen_prefix WHERE created_on <= ?")
+	st, err := d.prepare("DELETE FROM frozen_prefix WHERE created_on <= ?")
 	if err != nil {
 		return err
 	}
@@ -97,7 +158,7 @@ func (d *Database) DeleteExpiredFrozen(t *time.Time) error {
 }
 
 func (d *Database) MigrateFrozen(prefix string, expiration int64) error {
-	st, err := d.Db.Prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
+	st, err := d.prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
 	if err != nil {
 		return err
 	}
@@ -108,7 +169,7 @@ func (d *Database) MigrateFrozen(prefix string, expiration int64) error {
 }
 
 func (d *Database) InsertToken(token, name string) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )")
+	st, err := d.prepare("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )")
 	if err != nil {
 		return 0, err
 	}
@@ -123,7 +184,7 @@ func (d *Database) InsertToken(token, name string) (int64, error) {
 }
 
 func (d *Database) QueryTokenCount() (int64, error) {
-	st, err := d.Db.Prepare("SELECT count(*) FROM token")
+	st, err := d.prepare("SELECT count(*) FROM token")
 	if err != nil {
 		return 0, err
 	}
@@ -139,7 +200,7 @@ func (d *Database) QueryTokenCount() (int64, error) {
 
 func (d *Database) QueryToken(name string) (*model.Token, error) {
 	r := &model.Token{}
-	st, err := d.Db.Prepare("SELECT * FROM token WHERE fqdn = ?")
+	st, err := d.prepare("SELECT * FROM token WHERE fqdn = ?")
 	if err != nil {
 		return r, err
 	}
@@ -154,7 +215,7 @@ func (d *Database) QueryToken(name string) (*model.Token, error) {
 
 func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
 	result := make([]*model.Token, 0)
-	st, err := d.Db.Prepare("SELECT * FROM token WHERE created_on <= ?")
+	st, err := d.prepare("SELECT * FROM token WHERE created_on <= ?")
 	if err != nil {
 		return result, err
 	}
@@ -176,8 +237,38 @@ func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
 	return result, nil
 }
 
+func (d *Database) ListTokens(suffix string, createdBefore *time.Time, afterID int64, limit int) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	st, err := d.prepare("SELECT * FROM token WHERE id > ? AND fqdn LIKE ? AND created_on <= ? ORDER BY id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	before := int64(math.MaxInt64)
+	if createdBefore != nil {
+		before = createdBefore.UnixNano()
+	}
+
+	rows, err := st.Query(afterID, "%"+suffix, before, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
 func (d *Database) RenewToken(name string) (int64, int64, error) {
-	st, err := d.Db.Prepare("UPDATE token SET created_on = ? WHERE fqdn = ?")
+	st, err := d.prepare("UPDATE token SET created_on = ? WHERE fqdn = ?")
 	if err != nil {
 		return 0, 0, err
 	}
@@ -197,8 +288,19 @@ func (d *Database) RenewToken(name string) (int64, int64, error) {
 	return id, t, nil
 }
 
+func (d *Database) UpdateToken(token, name string) error {
+	st, err := d.prepare("UPDATE token SET token = ? WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(token, name)
+	return err
+}
+
 func (d *Database) DeleteToken(token string) error {
-	st, err := d.Db.Prepare("DELETE FROM token WHERE token = ?")
+	st, err := d.prepare("DELETE FROM token WHERE token = ?")
 	if err != nil {
 		return err
 	}
@@ -209,7 +311,7 @@ func (d *Database) DeleteToken(token string) error {
 }
 
 func (d *Database) MigrateToken(token, name string, expiration int64) error {
-	st, err := d.Db.Prepare("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )")
+	st, err := d.prepare("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )")
 	if err != nil {
 		return err
 	}
@@ -223,8 +325,58 @@ func (d *Database) MigrateToken(token, name string, expiration int64) error {
 	return nil
 }
 
+func (d *Database) InsertSubToken(t *model.SubToken) (int64, error) {
+	st, err := d.prepare("INSERT INTO sub_token (sid, name, scope, digest, created_on, tid) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(t.SID, t.Name, t.Scope, t.Digest, t.CreatedOn, t.TID)
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+func (d *Database) ListSubTokens(id int64) ([]*model.SubToken, error) {
+	result := make([]*model.SubToken, 0)
+	st, err := d.prepare("SELECT id, sid, name, scope, digest, created_on, tid FROM sub_token WHERE tid = ? ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(id)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.SubToken{}
+		if err := rows.Scan(&temp.ID, &temp.SID, &temp.Name, &temp.Scope, &temp.Digest, &temp.CreatedOn, &temp.TID); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteSubToken(sid string) error {
+	st, err := d.prepare("DELETE FROM sub_token WHERE sid = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(sid)
+	return err
+}
+
 func (d *Database) InsertA(a *model.RecordA) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO record_a (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
+	st, err := d.prepare("INSERT INTO record_a (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -239,7 +391,7 @@ func (d *Database) InsertA(a *model.RecordA) (int64, error) {
 
 func (d *Database) QueryA(name string) (*model.RecordA, error) {
 	r := &model.RecordA{}
-	st, err := d.Db.Prepare("SELECT * FROM record_a WHERE fqdn = ?")
+	st, err := d.prepare("SELECT * FROM record_a WHERE fqdn = ?")
 	if err != nil {
 		return r, err
 	}
@@ -260,7 +412,7 @@ func (d *Database) QueryA(name string) (*model.RecordA, error) {
 }
 
 func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE record_a SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
+	st, err := d.prepare("UPDATE record_a SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
 	if err != nil {
 		return 0, err
 	}
@@ -274,7 +426,7 @@ func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
 }
 
 func (d *Database) DeleteA(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM record_a WHERE fqdn = ?")
+	st, err := d.prepare("DELETE FROM record_a WHERE fqdn = ?")
 	if err != nil {
 		return err
 	}
@@ -285,7 +437,7 @@ func (d *Database) DeleteA(name string) error {
 }
 
 func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO sub_record_a (fqdn, type, content, created_on, pid) VALUES (?, ?, ?, ?, ?)")
+	st, err := d.prepare("INSERT INTO sub_record_a (fqdn, type, content, created_on, pid) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -299,7 +451,7 @@ func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
 }
 
 func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE sub_record_a SET type = ?, content = ?, created_on = ?, pid = ? WHERE fqdn = ?")
+	st, err := d.prepare("UPDATE sub_record_a SET type = ?, content = ?, created_on = ?, pid = ? WHERE fqdn = ?")
 	if err != nil {
 		return 0, err
 	}
@@ -314,7 +466,7 @@ func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
 
 func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
 	r := &model.SubRecordA{}
-	st, err := d.Db.Prepare("SELECT * FROM sub_record_a WHERE fqdn = ?")
+	st, err := d.prepare("SELECT * FROM sub_record_a WHERE fqdn = ?")
 	if err != nil {
 		return r, err
 	}
@@ -337,7 +489,7 @@ func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
 func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
 	rs := make([]*model.SubRecordA, 0)
 
-	st, err := d.Db.Prepare("SELECT * FROM sub_record_a WHERE pid = ?")
+	st, err := d.prepare("SELECT * FROM sub_record_a WHERE pid = ?")
 	if err != nil {
 		return rs, err
 	}
@@ -360,7 +512,7 @@ func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
 }
 
 func (d *Database) DeleteSubA(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM sub_record_a WHERE fqdn = ?")
+	st, err := d.prepare("DELETE FROM sub_record_a WHERE fqdn = ?")
 	if err != nil {
 		return err
 	}
@@ -371,7 +523,7 @@ func (d *Database) DeleteSubA(name string) error {
 }
 
 func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO record_cname (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
+	st, err := d.prepare("INSERT INTO record_cname (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -385,7 +537,7 @@ func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
 }
 
 func (d *Database) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE record_cname SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
+	st, err := d.prepare("UPDATE record_cname SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
 	if err != nil {
 		return 0, err
 	}
@@ -400,7 +552,7 @@ func (d *Database) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
 
 func (d *Database) QueryCNAME(name string) (*model.RecordCNAME, error) {
 	r := &model.RecordCNAME{}
-	st, err := d.Db.Prepare("SELECT * FROM record_cname WHERE fqdn = ?")
+	st, err := d.prepare("SELECT * FROM record_cname WHERE fqdn = ?")
 	if err != nil {
 		return r, err
 	}
@@ -421,7 +573,7 @@ func (d *Database) QueryCNAME(name string) (*model.RecordCNAME, error) {
 }
 
 func (d *Database) DeleteCNAME(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM record_cname WHERE fqdn = ?")
+	st, err := d.prepare("DELETE FROM record_cname WHERE fqdn = ?")
 	if err != nil {
 		return err
 	}
@@ -432,7 +584,7 @@ func (d *Database) DeleteCNAME(name string) error {
 }
 
 func (d *Database) InsertTXT(a *model.RecordTXT) (int64, error) {
-	st, err := d.Db.Prepare("INSERT INTO record_txt (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
+	st, err := d.prepare("INSERT INTO record_txt (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)")
 	if err != nil {
 		return 0, err
 	}
@@ -446,7 +598,7 @@ func (d *Database) InsertTXT(a *model.RecordTXT) (int64, error) {
 }
 
 func (d *Database) UpdateTXT(a *model.RecordTXT) (int64, error) {
-	st, err := d.Db.Prepare("UPDATE record_txt SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
+	st, err := d.prepare("UPDATE record_txt SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ?")
 	if err != nil {
 		return 0, err
 	}
@@ -460,7 +612,7 @@ func (d *Database) UpdateTXT(a *model.RecordTXT) (int64, error) {
 }
 
 func (d *Database) DeleteTXT(name string) error {
-	st, err := d.Db.Prepare("DELETE FROM record_txt WHERE fqdn = ?")
+	st, err := d.prepare("DELETE FROM record_txt WHERE fqdn = ?")
 	if err != nil {
 		return err
 	}
@@ -472,7 +624,7 @@ func (d *Database) DeleteTXT(name string) error {
 
 func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
 	r := &model.RecordTXT{}
-	st, err := d.Db.Prepare("SELECT * FROM record_txt WHERE fqdn = ?")
+	st, err := d.prepare("SELECT * FROM record_txt WHERE fqdn = ?")
 	if err != nil {
 		return r, err
 	}
@@ -494,7 +646,7 @@ func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
 
 func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
 	result := make([]*model.RecordTXT, 0)
-	st, err := d.Db.Prepare("SELECT * FROM record_txt WHERE tid = ?")
+	st, err := d.prepare("SELECT * FROM record_txt WHERE tid = ?")
 	if err != nil {
 		return result, err
 	}
@@ -516,6 +668,208 @@ func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
 	return result, nil
 }
 
+func (d *Database) InsertAuditLog(l *model.AuditLog) (int64, error) {
+	st, err := d.prepare("INSERT INTO audit_log (fqdn, action, content, created_on) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(l.Fqdn, l.Action, l.Content, l.CreatedOn)
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+func (d *Database) ListAuditLogs(fqdn string, since, until *time.Time, limit int) ([]*model.AuditLog, error) {
+	result := make([]*model.AuditLog, 0)
+	st, err := d.prepare("SELECT id, fqdn, action, content, created_on FROM audit_log WHERE fqdn = ? AND created_on >= ? AND created_on <= ? ORDER BY id DESC LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	from, to := int64(0), int64(math.MaxInt64)
+	if since != nil {
+		from = since.UnixNano()
+	}
+	if until != nil {
+		to = until.UnixNano()
+	}
+
+	rows, err := st.Query(fqdn, from, to, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.AuditLog{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.Action, &temp.Content, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) InsertWebhookDelivery(w *model.WebhookDelivery) (int64, error) {
+	st, err := d.prepare("INSERT INTO webhook_delivery (endpoint, event, content, attempts, next_attempt_on, last_error, created_on) VALUES (?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(w.Endpoint, w.Event, w.Content, w.Attempts, w.NextAttemptOn, w.LastError, w.CreatedOn)
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+func (d *Database) ListDueWebhookDeliveries(due *time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	result := make([]*model.WebhookDelivery, 0)
+	st, err := d.prepare("SELECT id, endpoint, event, content, attempts, next_attempt_on, last_error, created_on FROM webhook_delivery WHERE next_attempt_on <= ? ORDER BY next_attempt_on, id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(due.UnixNano(), limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.WebhookDelivery{}
+		if err := rows.Scan(&temp.ID, &temp.Endpoint, &temp.Event, &temp.Content, &temp.Attempts, &temp.NextAttemptOn, &temp.LastError, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) ClaimWebhookDelivery(w *model.WebhookDelivery, until int64) (bool, error) {
+	st, err := d.prepare("UPDATE webhook_delivery SET next_attempt_on = ? WHERE id = ? AND next_attempt_on = ?")
+	if err != nil {
+		return false, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(until, w.ID, w.NextAttemptOn)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+func (d *Database) UpdateWebhookDelivery(w *model.WebhookDelivery) error {
+	st, err := d.prepare("UPDATE webhook_delivery SET attempts = ?, next_attempt_on = ?, last_error = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(w.Attempts, w.NextAttemptOn, w.LastError, w.ID)
+	return err
+}
+
+func (d *Database) DeleteWebhookDelivery(id int64) error {
+	st, err := d.prepare("DELETE FROM webhook_delivery WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(id)
+	return err
+}
+
+func (d *Database) InsertDomainChange(c *model.DomainChange) (int64, error) {
+	st, err := d.prepare("INSERT INTO domain_change (fqdn, created_on) VALUES (?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(c.Fqdn, c.CreatedOn)
+	if err != nil {
+		return 0, err
+	}
+	return r.LastInsertId()
+}
+
+func (d *Database) ListDomainChanges(fqdn string, afterID int64, limit int) ([]*model.DomainChange, error) {
+	result := make([]*model.DomainChange, 0)
+	query := "SELECT id, fqdn, created_on FROM domain_change WHERE id > ? ORDER BY id LIMIT ?"
+	args := []interface{}{afterID, limit}
+	if fqdn != "" {
+		query = "SELECT id, fqdn, created_on FROM domain_change WHERE fqdn = ? AND id > ? ORDER BY id LIMIT ?"
+		args = []interface{}{fqdn, afterID, limit}
+	}
+	st, err := d.prepare(query)
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.DomainChange{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) QueryDomainChangeRange() (int64, int64, error) {
+	st, err := d.prepare("SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM domain_change")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer st.Close()
+
+	var first, last int64
+	if err := st.QueryRow().Scan(&first, &last); err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+func (d *Database) DeleteExpiredDomainChanges(t *time.Time) error {
+	_, last, err := d.QueryDomainChangeRange()
+	if err != nil {
+		return err
+	}
+
+	st, err := d.prepare("DELETE FROM domain_change WHERE created_on <= ? AND id < ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(t.UnixNano(), last)
+	return err
+}
+
+func (d *Database) Ping(ctx context.Context) error {
+	return d.Db.PingContext(ctx)
+}
+
 func (d *Database) Close() error {
 	return d.Db.Close()
 }
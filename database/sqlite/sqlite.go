@@ -0,0 +1,746 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+
+	// in order to make build through
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverName = "sqlite"
+
+	maxOpenConnections = 16
+	maxIdleConnections = 16
+)
+
+// Pragmas are applied to every connection, foreign keys make the records cascade-deleted with their token
+var pragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
+type Database struct {
+	Db *sql.DB
+}
+
+func NewDatabase(dsn string) (*Database, error) {
+	db, err := sql.Open(DriverName, withPragmas(dsn))
+	if err != nil {
+		return &Database{}, err
+	}
+
+	db.SetMaxOpenConns(maxOpenConnections)
+	db.SetMaxIdleConns(maxIdleConnections)
+
+	if err := db.Ping(); err != nil {
+		return &Database{}, err
+	}
+
+	return &Database{db}, err
+}
+
+func (d *Database) InsertFrozen(prefix string) error {
+	st, err := d.Db.Prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(prefix, time.Now().UnixNano())
+	return err
+}
+
+func (d *Database) QueryFrozen(prefix string) (string, error) {
+	st, err := d.Db.Prepare("SELECT prefix FROM frozen_prefix WHERE prefix = ?")
+	if err != nil {
+		return "", err
+	}
+	defer st.Close()
+
+	var result string
+	if err := st.QueryRow(prefix).Scan(&result); err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+func (d *Database) RenewFrozen(prefix string) error {
+	st, err := d.Db.Prepare("UPDATE frozen_prefix SET created_on = ? WHERE prefix = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(time.Now().UnixNano(), prefix)
+	return err
+}
+
+func (d *Database) DeleteFrozen(prefix string) error {
+	st, err := d.Db.Prepare("DELETE FROM frozen_prefix WHERE prefix = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(prefix)
+	return err
+}
+
+func (d *Database) DeleteExpiredFrozen(t *time.Time) error {
+	st, err := d.Db.Prepare("DELETE FROM frozen_prefix WHERE created_on <= ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(t.UnixNano())
+	return err
+}
+
+func (d *Database) MigrateFrozen(prefix string, expiration int64) error {
+	st, err := d.Db.Prepare("INSERT INTO frozen_prefix (prefix, created_on) VALUES ( ?, ? )")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(prefix, expiration)
+	return err
+}
+
+func (d *Database) InsertToken(token, name string) (int64, error) {
+	return d.insert("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )", token, name, time.Now().UnixNano())
+}
+
+func (d *Database) QueryTokenCount() (int64, error) {
+	st, err := d.Db.Prepare("SELECT count(*) FROM token")
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	var result int64
+	if err := st.QueryRow().Scan(&result); err != nil {
+		return 0, err
+	}
+
+	return result, nil
+}
+
+func (d *Database) QueryToken(name string) (*model.Token, error) {
+	r := &model.Token{}
+	st, err := d.Db.Prepare("SELECT id, token, fqdn, created_on FROM token WHERE fqdn = ?")
+	if err != nil {
+		return r, err
+	}
+	defer st.Close()
+
+	if err := st.QueryRow(name).Scan(&r.ID, &r.Token, &r.Fqdn, &r.CreatedOn); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+func (d *Database) QueryExpiredTokens(t *time.Time) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	st, err := d.Db.Prepare("SELECT id, token, fqdn, created_on FROM token WHERE created_on <= ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(t.UnixNano())
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) ListTokens(suffix string, createdBefore *time.Time, afterID int64, limit int) ([]*model.Token, error) {
+	result := make([]*model.Token, 0)
+	st, err := d.Db.Prepare("SELECT * FROM token WHERE id > ? AND fqdn LIKE ? AND created_on <= ? ORDER BY id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	before := int64(math.MaxInt64)
+	if createdBefore != nil {
+		before = createdBefore.UnixNano()
+	}
+
+	rows, err := st.Query(afterID, "%"+suffix, before, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.Token{}
+		if err := rows.Scan(&temp.ID, &temp.Token, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) RenewToken(name string) (int64, int64, error) {
+	t := time.Now().UnixNano()
+	id, err := d.update("UPDATE token SET created_on = ? WHERE fqdn = ? RETURNING id", t, name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, t, nil
+}
+
+func (d *Database) UpdateToken(token, name string) error {
+	st, err := d.Db.Prepare("UPDATE token SET token = ? WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(token, name)
+	return err
+}
+
+func (d *Database) DeleteToken(token string) error {
+	st, err := d.Db.Prepare("DELETE FROM token WHERE token = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(token)
+	return err
+}
+
+func (d *Database) MigrateToken(token, name string, expiration int64) error {
+	_, err := d.insert("INSERT INTO token (token, fqdn, created_on) VALUES( ?, ?, ? )", token, name, expiration)
+	return err
+}
+
+func (d *Database) InsertSubToken(t *model.SubToken) (int64, error) {
+	return d.insert("INSERT INTO sub_token (sid, name, scope, digest, created_on, tid) VALUES (?, ?, ?, ?, ?, ?)", t.SID, t.Name, t.Scope, t.Digest, t.CreatedOn, t.TID)
+}
+
+func (d *Database) ListSubTokens(id int64) ([]*model.SubToken, error) {
+	result := make([]*model.SubToken, 0)
+	st, err := d.Db.Prepare("SELECT id, sid, name, scope, digest, created_on, tid FROM sub_token WHERE tid = ? ORDER BY id")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(id)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.SubToken{}
+		if err := rows.Scan(&temp.ID, &temp.SID, &temp.Name, &temp.Scope, &temp.Digest, &temp.CreatedOn, &temp.TID); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) DeleteSubToken(sid string) error {
+	st, err := d.Db.Prepare("DELETE FROM sub_token WHERE sid = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(sid)
+	return err
+}
+
+func (d *Database) InsertA(a *model.RecordA) (int64, error) {
+	return d.insert("INSERT INTO record_a (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)",
+		a.Fqdn, a.Type, a.Content, a.CreatedOn, a.TID)
+}
+
+func (d *Database) QueryA(name string) (*model.RecordA, error) {
+	r := &model.RecordA{}
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, tid FROM record_a WHERE fqdn = ?")
+	if err != nil {
+		return r, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&r.ID, &r.Fqdn, &r.Type, &r.Content, &r.CreatedOn, &r.UpdatedOn, &r.TID); err != nil {
+			return r, err
+		}
+	}
+
+	return r, rows.Err()
+}
+
+func (d *Database) UpdateA(a *model.RecordA) (int64, error) {
+	return d.update("UPDATE record_a SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ? RETURNING id",
+		a.Type, a.Content, a.CreatedOn, a.TID, a.Fqdn)
+}
+
+func (d *Database) DeleteA(name string) error {
+	st, err := d.Db.Prepare("DELETE FROM record_a WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name)
+	return err
+}
+
+func (d *Database) InsertSubA(a *model.SubRecordA) (int64, error) {
+	return d.insert("INSERT INTO sub_record_a (fqdn, type, content, created_on, pid) VALUES (?, ?, ?, ?, ?)",
+		a.Fqdn, a.Type, a.Content, a.CreatedOn, a.PID)
+}
+
+func (d *Database) UpdateSubA(a *model.SubRecordA) (int64, error) {
+	return d.update("UPDATE sub_record_a SET type = ?, content = ?, created_on = ?, pid = ? WHERE fqdn = ? RETURNING id",
+		a.Type, a.Content, a.CreatedOn, a.PID, a.Fqdn)
+}
+
+func (d *Database) QuerySubA(name string) (*model.SubRecordA, error) {
+	r := &model.SubRecordA{}
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, pid FROM sub_record_a WHERE fqdn = ?")
+	if err != nil {
+		return r, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&r.ID, &r.Fqdn, &r.Type, &r.Content, &r.CreatedOn, &r.UpdatedOn, &r.PID); err != nil {
+			return r, err
+		}
+	}
+
+	return r, rows.Err()
+}
+
+func (d *Database) ListSubA(id int64) ([]*model.SubRecordA, error) {
+	rs := make([]*model.SubRecordA, 0)
+
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, pid FROM sub_record_a WHERE pid = ?")
+	if err != nil {
+		return rs, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(id)
+	if err != nil {
+		return rs, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &model.SubRecordA{}
+		if err := rows.Scan(&r.ID, &r.Fqdn, &r.Type, &r.Content, &r.CreatedOn, &r.UpdatedOn, &r.PID); err != nil {
+			return rs, err
+		}
+		rs = append(rs, r)
+	}
+
+	return rs, rows.Err()
+}
+
+func (d *Database) DeleteSubA(name string) error {
+	st, err := d.Db.Prepare("DELETE FROM sub_record_a WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name)
+	return err
+}
+
+func (d *Database) InsertCNAME(c *model.RecordCNAME) (int64, error) {
+	return d.insert("INSERT INTO record_cname (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)",
+		c.Fqdn, c.Type, c.Content, c.CreatedOn, c.TID)
+}
+
+func (d *Database) UpdateCNAME(c *model.RecordCNAME) (int64, error) {
+	return d.update("UPDATE record_cname SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ? RETURNING id",
+		c.Type, c.Content, c.CreatedOn, c.TID, c.Fqdn)
+}
+
+func (d *Database) QueryCNAME(name string) (*model.RecordCNAME, error) {
+	r := &model.RecordCNAME{}
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, tid FROM record_cname WHERE fqdn = ?")
+	if err != nil {
+		return r, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&r.ID, &r.Fqdn, &r.Type, &r.Content, &r.CreatedOn, &r.UpdatedOn, &r.TID); err != nil {
+			return r, err
+		}
+	}
+
+	return r, rows.Err()
+}
+
+func (d *Database) DeleteCNAME(name string) error {
+	st, err := d.Db.Prepare("DELETE FROM record_cname WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name)
+	return err
+}
+
+func (d *Database) InsertTXT(a *model.RecordTXT) (int64, error) {
+	return d.insert("INSERT INTO record_txt (fqdn, type, content, created_on, tid) VALUES (?, ?, ?, ?, ?)",
+		a.Fqdn, a.Type, a.Content, a.CreatedOn, a.TID)
+}
+
+func (d *Database) UpdateTXT(a *model.RecordTXT) (int64, error) {
+	return d.update("UPDATE record_txt SET type = ?, content = ?, created_on = ?, tid = ? WHERE fqdn = ? RETURNING id",
+		a.Type, a.Content, a.CreatedOn, a.TID, a.Fqdn)
+}
+
+func (d *Database) DeleteTXT(name string) error {
+	st, err := d.Db.Prepare("DELETE FROM record_txt WHERE fqdn = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(name)
+	return err
+}
+
+func (d *Database) QueryTXT(name string) (*model.RecordTXT, error) {
+	r := &model.RecordTXT{}
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, tid FROM record_txt WHERE fqdn = ?")
+	if err != nil {
+		return r, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(name)
+	if err != nil {
+		return r, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(&r.ID, &r.Fqdn, &r.Type, &r.Content, &r.CreatedOn, &r.UpdatedOn, &r.TID); err != nil {
+			return r, err
+		}
+	}
+
+	return r, rows.Err()
+}
+
+func (d *Database) QueryExpiredTXTs(id int64) ([]*model.RecordTXT, error) {
+	result := make([]*model.RecordTXT, 0)
+	st, err := d.Db.Prepare("SELECT id, fqdn, type, content, created_on, updated_on, tid FROM record_txt WHERE tid = ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(id)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.RecordTXT{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.Type, &temp.Content, &temp.CreatedOn, &temp.UpdatedOn, &temp.TID); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) InsertAuditLog(l *model.AuditLog) (int64, error) {
+	return d.insert("INSERT INTO audit_log (fqdn, action, content, created_on) VALUES (?, ?, ?, ?)", l.Fqdn, l.Action, l.Content, l.CreatedOn)
+}
+
+func (d *Database) ListAuditLogs(fqdn string, since, until *time.Time, limit int) ([]*model.AuditLog, error) {
+	result := make([]*model.AuditLog, 0)
+	st, err := d.Db.Prepare("SELECT id, fqdn, action, content, created_on FROM audit_log WHERE fqdn = ? AND created_on >= ? AND created_on <= ? ORDER BY id DESC LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	from, to := int64(0), int64(math.MaxInt64)
+	if since != nil {
+		from = since.UnixNano()
+	}
+	if until != nil {
+		to = until.UnixNano()
+	}
+
+	rows, err := st.Query(fqdn, from, to, limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.AuditLog{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.Action, &temp.Content, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) InsertWebhookDelivery(w *model.WebhookDelivery) (int64, error) {
+	return d.insert("INSERT INTO webhook_delivery (endpoint, event, content, attempts, next_attempt_on, last_error, created_on) VALUES (?, ?, ?, ?, ?, ?, ?)", w.Endpoint, w.Event, w.Content, w.Attempts, w.NextAttemptOn, w.LastError, w.CreatedOn)
+}
+
+func (d *Database) ListDueWebhookDeliveries(due *time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	result := make([]*model.WebhookDelivery, 0)
+	st, err := d.Db.Prepare("SELECT id, endpoint, event, content, attempts, next_attempt_on, last_error, created_on FROM webhook_delivery WHERE next_attempt_on <= ? ORDER BY next_attempt_on, id LIMIT ?")
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(due.UnixNano(), limit)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.WebhookDelivery{}
+		if err := rows.Scan(&temp.ID, &temp.Endpoint, &temp.Event, &temp.Content, &temp.Attempts, &temp.NextAttemptOn, &temp.LastError, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) ClaimWebhookDelivery(w *model.WebhookDelivery, until int64) (bool, error) {
+	st, err := d.Db.Prepare("UPDATE webhook_delivery SET next_attempt_on = ? WHERE id = ? AND next_attempt_on = ?")
+	if err != nil {
+		return false, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(until, w.ID, w.NextAttemptOn)
+	if err != nil {
+		return false, err
+	}
+	n, err := r.RowsAffected()
+	return n == 1, err
+}
+
+func (d *Database) UpdateWebhookDelivery(w *model.WebhookDelivery) error {
+	st, err := d.Db.Prepare("UPDATE webhook_delivery SET attempts = ?, next_attempt_on = ?, last_error = ? WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(w.Attempts, w.NextAttemptOn, w.LastError, w.ID)
+	return err
+}
+
+func (d *Database) DeleteWebhookDelivery(id int64) error {
+	st, err := d.Db.Prepare("DELETE FROM webhook_delivery WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(id)
+	return err
+}
+
+func (d *Database) InsertDomainChange(c *model.DomainChange) (int64, error) {
+	return d.insert("INSERT INTO domain_change (fqdn, created_on) VALUES (?, ?)", c.Fqdn, c.CreatedOn)
+}
+
+func (d *Database) ListDomainChanges(fqdn string, afterID int64, limit int) ([]*model.DomainChange, error) {
+	result := make([]*model.DomainChange, 0)
+	query := "SELECT id, fqdn, created_on FROM domain_change WHERE id > ? ORDER BY id LIMIT ?"
+	args := []interface{}{afterID, limit}
+	if fqdn != "" {
+		query = "SELECT id, fqdn, created_on FROM domain_change WHERE fqdn = ? AND id > ? ORDER BY id LIMIT ?"
+		args = []interface{}{fqdn, afterID, limit}
+	}
+	st, err := d.Db.Prepare(query)
+	if err != nil {
+		return result, err
+	}
+	defer st.Close()
+
+	rows, err := st.Query(args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		temp := &model.DomainChange{}
+		if err := rows.Scan(&temp.ID, &temp.Fqdn, &temp.CreatedOn); err != nil {
+			return result, err
+		}
+		result = append(result, temp)
+	}
+
+	return result, rows.Err()
+}
+
+func (d *Database) QueryDomainChangeRange() (int64, int64, error) {
+	st, err := d.Db.Prepare("SELECT COALESCE(MIN(id), 0), COALESCE(MAX(id), 0) FROM domain_change")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer st.Close()
+
+	var first, last int64
+	if err := st.QueryRow().Scan(&first, &last); err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
+
+func (d *Database) DeleteExpiredDomainChanges(t *time.Time) error {
+	_, last, err := d.QueryDomainChangeRange()
+	if err != nil {
+		return err
+	}
+
+	st, err := d.Db.Prepare("DELETE FROM domain_change WHERE created_on <= ? AND id < ?")
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	_, err = st.Exec(t.UnixNano(), last)
+	return err
+}
+
+// WithContext returns the database itself, only the statements of mysql are traced.
+func (d *Database) WithContext(ctx context.Context) database.Database {
+	return d
+}
+
+func (d *Database) Ping(ctx context.Context) error {
+	return d.Db.PingContext(ctx)
+}
+
+func (d *Database) Close() error {
+	return d.Db.Close()
+}
+
+// Used to insert a row and return its id
+func (d *Database) insert(query string, args ...interface{}) (int64, error) {
+	st, err := d.Db.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	r, err := st.Exec(args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.LastInsertId()
+}
+
+// Used to update a row and return its id, no matched row is not an error just like mysql
+func (d *Database) update(query string, args ...interface{}) (int64, error) {
+	st, err := d.Db.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	var id int64
+	if err := st.QueryRow(args...).Scan(&id); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Used to append the pragmas to the dsn
+// e.g. /var/lib/rdns/rdns.db => file:/var/lib/rdns/rdns.db?_pragma=foreign_keys(1)&...
+func withPragmas(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+
+	ps := make([]string, 0)
+	for _, p := range pragmas {
+		if !strings.Contains(dsn, strings.Split(p, "(")[0]) {
+			ps = append(ps, "_pragma="+p)
+		}
+	}
+	if len(ps) <= 0 {
+		return dsn
+	}
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+
+	return dsn + sep + strings.Join(ps, "&")
+}
@@ -0,0 +1,99 @@
+package lifecycle
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultTimeout is shorter than the default termination grace period of kubernetes, so the components are stopped before being killed.
+const DefaultTimeout = 25 * time.Second
+
+// StopFunc stops a component, it should return once the component is stopped or the context is done.
+type StopFunc func(ctx context.Context) error
+
+type hook struct {
+	name string
+	stop StopFunc
+}
+
+// Manager stops the components when the server is terminated, the components are stopped one by one
+// in the reverse order of their registration, so a component is stopped before what it depends on.
+type Manager struct {
+	timeout time.Duration
+	hooks   []hook
+	errc    chan error
+	once    sync.Once
+}
+
+// NewManager returns a manager which stops all components within the timeout.
+func NewManager(timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Manager{
+		timeout: timeout,
+		errc:    make(chan error, 1),
+	}
+}
+
+// OnStop registers the stop function of the component.
+func (m *Manager) OnStop(name string, stop StopFunc) {
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go runs the component in the background, the server is shut down if the component fails.
+func (m *Manager) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil {
+			err = errors.Wrapf(err, "%s failed", name)
+			select {
+			case m.errc <- err:
+			default:
+				logrus.Error(err)
+			}
+		}
+	}()
+}
+
+// Wait blocks until SIGTERM or SIGINT is received or a component fails, then the components are stopped.
+// The error of the failed component is returned.
+func (m *Manager) Wait() error {
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigc)
+
+	var err error
+	select {
+	case s := <-sigc:
+		logrus.Infof("received signal %s, shutting down", s)
+	case err = <-m.errc:
+	}
+
+	m.Stop()
+	return err
+}
+
+// Stop stops the components once, components not stopped within the timeout are left behind.
+func (m *Manager) Stop() {
+	m.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+		defer cancel()
+
+		for i := len(m.hooks) - 1; i >= 0; i-- {
+			h := m.hooks[i]
+			start := time.Now()
+			if err := h.stop(ctx); err != nil {
+				logrus.Errorf("failed to stop %s: %v", h.name, err)
+				continue
+			}
+			logrus.Infof("%s is stopped in %s", h.name, time.Since(start).Round(time.Millisecond))
+		}
+	})
+}
@@ -4,8 +4,17 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/rancher/rdns-server/audit"
 	"github.com/rancher/rdns-server/command/etcdv3"
+	"github.com/rancher/rdns-server/command/memory"
+	"github.com/rancher/rdns-server/command/migrate"
 	"github.com/rancher/rdns-server/command/route53"
+	"github.com/rancher/rdns-server/lifecycle"
+	"github.com/rancher/rdns-server/service"
+	"github.com/rancher/rdns-server/slug"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/webhook"
+
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -39,12 +48,186 @@ func main() {
 			Usage:  "used to set listen port.",
 			Value:  ":9333",
 		},
+		cli.DurationFlag{
+			Name:   "shutdown_timeout",
+			EnvVar: "SHUTDOWN_TIMEOUT",
+			Usage:  "used to set how long to wait for requests in flight and background jobs when the server is terminated.",
+			Value:  lifecycle.DefaultTimeout,
+		},
 		cli.StringFlag{
 			Name:   "frozen",
 			EnvVar: "FROZEN",
 			Usage:  "used to set the duration when the domain name can be used again.",
 			Value:  "2160h",
 		},
+		cli.StringFlag{
+			Name:   "admin_keys",
+			EnvVar: "ADMIN_KEYS",
+			Usage:  "used to set admin keys separated by commas, each key is <name>:<key> (e.g. ops:xxxx).",
+		},
+		cli.StringFlag{
+			Name:   "admin_keys_file",
+			EnvVar: "ADMIN_KEYS_FILE",
+			Usage:  "used to set the file of admin keys, one <name>:<key> per line.",
+		},
+		cli.StringFlag{
+			Name:   "tls_cert",
+			EnvVar: "TLS_CERT",
+			Usage:  "used to set the certificate file to serve HTTPS, it is reloaded when the file is changed.",
+		},
+		cli.StringFlag{
+			Name:   "tls_key",
+			EnvVar: "TLS_KEY",
+			Usage:  "used to set the private key file of the certificate.",
+		},
+		cli.StringFlag{
+			Name:   "tls_client_ca",
+			EnvVar: "TLS_CLIENT_CA",
+			Usage:  "used to set the CA file to verify client certificates, admin routes require a client certificate if it is set.",
+		},
+		cli.IntFlag{
+			Name:   "slug_length",
+			EnvVar: "SLUG_LENGTH",
+			Usage:  "used to set the length of random slug names.",
+			Value:  slug.DefaultLength,
+		},
+		cli.StringFlag{
+			Name:   "slug_alphabet",
+			EnvVar: "SLUG_ALPHABET",
+			Usage:  "used to set the lowercase letters and digits of random slug names.",
+			Value:  slug.DefaultAlphabet,
+		},
+		cli.StringFlag{
+			Name:   "slug_prefix",
+			EnvVar: "SLUG_PREFIX",
+			Usage:  "used to set the prefix of random slug names.",
+		},
+		cli.StringFlag{
+			Name:   "slug_suffix",
+			EnvVar: "SLUG_SUFFIX",
+			Usage:  "used to set the suffix of random slug names.",
+		},
+		cli.StringFlag{
+			Name:   "slug_denylist",
+			EnvVar: "SLUG_DENYLIST",
+			Usage:  "used to set the words separated by commas which slug names must not contain.",
+		},
+		cli.StringFlag{
+			Name:   "slug_denylist_file",
+			EnvVar: "SLUG_DENYLIST_FILE",
+			Usage:  "used to set the file of words (one per line) which slug names must not contain.",
+		},
+		cli.IntFlag{
+			Name:   "slug_max_retries",
+			EnvVar: "SLUG_MAX_RETRIES",
+			Usage:  "used to set the max attempts to allocate a random slug name.",
+			Value:  slug.DefaultMaxRetries,
+		},
+		cli.Float64Flag{
+			Name:   "rate_limit_ip",
+			EnvVar: "RATE_LIMIT_IP",
+			Usage:  "used to set the requests per second of each client IP, 0 disables the limit.",
+		},
+		cli.IntFlag{
+			Name:   "rate_limit_ip_burst",
+			EnvVar: "RATE_LIMIT_IP_BURST",
+			Usage:  "used to set the burst requests of each client IP.",
+			Value:  20,
+		},
+		cli.Float64Flag{
+			Name:   "rate_limit_token",
+			EnvVar: "RATE_LIMIT_TOKEN",
+			Usage:  "used to set the requests per second of each bearer token, 0 disables the limit.",
+		},
+		cli.IntFlag{
+			Name:   "rate_limit_token_burst",
+			EnvVar: "RATE_LIMIT_TOKEN_BURST",
+			Usage:  "used to set the burst requests of each bearer token.",
+			Value:  20,
+		},
+		cli.IntFlag{
+			Name:   "create_quota_ip",
+			EnvVar: "CREATE_QUOTA_IP",
+			Usage:  "used to set the domains which each client IP can create per day (UTC), 0 disables the quota.",
+		},
+		cli.StringFlag{
+			Name:   "client_ip_header",
+			EnvVar: "CLIENT_IP_HEADER",
+			Usage:  "used to set the header of the client IP set by the trusted proxy (e.g. X-Forwarded-For), the remote address is used if it is empty.",
+		},
+		cli.StringFlag{
+			Name:   "trusted_proxies",
+			EnvVar: "TRUSTED_PROXIES",
+			Usage:  "used to set the comma separated IPs or CIDRs of the proxies allowed to set the client IP header, it is required by client_ip_header.",
+		},
+		cli.BoolTFlag{
+			Name:   "access_log",
+			EnvVar: "ACCESS_LOG",
+			Usage:  "used to set whether each request is logged as a JSON line to stdout.",
+		},
+		cli.StringFlag{
+			Name:   "trace_exporter",
+			EnvVar: "TRACE_EXPORTER",
+			Usage:  "used to set where the spans are exported (none, stdout or otlp).",
+			Value:  tracing.ExporterNone,
+		},
+		cli.StringFlag{
+			Name:   "trace_endpoint",
+			EnvVar: "TRACE_ENDPOINT",
+			Usage:  "used to set the OTLP/HTTP traces endpoint of the otlp exporter, spans are posted in the JSON encoding.",
+			Value:  tracing.DefaultEndpoint,
+		},
+		cli.Float64Flag{
+			Name:   "trace_sample_ratio",
+			EnvVar: "TRACE_SAMPLE_RATIO",
+			Usage:  "used to set the ratio of the traces started by the server which are sampled, the traces of clients follow their decisions.",
+			Value:  1,
+		},
+		cli.StringFlag{
+			Name:   "audit_sink",
+			EnvVar: "AUDIT_SINK",
+			Usage:  "used to set where the audit events of mutating API calls are written (stdout, file or database), auditing is disabled if it is empty.",
+		},
+		cli.StringFlag{
+			Name:   "audit_file",
+			EnvVar: "AUDIT_FILE",
+			Usage:  "used to set the JSON lines file of the file audit sink.",
+			Value:  "/var/log/rdns/audit.log",
+		},
+		cli.Int64Flag{
+			Name:   "audit_file_max_size",
+			EnvVar: "AUDIT_FILE_MAX_SIZE",
+			Usage:  "used to set the megabytes of the audit file before it is rotated.",
+			Value:  100,
+		},
+		cli.IntFlag{
+			Name:   "audit_file_max_backups",
+			EnvVar: "AUDIT_FILE_MAX_BACKUPS",
+			Usage:  "used to set the number of rotated audit files to keep.",
+			Value:  10,
+		},
+		cli.StringFlag{
+			Name:   "webhook_endpoints",
+			EnvVar: "WEBHOOK_ENDPOINTS",
+			Usage:  "used to set the comma separated URLs receiving domain events, an endpoint may subscribe some events (e.g. https://example.com/hook;events=domain.created|domain.purged), webhooks are disabled if it is empty.",
+		},
+		cli.StringFlag{
+			Name:   "webhook_secret",
+			EnvVar: "WEBHOOK_SECRET",
+			Usage:  "used to set the secret signing the events by HMAC-SHA256, it is required if webhook endpoints are set.",
+		},
+		cli.DurationFlag{
+			Name:   "webhook_expiring_within",
+			EnvVar: "WEBHOOK_EXPIRING_WITHIN",
+			Usage:  "used to set how long before the expiration a domain is notified as expiring.",
+			Value:  webhook.DefaultExpiringWithin,
+		},
+		cli.IntFlag{
+			Name:   "webhook_max_attempts",
+			EnvVar: "WEBHOOK_MAX_ATTEMPTS",
+			Usage:  "used to set the number of attempts to post an event before it is given up.",
+			Value:  webhook.DefaultMaxAttempts,
+		},
 	}
 	app.Commands = []cli.Command{
 		{
@@ -61,6 +244,18 @@ func main() {
 			Flags:   etcdv3.Flags(),
 			Action:  etcdv3.Action,
 		},
+		{
+			Name:    "memory",
+			Aliases: []string{"mem"},
+			Usage:   "use in-memory backend for tests and local development",
+			Flags:   memory.Flags(),
+			Action:  memory.Action,
+		},
+		{
+			Name:        "migrate",
+			Usage:       "manage database migrations",
+			Subcommands: migrate.Subcommands(),
+		},
 	}
 	if err := app.Run(os.Args); err != nil {
 		logrus.Fatal(err)
@@ -71,11 +266,92 @@ func beforeFunc(c *cli.Context) error {
 	if os.Getuid() != 0 {
 		logrus.Fatalf("%s: need to be root", os.Args[0])
 	}
+	if err := service.SetAdminKeys(c.GlobalString("admin_keys"), c.GlobalString("admin_keys_file")); err != nil {
+		return err
+	}
+	if err := service.SetTLSOptions(&service.TLSOptions{
+		CertFile:     c.GlobalString("tls_cert"),
+		KeyFile:      c.GlobalString("tls_key"),
+		ClientCAFile: c.GlobalString("tls_client_ca"),
+	}); err != nil {
+		return err
+	}
+	if err := service.SetRateLimits(&service.RateLimitOptions{
+		IPRate:      c.GlobalFloat64("rate_limit_ip"),
+		IPBurst:     c.GlobalInt("rate_limit_ip_burst"),
+		TokenRate:   c.GlobalFloat64("rate_limit_token"),
+		TokenBurst:  c.GlobalInt("rate_limit_token_burst"),
+		CreateQuota: c.GlobalInt("create_quota_ip"),
+	}); err != nil {
+		return err
+	}
+	if err := service.SetClientIP(c.GlobalString("client_ip_header"), c.GlobalString("trusted_proxies")); err != nil {
+		return err
+	}
+	service.SetAccessLog(c.GlobalBoolT("access_log"))
+	if err := tracing.Setup(&tracing.Options{
+		Exporter:    c.GlobalString("trace_exporter"),
+		Endpoint:    c.GlobalString("trace_endpoint"),
+		SampleRatio: c.GlobalFloat64("trace_sample_ratio"),
+	}); err != nil {
+		return err
+	}
+	if err := setAuditSink(c); err != nil {
+		return err
+	}
+	if err := setWebhooks(c); err != nil {
+		return err
+	}
+	return setSlugPolicy(c)
+}
+
+func setSlugPolicy(c *cli.Context) error {
+	denylist, err := slug.ParseDenylist(c.GlobalString("slug_denylist"), c.GlobalString("slug_denylist_file"))
+	if err != nil {
+		return err
+	}
+
+	return slug.SetPolicy(&slug.Policy{
+		Length:     c.GlobalInt("slug_length"),
+		Alphabet:   c.GlobalString("slug_alphabet"),
+		Prefix:     c.GlobalString("slug_prefix"),
+		Suffix:     c.GlobalString("slug_suffix"),
+		Denylist:   denylist,
+		MaxRetries: c.GlobalInt("slug_max_retries"),
+	})
+}
+
+func setAuditSink(c *cli.Context) error {
+	s, err := audit.NewSink(&audit.Options{
+		Sink:           c.GlobalString("audit_sink"),
+		File:           c.GlobalString("audit_file"),
+		FileMaxSize:    c.GlobalInt64("audit_file_max_size") * 1024 * 1024,
+		FileMaxBackups: c.GlobalInt("audit_file_max_backups"),
+	})
+	if err != nil {
+		return err
+	}
+
+	audit.SetSink(s)
 	return nil
 }
 
+func setWebhooks(c *cli.Context) error {
+	endpoints, err := webhook.ParseEndpoints(c.GlobalString("webhook_endpoints"))
+	if err != nil {
+		return err
+	}
+
+	return webhook.SetOptions(&webhook.Options{
+		Endpoints:      endpoints,
+		Secret:         c.GlobalString("webhook_secret"),
+		ExpiringWithin: c.GlobalDuration("webhook_expiring_within"),
+		MaxAttempts:    c.GlobalInt("webhook_max_attempts"),
+	})
+}
+
 func versionPrinter(c *cli.Context) {
-	if _, err := fmt.Fprintf(c.App.Writer, DNSVersion); err != nil {
+	if _, err := fmt.Fprint(c.App.Writer, DNSVersion); err != nil {
 		logrus.Error(err)
 	}
 }
@@ -1,10 +1,10 @@
 package metric
 
 import (
+	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/rancher/rdns-server/backend"
-
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/sirupsen/logrus"
@@ -13,24 +13,170 @@ import (
 var (
 	queryDuration = 5 * time.Second
 
+	httpMethods = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodPost:    true,
+		http.MethodPut:     true,
+		http.MethodPatch:   true,
+		http.MethodDelete:  true,
+		http.MethodOptions: true,
+	}
+
 	tokenGauge = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "rancher_dns_tokens",
 		Help: "The number of the rancher dns tokens",
 	})
+
+	slugAttempts = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "rancher_dns_slug_allocation_attempts",
+		Help:    "The number of attempts to allocate a random slug",
+		Buckets: []float64{1, 2, 3, 5, 10, 25, 50, 100},
+	})
+
+	slugFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rancher_dns_slug_allocation_failures_total",
+		Help: "The number of random slug allocations which failed after all attempts",
+	})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_rate_limited_requests_total",
+		Help: "The number of requests rejected by rate limits and quotas",
+	}, []string{"limit"})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_http_requests_total",
+		Help: "The number of HTTP requests by route name, method and status code",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rancher_dns_http_request_duration_seconds",
+		Help:    "The latency of HTTP requests by route name and method",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	backendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rancher_dns_backend_request_duration_seconds",
+		Help:    "The latency of the requests to route53 or etcd by operation, retries are included",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"backend", "operation"})
+
+	backendErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_backend_request_errors_total",
+		Help: "The number of the requests to route53 or etcd which failed",
+	}, []string{"backend", "operation"})
+
+	backendThrottles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_backend_throttled_requests_total",
+		Help: "The number of the request attempts throttled by route53",
+	}, []string{"backend", "operation"})
+
+	databaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rancher_dns_database_query_duration_seconds",
+		Help:    "The latency of the database calls by driver and call",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"driver", "query"})
+
+	databaseErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_database_query_errors_total",
+		Help: "The number of the database calls which failed, missing rows are not counted",
+	}, []string{"driver", "query"})
+
+	purgeDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "rancher_dns_purge_duration_seconds",
+		Help:    "The duration of the purge runs",
+		Buckets: prometheus.ExponentialBuckets(0.1, 4, 8),
+	})
+
+	purgeExpired = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rancher_dns_purge_expired_tokens_total",
+		Help: "The number of the expired domains purged",
+	})
+
+	purgeFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "rancher_dns_purge_failures_total",
+		Help: "The number of the failures of the purge runs",
+	})
+
+	webhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rancher_dns_webhook_deliveries_total",
+		Help: "The number of the webhook delivery attempts by event and result (success, retry or failure)",
+	}, []string{"event", "result"})
 )
 
-func StartMetricDaemon(done chan struct{}) {
+// ObserveSlugAllocation records the attempts of a random slug allocation, the allocation is failed if ok is false.
+func ObserveSlugAllocation(attempts int, ok bool) {
+	slugAttempts.Observe(float64(attempts))
+	if !ok {
+		slugFailures.Inc()
+	}
+}
+
+// ObserveRateLimited records a request rejected by the limit, the limit is ip, token or create_quota.
+func ObserveRateLimited(limit string) {
+	rateLimited.WithLabelValues(limit).Inc()
+}
+
+// ObserveRequest records an HTTP request, the route is empty if no route matches the request.
+func ObserveRequest(route, method string, status int, d time.Duration) {
+	if route == "" {
+		route = "none"
+	}
+	// clients can send any method, so the labels are bounded
+	if !httpMethods[method] {
+		method = "OTHER"
+	}
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+// ObserveBackendRequest records a request to the service of the backend.
+func ObserveBackendRequest(backend, operation string, d time.Duration, failed bool) {
+	backendDuration.WithLabelValues(backend, operation).Observe(d.Seconds())
+	if failed {
+		backendErrors.WithLabelValues(backend, operation).Inc()
+	}
+}
+
+// ObserveBackendThrottle records a request attempt throttled by the service of the backend.
+func ObserveBackendThrottle(backend, operation string) {
+	backendThrottles.WithLabelValues(backend, operation).Inc()
+}
+
+// ObserveDatabaseQuery records a database call, the query is the name of the call.
+func ObserveDatabaseQuery(driver, query string, d time.Duration, failed bool) {
+	databaseDuration.WithLabelValues(driver, query).Observe(d.Seconds())
+	if failed {
+		databaseErrors.WithLabelValues(driver, query).Inc()
+	}
+}
+
+// ObservePurge records a purge run with the number of domains purged and failures.
+func ObservePurge(d time.Duration, purged, failures int) {
+	purgeDuration.Observe(d.Seconds())
+	purgeExpired.Add(float64(purged))
+	purgeFailures.Add(float64(failures))
+}
+
+// ObserveWebhookDelivery records a delivery attempt of the event, the result is success, retry or failure.
+func ObserveWebhookDelivery(event, result string) {
+	webhookDeliveries.WithLabelValues(event, result).Inc()
+}
+
+// StartMetricDaemon counts the tokens periodically until done is closed, the tokens of all zones are kept in one store
+// so the count of the default zone backend is the total.
+func StartMetricDaemon(done chan struct{}, countTokens func() (int64, error)) {
 	for {
+		count, err := countTokens()
+		if err != nil {
+			logrus.Errorf("failed to count token numbers: %s", err.Error())
+		}
+		tokenGauge.Set(float64(count))
+
 		select {
 		case <-done:
 			return
-		default:
-			count, err := backend.GetBackend().GetTokenCount()
-			if err != nil {
-				logrus.Errorf("failed to count token numbers: %s", err.Error())
-			}
-			tokenGauge.Set(float64(count))
-			time.Sleep(queryDuration)
+		case <-time.After(queryDuration):
 		}
 	}
 }
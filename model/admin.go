@@ -0,0 +1,158 @@
+package model
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// ListOptions filters the domains listed by the admin API, the marker is the next value of the previous page.
+type ListOptions struct {
+	Zone           string
+	Type           string
+	Host           string
+	CreatedBefore  *time.Time
+	ExpiringBefore *time.Time
+	Marker         string
+	Limit          int
+}
+
+// TokenInfo is the metadata of the domain token, the token itself is never returned by the admin API.
+// The created time is reset when the domain is renewed, so it is the start of the current lease.
+type TokenInfo struct {
+	Created    *time.Time `json:"created,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	Expired    bool       `json:"expired"`
+}
+
+// DomainInfo is the domain view of the admin API, texts are only set when a single domain is inspected.
+type DomainInfo struct {
+	Fqdn      string              `json:"fqdn"`
+	Type      string              `json:"type,omitempty"`
+	Hosts     []string            `json:"hosts,omitempty"`
+	SubDomain map[string][]string `json:"subdomain,omitempty"`
+	CNAME     string              `json:"cname,omitempty"`
+	Texts     map[string]string   `json:"texts,omitempty"`
+	Token     TokenInfo           `json:"token"`
+}
+
+// Domain returns the domain of the info, the texts are dropped since a domain carries one text.
+func (d *DomainInfo) Domain() *Domain {
+	return &Domain{
+		Fqdn:       d.Fqdn,
+		Hosts:      d.Hosts,
+		SubDomain:  d.SubDomain,
+		CNAME:      d.CNAME,
+		Expiration: d.Token.Expiration,
+	}
+}
+
+// DomainList is a page of domains, next is set if the page is full and should be sent as the marker of the next page.
+type DomainList struct {
+	Items []DomainInfo `json:"items"`
+	Next  string       `json:"next,omitempty"`
+}
+
+func NewTokenInfo(created time.Time, leaseTime time.Duration) TokenInfo {
+	e := created.Add(leaseTime)
+	return TokenInfo{
+		Created:    &created,
+		Expiration: &e,
+		Expired:    e.Before(time.Now()),
+	}
+}
+
+func ParseListOptions(r *http.Request) (*ListOptions, error) {
+	vals := r.URL.Query()
+	errs := make(ValidationErrors, 0)
+
+	opts := &ListOptions{
+		Zone:   vals.Get("zone"),
+		Type:   strings.ToUpper(vals.Get("type")),
+		Host:   vals.Get("host"),
+		Marker: vals.Get("marker"),
+		Limit:  DefaultListLimit,
+	}
+
+	if opts.Type != "" && opts.Type != "A" && opts.Type != "CNAME" {
+		errs.add("type", "must be A or CNAME")
+	}
+	if opts.Host != "" && net.ParseIP(opts.Host) == nil {
+		errs.add("host", "must be an IPv4 or IPv6 address")
+	}
+	opts.CreatedBefore = parseTimeValue(&errs, "created_before", vals.Get("created_before"))
+	opts.ExpiringBefore = parseTimeValue(&errs, "expiring_before", vals.Get("expiring_before"))
+
+	if v := vals.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > MaxListLimit {
+			errs.add("limit", "must be a number between 1 and "+strconv.Itoa(MaxListLimit))
+		}
+		opts.Limit = l
+	}
+
+	return opts, errs.result()
+}
+
+// LeaseStartBefore returns the latest lease start of the domains which may match the time filters, nil if not limited.
+func (o *ListOptions) LeaseStartBefore(leaseTime time.Duration) *time.Time {
+	before := o.CreatedBefore
+	if o.ExpiringBefore != nil {
+		t := o.ExpiringBefore.Add(-leaseTime)
+		if before == nil || t.Before(*before) {
+			before = &t
+		}
+	}
+	return before
+}
+
+// Match reports whether the domain matches the filters, the zone and the marker are applied by backends.
+func (o *ListOptions) Match(d *DomainInfo) bool {
+	if o.Type != "" && d.Type != o.Type {
+		return false
+	}
+	if o.CreatedBefore != nil && (d.Token.Created == nil || d.Token.Created.After(*o.CreatedBefore)) {
+		return false
+	}
+	if o.ExpiringBefore != nil && (d.Token.Expiration == nil || d.Token.Expiration.After(*o.ExpiringBefore)) {
+		return false
+	}
+	if o.Host != "" && !d.hasHost(net.ParseIP(o.Host)) {
+		return false
+	}
+	return true
+}
+
+// Used to check whether the domain or its sub domains point to the host
+func (d *DomainInfo) hasHost(host net.IP) bool {
+	hosts := append([]string{}, d.Hosts...)
+	for _, v := range d.SubDomain {
+		hosts = append(hosts, v...)
+	}
+	for _, h := range hosts {
+		if host.Equal(net.ParseIP(h)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Used to parse a RFC3339 time of the query, nil is returned if the value is empty or invalid
+func parseTimeValue(errs *ValidationErrors, field, value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		errs.add(field, "must be a RFC3339 time")
+		return nil
+	}
+	return &t
+}
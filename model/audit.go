@@ -0,0 +1,72 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEvent is a mutating API call recorded by the audit log, before and after are the records of the domain around the call.
+// The actor is the admin key name of admin routes, tokens are identified by their fingerprints.
+type AuditEvent struct {
+	Time             time.Time `json:"time"`
+	Action           string    `json:"action"`
+	Fqdn             string    `json:"fqdn,omitempty"`
+	ClientIP         string    `json:"client_ip"`
+	Actor            string    `json:"actor,omitempty"`
+	TokenFingerprint string    `json:"token_fingerprint,omitempty"`
+	Before           *Domain   `json:"before,omitempty"`
+	After            *Domain   `json:"after,omitempty"`
+	Status           int       `json:"status"`
+	Result           string    `json:"result"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// AuditQuery filters the audit events of a domain, the newest events are returned first.
+type AuditQuery struct {
+	Fqdn  string
+	Since *time.Time
+	Until *time.Time
+	Limit int
+}
+
+// Match reports whether the event matches the query regardless of the limit.
+func (q *AuditQuery) Match(e *AuditEvent) bool {
+	if e.Fqdn != q.Fqdn {
+		return false
+	}
+	if q.Since != nil && e.Time.Before(*q.Since) {
+		return false
+	}
+	if q.Until != nil && e.Time.After(*q.Until) {
+		return false
+	}
+	return true
+}
+
+func ParseAuditQuery(r *http.Request, fqdn string) (*AuditQuery, error) {
+	vals := r.URL.Query()
+	errs := make(ValidationErrors, 0)
+
+	q := &AuditQuery{
+		Fqdn:  fqdn,
+		Limit: DefaultListLimit,
+	}
+	q.Since = parseTimeValue(&errs, "since", vals.Get("since"))
+	q.Until = parseTimeValue(&errs, "until", vals.Get("until"))
+
+	if v := vals.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > MaxListLimit {
+			errs.add("limit", "must be a number between 1 and "+strconv.Itoa(MaxListLimit))
+		}
+		q.Limit = l
+	}
+
+	return q, errs.result()
+}
@@ -54,3 +54,38 @@ type RecordCNAME struct {
 	UpdatedOn sql.NullInt64 `db:"updated_on"`
 	TID       int64         `db:"tid"`
 }
+
+type SubToken struct {
+	ID        int64  `db:"id"`
+	SID       string `db:"sid"`
+	Name      string `db:"name"`
+	Scope     string `db:"scope"`
+	Digest    string `db:"digest"`
+	CreatedOn int64  `db:"created_on"`
+	TID       int64  `db:"tid"`
+}
+
+type AuditLog struct {
+	ID        int64  `db:"id"`
+	Fqdn      string `db:"fqdn"`
+	Action    string `db:"action"`
+	Content   string `db:"content"`
+	CreatedOn int64  `db:"created_on"`
+}
+
+type WebhookDelivery struct {
+	ID            int64  `db:"id"`
+	Endpoint      string `db:"endpoint"`
+	Event         string `db:"event"`
+	Content       string `db:"content"`
+	Attempts      int    `db:"attempts"`
+	NextAttemptOn int64  `db:"next_attempt_on"`
+	LastError     string `db:"last_error"`
+	CreatedOn     int64  `db:"created_on"`
+}
+
+type DomainChange struct {
+	ID        int64  `db:"id"`
+	Fqdn      string `db:"fqdn"`
+	CreatedOn int64  `db:"created_on"`
+}
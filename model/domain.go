@@ -36,6 +36,8 @@ type DomainOptions struct {
 	Text      string              `json:"text"`
 	CNAME     string              `json:"cname"`
 	Normal    bool                `json:"normal"`
+	Zone      string              `json:"zone"`
+	Name      string              `json:"name"`
 }
 
 func (d *DomainOptions) String() string {
@@ -54,8 +56,10 @@ func (d *DomainOptions) String() string {
 func ParseDomainOptions(r *http.Request) (*DomainOptions, error) {
 	var opts DomainOptions
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&opts)
-	return &opts, err
+	if err := decoder.Decode(&opts); err != nil {
+		return &opts, ValidationErrors{{Field: "body", Reason: err.Error()}}
+	}
+	return &opts, nil
 }
 
 func mapToString(m map[string][]string) string {
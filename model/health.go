@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// CheckResult is the result of a readiness check of a dependency.
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Ready     bool    `json:"ready"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Readiness is the result of all readiness checks, it is ready only if all checks are ready.
+type Readiness struct {
+	Ready     bool          `json:"ready"`
+	CheckedAt time.Time     `json:"checked_at"`
+	Checks    []CheckResult `json:"checks"`
+}
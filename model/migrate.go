@@ -3,6 +3,7 @@ package model
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -15,8 +16,11 @@ type MigrateRecord struct {
 	Expiration *time.Time          `json:"expiration"`
 }
 
+// MigrateFrozen is a frozen slug name, slug names are frozen regardless of the zone
+// so the zone only chooses the backend keeping it, the default zone is used if it is empty.
 type MigrateFrozen struct {
 	Path       string     `json:"path"`
+	Zone       string     `json:"zone"`
 	Expiration *time.Time `json:"expiration"`
 }
 
@@ -26,6 +30,12 @@ type MigrateToken struct {
 	Expiration *time.Time `json:"expiration"`
 }
 
+// Fqdn returns the domain of the token, the path is the etcd key of the token (e.g. /tokenv3/xxxxxx_lb_rancher_cloud) or the domain itself
+func (m *MigrateToken) Fqdn() string {
+	name := m.Path[strings.LastIndex(m.Path, "/")+1:]
+	return strings.Replace(name, "_", ".", -1)
+}
+
 func ParseMigrateRecord(r *http.Request) (*MigrateRecord, error) {
 	var opts MigrateRecord
 	decoder := json.NewDecoder(r.Body)
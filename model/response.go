@@ -5,4 +5,13 @@ type Response struct {
 	Message string `json:"msg"`
 	Data    Domain `json:"data,omitempty"`
 	Token   string `json:"token"`
+
+	Errors ValidationErrors `json:"errors,omitempty"`
+}
+
+// DataResponse is the response of the APIs returning other data than a domain, e.g. the admin APIs and sub tokens.
+type DataResponse struct {
+	Status  int         `json:"status"`
+	Message string      `json:"msg"`
+	Data    interface{} `json:"data,omitempty"`
 }
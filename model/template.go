@@ -1,31 +1,46 @@
 package model
 
 var CoreFileTmpl = `
-. {
-    {{- if and .CoreDNSDBFile .CoreDNSDBZone}}
-    file {{.CoreDNSDBFile}} {{.CoreDNSDBZone}} {
-        reload 0
-    }
-    {{- end}}
-    rdns {{.Domain}} {
-        path {{.EtcdPrefixPath}}
-        endpoint {{.EtcdEndpoints}}
+{{- range .Zones}}
+{{.Domain}} {
+    rdns {
+        path {{$.EtcdPrefixPath}}
+        endpoint {{$.EtcdEndpoints}}
         upstream 8.8.8.8:53 8.8.4.4:53
         wildcardbound {{.WildCardBound}}
     }
-    cache {{.TTL}} {{.Domain}}
+    cache {{.TTL}}
     loadbalance
+    log stdout
+    errors
+}
+{{- end}}
+{{- if and .CoreDNSDBFile .CoreDNSDBZone}}
+{{.CoreDNSDBZone}} {
+    file {{.CoreDNSDBFile}} {
+        reload 0
+    }
+    log stdout
+    errors
+}
+{{- end}}
+. {
     forward . 8.8.8.8:53 8.8.4.4:53
     log stdout
     errors
 }`
 
+// CoreFile is rendered to one server block per zone, so each zone has its own ttl and wildcard bound.
 type CoreFile struct {
 	CoreDNSDBFile  string
 	CoreDNSDBZone  string
-	Domain         string
 	EtcdPrefixPath string
 	EtcdEndpoints  string
-	TTL            string
-	WildCardBound  string
+	Zones          []CoreFileZone
+}
+
+type CoreFileZone struct {
+	Domain        string
+	TTL           string
+	WildCardBound string
 }
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const (
+	ScopeFull     = "full"
+	ScopeRead     = "read"
+	ScopeTXTWrite = "txt:write"
+
+	// SubTokenPrefix tells sub tokens from domain tokens, domain tokens are base64 encoded which never contain underscores
+	SubTokenPrefix = "st_"
+
+	maxSubTokenNameLength = 64
+)
+
+var scopes = map[string]bool{
+	ScopeFull:     true,
+	ScopeRead:     true,
+	ScopeTXTWrite: true,
+}
+
+type SubTokenOptions struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+// SubTokenInfo is the sub token view of the API, the token is only responded when the sub token is created.
+type SubTokenInfo struct {
+	ID      string     `json:"id"`
+	Name    string     `json:"name,omitempty"`
+	Scope   string     `json:"scope"`
+	Created *time.Time `json:"created,omitempty"`
+	Token   string     `json:"token,omitempty"`
+}
+
+func NewSubTokenInfo(t *SubToken) SubTokenInfo {
+	created := time.Unix(0, t.CreatedOn)
+	return SubTokenInfo{
+		ID:      t.SID,
+		Name:    t.Name,
+		Scope:   t.Scope,
+		Created: &created,
+	}
+}
+
+func ParseSubTokenOptions(r *http.Request) (*SubTokenOptions, error) {
+	var opts SubTokenOptions
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&opts); err != nil {
+		return &opts, ValidationErrors{{Field: "body", Reason: err.Error()}}
+	}
+
+	errs := make(ValidationErrors, 0)
+	if !scopes[opts.Scope] {
+		errs.add("scope", "must be one of full, read and txt:write")
+	}
+	if len(opts.Name) > maxSubTokenNameLength {
+		errs.add("name", "must be at most 64 characters")
+	}
+
+	return &opts, errs.result()
+}
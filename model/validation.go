@@ -0,0 +1,143 @@
+package model
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxTextLength is the max length of TXT record, record_txt.content is VARCHAR(255) and keeps the quoted text
+	MaxTextLength = 253
+	// MaxNameLength is the max length of a domain name in text form without the trailing dot
+	MaxNameLength = 253
+)
+
+var (
+	labelRegexp = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+	nameRegexp  = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+)
+
+type ValidationError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationErrors is returned when the request is not valid, each error points to the invalid field.
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	ss := make([]string, 0)
+	for _, e := range v {
+		ss = append(ss, fmt.Sprintf("%s: %s", e.Field, e.Reason))
+	}
+	return fmt.Sprintf("invalid request: %s", strings.Join(ss, "; "))
+}
+
+func (v *ValidationErrors) add(field, reason string) {
+	*v = append(*v, &ValidationError{
+		Field:  field,
+		Reason: reason,
+	})
+}
+
+func (v ValidationErrors) result() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// ValidateHosts checks hosts are IP addresses and sub domain keys are DNS labels.
+func (d *DomainOptions) ValidateHosts() error {
+	errs := make(ValidationErrors, 0)
+
+	validateHosts(&errs, "hosts", d.Hosts)
+
+	keys := make([]string, 0)
+	for k := range d.SubDomain {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		field := fmt.Sprintf("subdomain.%s", k)
+		if !labelRegexp.MatchString(k) {
+			errs.add(field, "must be a DNS label of letters, digits and hyphens with at most 63 characters")
+			continue
+		}
+		validateHosts(&errs, field, d.SubDomain[k])
+	}
+
+	return errs.result()
+}
+
+// ValidateName checks the requested name is a lowercase DNS label, an empty name means a random slug is generated.
+func (d *DomainOptions) ValidateName() error {
+	errs := make(ValidationErrors, 0)
+
+	if d.Name != "" && !nameRegexp.MatchString(d.Name) {
+		errs.add("name", "must be a DNS label of lowercase letters, digits and hyphens with at most 63 characters")
+	}
+
+	return errs.result()
+}
+
+// ValidateText checks the text fits in a TXT record.
+func (d *DomainOptions) ValidateText() error {
+	errs := make(ValidationErrors, 0)
+
+	switch {
+	case d.Text == "":
+		errs.add("text", "must not be empty")
+	case len(d.Text) > MaxTextLength:
+		errs.add("text", fmt.Sprintf("must be at most %d bytes", MaxTextLength))
+	case strings.ContainsAny(d.Text, "\"\\"):
+		errs.add("text", "must not contain double quotes or backslashes")
+	}
+
+	return errs.result()
+}
+
+// ValidateCNAME checks the CNAME target is a hostname.
+func (d *DomainOptions) ValidateCNAME() error {
+	errs := make(ValidationErrors, 0)
+
+	name := strings.TrimSuffix(d.CNAME, ".")
+	switch {
+	case name == "":
+		errs.add("cname", "must not be empty")
+	case len(name) > MaxNameLength:
+		errs.add("cname", fmt.Sprintf("must be at most %d characters", MaxNameLength))
+	case net.ParseIP(name) != nil:
+		errs.add("cname", "must be a hostname instead of an IP address")
+	default:
+		for _, l := range strings.Split(name, ".") {
+			if !labelRegexp.MatchString(l) {
+				errs.add("cname", fmt.Sprintf("label %q must be letters, digits and hyphens with at most 63 characters", l))
+				break
+			}
+		}
+	}
+
+	return errs.result()
+}
+
+// Used to validate hosts are unique IP addresses
+func validateHosts(errs *ValidationErrors, field string, hosts []string) {
+	seen := make(map[string]bool)
+	for i, h := range hosts {
+		f := fmt.Sprintf("%s[%d]", field, i)
+		if net.ParseIP(h) == nil {
+			errs.add(f, "must be an IPv4 or IPv6 address")
+			continue
+		}
+		if seen[h] {
+			errs.add(f, "duplicate host")
+			continue
+		}
+		seen[h] = true
+	}
+}
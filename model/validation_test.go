@@ -0,0 +1,124 @@
+package model
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHosts(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   DomainOptions
+		fields []string
+	}{
+		{
+			name: "valid",
+			opts: DomainOptions{Hosts: []string{"4.4.4.4", "2001:db8::2"}, SubDomain: map[string][]string{"sub1": {"9.9.9.9"}}},
+		},
+		{
+			name:   "invalid host",
+			opts:   DomainOptions{Hosts: []string{"4.4.4.4", "example.com"}},
+			fields: []string{"hosts[1]"},
+		},
+		{
+			name:   "duplicate host",
+			opts:   DomainOptions{Hosts: []string{"4.4.4.4", "4.4.4.4"}},
+			fields: []string{"hosts[1]"},
+		},
+		{
+			name:   "invalid sub domain",
+			opts:   DomainOptions{SubDomain: map[string][]string{"-sub": {"1.1.1.1"}, "sub2": {"x"}}},
+			fields: []string{"subdomain.-sub", "subdomain.sub2[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertFields(t, tt.opts.ValidateHosts(), tt.fields)
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"":                      true,
+		"myteam":                true,
+		"my-team-1":             true,
+		"MyTeam":                false,
+		"-team":                 false,
+		"team.a":                false,
+		strings.Repeat("a", 64): false,
+		strings.Repeat("a", 63): true,
+	} {
+		opts := DomainOptions{Name: name}
+		if err := opts.ValidateName(); (err == nil) != valid {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", name, err, valid)
+		}
+	}
+}
+
+func TestValidateText(t *testing.T) {
+	for text, valid := range map[string]bool{
+		"xxxxxx":                             true,
+		"":                                   false,
+		`a"b`:                                false,
+		`a\b`:                                false,
+		strings.Repeat("a", MaxTextLength):   true,
+		strings.Repeat("a", MaxTextLength+1): false,
+	} {
+		opts := DomainOptions{Text: text}
+		if err := opts.ValidateText(); (err == nil) != valid {
+			t.Errorf("ValidateText(%q) = %v, want valid %v", text, err, valid)
+		}
+	}
+}
+
+func TestValidateCNAME(t *testing.T) {
+	for cname, valid := range map[string]bool{
+		"www.example.com":  true,
+		"www.example.com.": true,
+		"":                 false,
+		"1.1.1.1":          false,
+		"a..example.com":   false,
+		"a_b.example.com":  false,
+	} {
+		opts := DomainOptions{CNAME: cname}
+		if err := opts.ValidateCNAME(); (err == nil) != valid {
+			t.Errorf("ValidateCNAME(%q) = %v, want valid %v", cname, err, valid)
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	opts := DomainOptions{Hosts: []string{"x"}}
+	err := opts.ValidateHosts()
+
+	want := "invalid request: hosts[0]: must be an IPv4 or IPv6 address"
+	if err == nil || err.Error() != want {
+		t.Fatalf("error = %v, want %q", err, want)
+	}
+}
+
+// Used to check the error lists exactly the fields
+func assertFields(t *testing.T, err error, fields []string) {
+	t.Helper()
+	if len(fields) == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("error = %v, want ValidationErrors", err)
+	}
+	if len(errs) != len(fields) {
+		t.Fatalf("error = %v, want fields %v", err, fields)
+	}
+	for i, e := range errs {
+		if e.Field != fields[i] {
+			t.Errorf("field %d = %s, want %s", i, e.Field, fields[i])
+		}
+	}
+}
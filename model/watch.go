@@ -0,0 +1,49 @@
+package model
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const (
+	WatchUpdated  = "updated"
+	WatchDeleted  = "deleted"
+	WatchBookmark = "bookmark"
+
+	// LastEventIDHeader is sent by SSE clients when they reconnect, it is the revision of the last event they received
+	LastEventIDHeader = "Last-Event-ID"
+)
+
+// WatchChange is a change of a domain kept by a backend, the revisions of a backend only increase so a watch can be resumed
+// after the revision of the last change. A change without fqdn is a bookmark of the revision which the watch starts from.
+type WatchChange struct {
+	Revision int64
+	Fqdn     string
+}
+
+// WatchEvent is an event of the watch stream, the domain is read when the event is sent, so it may be newer than the revision.
+type WatchEvent struct {
+	Revision int64   `json:"revision"`
+	Type     string  `json:"type"`
+	Domain   *Domain `json:"domain,omitempty"`
+}
+
+// ParseWatchRevision returns the revision which the watch resumes after, 0 if the watch starts from now.
+// Last-Event-ID wins over the revision query, since it is newer once the client reconnects.
+func ParseWatchRevision(r *http.Request) (int64, error) {
+	errs := make(ValidationErrors, 0)
+
+	field, v := "revision", r.URL.Query().Get("revision")
+	if id := r.Header.Get(LastEventIDHeader); id != "" {
+		field, v = LastEventIDHeader, id
+	}
+	if v == "" {
+		return 0, nil
+	}
+
+	revision, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || revision < 0 {
+		errs.add(field, "must be a non-negative number")
+	}
+	return revision, errs.result()
+}
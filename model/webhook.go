@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	WebhookDomainCreated  = "domain.created"
+	WebhookDomainUpdated  = "domain.updated"
+	WebhookDomainRenewed  = "domain.renewed"
+	WebhookDomainExpiring = "domain.expiring"
+	WebhookDomainPurged   = "domain.purged"
+	WebhookDomainDeleted  = "domain.deleted"
+)
+
+// WebhookEvents are the events which the webhook endpoints can subscribe.
+var WebhookEvents = []string{
+	WebhookDomainCreated,
+	WebhookDomainUpdated,
+	WebhookDomainRenewed,
+	WebhookDomainExpiring,
+	WebhookDomainPurged,
+	WebhookDomainDeleted,
+}
+
+// WebhookEvent is the payload posted to the webhook endpoints, the ID is kept across retries so receivers can drop duplicates.
+type WebhookEvent struct {
+	ID     string    `json:"id"`
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	Domain *Domain   `json:"domain"`
+}
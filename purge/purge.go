@@ -1,88 +1,171 @@
 package purge
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/metric"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/webhook"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
 	flagFrozen            = "FROZEN"
-	flagLeaseTime         = "DATABASE_LEASE_TIME"
 	intervalSeconds int64 = 600
+	// watches can be resumed from the changes kept within the retention
+	changeRetention = 24 * time.Hour
 )
 
-type purger struct {
+// Purger deletes the expired records periodically, a purge in progress is stopped between tokens.
+// The domains which will expire soon are notified by webhooks too, once their notice time is passed.
+type Purger struct {
+	stop    chan struct{}
+	stopped chan struct{}
+	lastRun time.Time
 }
 
-func StartPurgerDaemon(done chan struct{}) {
-	p := &purger{}
-	go wait.JitterUntil(p.purge, time.Duration(intervalSeconds)*time.Second, .1, true, done)
+func StartPurgerDaemon() *Purger {
+	interval := time.Duration(intervalSeconds) * time.Second
+	p := &Purger{
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		// the domains whose notice time is passed while the server was down are notified by the first run
+		lastRun: time.Now().Add(-interval),
+	}
+	go func() {
+		defer close(p.stopped)
+		wait.JitterUntil(p.purge, interval, .1, true, p.stop)
+	}()
+	return p
+}
+
+// Stop stops the purger and waits for the domain being purged until the context is done.
+func (p *Purger) Stop(ctx context.Context) error {
+	close(p.stop)
+	select {
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (p *purger) purge() {
+func (p *Purger) purge() {
 	logrus.Debugf("running purge process")
 
+	// each run is a trace, so a slow run can be attributed to the domains it purged
+	ctx, span := tracing.Start(context.Background(), "purge")
+	start := time.Now()
+	purged, failures := 0, 0
+	defer func() {
+		metric.ObservePurge(time.Since(start), purged, failures)
+		span.SetAttributes(attribute.Int("rdns.purged", purged), attribute.Int("rdns.failures", failures))
+		span.End()
+	}()
+
 	// check frozen records, delete the frozen record which is expired
-	if err := database.GetDatabase().DeleteExpiredFrozen(calculateFrozenTime()); err != nil {
+	if err := database.GetDatabase().WithContext(ctx).DeleteExpiredFrozen(calculateFrozenTime()); err != nil {
+		logrus.Error(err)
+		failures++
+	}
+
+	// check domain changes, delete the change which is older than the retention
+	if err := database.GetDatabase().WithContext(ctx).DeleteExpiredDomainChanges(calculateChangeTime()); err != nil {
 		logrus.Error(err)
+		failures++
 	}
 
 	// check token records, delete the token record which is expired
 	// this ensures that associated records are also deleted
-	tokens, err := database.GetDatabase().QueryExpiredTokens(calculateTTLTime())
+	// zones have their own lease time, so tokens are queried with the shortest one and checked by the zone
+	// the tokens expiring within the notice window are queried too, so they can be notified by webhooks
+	now := time.Now()
+	tokens, err := database.GetDatabase().WithContext(ctx).QueryExpiredTokens(calculateTTLTime(webhook.ExpiringWithin()))
 	if err != nil {
 		logrus.Error(err)
+		failures++
+		return
 	}
+	defer func() {
+		p.lastRun = now
+	}()
 
 	for _, token := range tokens {
-		// delete route53 A records & sub A records & wildcard records
-		opts := &model.DomainOptions{
-			Fqdn: token.Fqdn,
-		}
-		a, err := backend.GetBackend().Get(opts)
-		if err == nil && a.Fqdn != "" {
-			if err := backend.GetBackend().Delete(opts); err != nil {
-				logrus.Error(err)
-				continue
-			}
+		select {
+		case <-p.stop:
+			logrus.Info("purge process is stopped, the remaining expired domains are purged next time")
+			return
+		default:
 		}
 
-		// delete route53 CNAME records
-		cname, err := backend.GetBackend().GetCNAME(opts)
-		if err == nil && cname.Fqdn != "" {
-			if err := backend.GetBackend().DeleteCNAME(opts); err != nil {
-				logrus.Error(err)
-				continue
-			}
+		z, err := backend.MatchZone(token.Fqdn)
+		if err != nil {
+			logrus.Error(err)
+			failures++
+			continue
 		}
-
-		// delete route53 TXT records
-		ts, err := database.GetDatabase().QueryExpiredTXTs(token.ID)
-		for _, t := range ts {
-			tOpts := &model.DomainOptions{
-				Fqdn: t.Fqdn,
-			}
-			if err := backend.GetBackend().DeleteText(tOpts); err != nil {
-				logrus.Error(err)
-				continue
-			}
+		b := z.Backend.WithContext(ctx)
+		expiration := time.Unix(0, token.CreatedOn).Add(z.LeaseTime)
+		if expiration.After(now) {
+			p.notifyExpiring(ctx, b, token, expiration, now)
+			continue
 		}
 
-		// delete token records & referenced records
-		if err := database.GetDatabase().DeleteToken(token.Token); err != nil {
+		// the records are gone once purged, so the domain of the event is inspected before
+		var d *model.Domain
+		if webhook.Enabled() {
+			d = inspect(b, token)
+		}
+		// the domain is force deleted through the zone backend, so the deletion is recorded as one change
+		err = b.ForceDelete(token.Fqdn)
+		if backend.KindOf(err) == backend.KindNotFound {
+			// purged by another server
+			continue
+		}
+		if err != nil {
 			logrus.Error(err)
+			failures++
+			continue
+		}
+		purged++
+		if d != nil {
+			webhook.Notify(ctx, model.WebhookDomainPurged, d)
 		}
 	}
 }
 
+// Used to notify the domain expiring within the notice window, the domain is notified by the first run
+// after its notice time, so it is notified once by each server whose purger runs
+func (p *Purger) notifyExpiring(ctx context.Context, b backend.Backend, token *model.Token, expiration, now time.Time) {
+	if !webhook.Enabled() {
+		return
+	}
+	notice := expiration.Add(-webhook.ExpiringWithin())
+	if !notice.After(p.lastRun) || notice.After(now) {
+		return
+	}
+	webhook.Notify(ctx, model.WebhookDomainExpiring, inspect(b, token))
+}
+
+// Used to get the domain of the token for the events, only the name is known if the backend fails to inspect it
+func inspect(b backend.Backend, token *model.Token) *model.Domain {
+	info, err := b.Inspect(token.Fqdn)
+	if err != nil {
+		logrus.Warnf("failed to inspect %s for webhook event, err: %v", token.Fqdn, err)
+		return &model.Domain{Fqdn: token.Fqdn}
+	}
+	return info.Domain()
+}
+
 func calculateFrozenTime() *time.Time {
 	f, err := time.ParseDuration(os.Getenv(flagFrozen))
 	if err != nil {
@@ -93,12 +176,14 @@ func calculateFrozenTime() *time.Time {
 	return &e
 }
 
-func calculateTTLTime() *time.Time {
-	t, err := time.ParseDuration(os.Getenv(flagLeaseTime))
-	if err != nil {
-		logrus.Fatalf(errEmptyEnv, flagLeaseTime)
-	}
-	duration, _ := time.ParseDuration(fmt.Sprintf("%dns", int(t.Nanoseconds())))
-	e := time.Now().Add(-duration)
+func calculateChangeTime() *time.Time {
+	e := time.Now().Add(-changeRetention)
+	return &e
+}
+
+// Used to calculate the created time of the tokens to check, the tokens created after it are not expired
+// nor expiring within the window under the shortest lease time
+func calculateTTLTime(window time.Duration) *time.Time {
+	e := time.Now().Add(-backend.MinLeaseTime()).Add(window)
 	return &e
 }
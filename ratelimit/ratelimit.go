@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const sweepInterval = time.Minute
+
+// Limiter keeps a token bucket for each key, the buckets which are full again are removed periodically.
+type Limiter struct {
+	limit rate.Limit
+	burst int
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewLimiter returns a limiter allowing r requests per second and bursts of b requests for each key.
+func NewLimiter(r float64, b int) *Limiter {
+	return &Limiter{
+		limit:     rate.Limit(r),
+		burst:     b,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow reports whether a request of the key is allowed, the duration until the next request is allowed is returned if not.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	r := b.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return false, time.Duration(math.MaxInt64)
+	}
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return false, d
+	}
+	return true, 0
+}
+
+// Len returns the number of the keys being tracked.
+func (l *Limiter) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.buckets)
+}
+
+// Used to remove the buckets which are refilled since they were seen, they are the same as new ones
+func (l *Limiter) sweep(now time.Time) {
+	refill := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for k, b := range l.buckets {
+		if now.Sub(b.lastSeen) > refill {
+			delete(l.buckets, k)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Quota counts the usage of each key in a UTC day, the counts are reset when the day changes.
+type Quota struct {
+	limit int
+	now   func() time.Time
+
+	lock   sync.Mutex
+	day    time.Time
+	counts map[string]int
+}
+
+// NewQuota returns a quota allowing n usages of each key per day.
+func NewQuota(n int) *Quota {
+	return &Quota{
+		limit:  n,
+		now:    time.Now,
+		counts: make(map[string]int),
+	}
+}
+
+// Reserve uses the quota of the key once if it has quota left, the duration until the quota is reset is returned if not.
+// The quota is checked and used at once, so concurrent usages of the key never exceed the limit.
+func (q *Quota) Reserve(key string) (bool, time.Duration) {
+	now := q.now().UTC()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.reset(now)
+	if q.counts[key] < q.limit {
+		q.counts[key]++
+		return true, 0
+	}
+	return false, q.day.AddDate(0, 0, 1).Sub(now)
+}
+
+// Refund gives back the quota reserved by the key, e.g. the usage is failed. Nothing is given back once the day changes.
+func (q *Quota) Refund(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.reset(q.now().UTC())
+	if q.counts[key] > 0 {
+		q.counts[key]--
+	}
+}
+
+// Used to reset the counts if the day of now is not the counted day
+func (q *Quota) reset(now time.Time) {
+	day := now.Truncate(24 * time.Hour)
+	if !day.Equal(q.day) {
+		q.day = day
+		q.counts = make(map[string]int)
+	}
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	l := NewLimiter(1, 2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a"); !ok {
+			t.Fatalf("request %d of the burst is rejected", i)
+		}
+	}
+	ok, wait := l.Allow("a")
+	if ok {
+		t.Fatal("request over the burst is allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want (0, 1s]", wait)
+	}
+
+	if ok, _ := l.Allow("b"); !ok {
+		t.Error("request of another key is rejected")
+	}
+	if n := l.Len(); n != 2 {
+		t.Errorf("Len() = %d, want 2", n)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := NewLimiter(1000, 1)
+	l.Allow("a")
+
+	time.Sleep(5 * time.Millisecond)
+	l.sweep(time.Now())
+	if n := l.Len(); n != 0 {
+		t.Errorf("Len() = %d after the bucket is refilled, want 0", n)
+	}
+}
+
+func TestQuotaReserve(t *testing.T) {
+	q := NewQuota(2)
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := q.Reserve("a"); !ok {
+			t.Fatalf("usage %d is rejected", i)
+		}
+	}
+	if ok, wait := q.Reserve("a"); ok || wait <= 0 || wait > 24*time.Hour {
+		t.Errorf("Reserve() = %v, %v over the quota", ok, wait)
+	}
+
+	q.Refund("a")
+	if ok, _ := q.Reserve("a"); !ok {
+		t.Error("refunded usage is rejected")
+	}
+	if ok, _ := q.Reserve("b"); !ok {
+		t.Error("usage of another key is rejected")
+	}
+}
+
+func TestQuotaReserveConcurrently(t *testing.T) {
+	q := NewQuota(5)
+
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := q.Reserve("a"); ok {
+				lock.Lock()
+				allowed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 {
+		t.Errorf("allowed = %d, want 5", allowed)
+	}
+}
+
+func TestQuotaResetsOnUTCDay(t *testing.T) {
+	// 01:30 of July 2nd in UTC+8 is still July 1st in UTC, the quota is reset by the UTC day only
+	now := time.Date(2019, 7, 2, 1, 30, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	q := NewQuota(1)
+	q.now = func() time.Time { return now }
+
+	if ok, _ := q.Reserve("a"); !ok {
+		t.Fatal("first usage is rejected")
+	}
+	ok, wait := q.Reserve("a")
+	if ok {
+		t.Fatal("usage over the quota is allowed")
+	}
+	if want := 6*time.Hour + 30*time.Minute; wait != want {
+		t.Errorf("wait = %v, want %v", wait, want)
+	}
+
+	now = now.Add(wait)
+	if ok, _ := q.Reserve("a"); !ok {
+		t.Error("usage is rejected after the UTC day changes")
+	}
+
+	// the reservation of the previous day is not given back to the new day
+	q.Refund("a")
+	q.Refund("a")
+	if n := q.counts["a"]; n != 0 {
+		t.Errorf("count = %d, want 0", n)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	requestIDHeader    = "X-Request-ID"
+	requestIDLength    = 20
+	maxRequestIDLength = 128
+)
+
+var (
+	accessLogEnabled = true
+
+	// accessLogger writes JSON lines whatever the format of the server logs is, so the lines can be parsed by log collectors
+	accessLogger = &logrus.Logger{
+		Out:       os.Stdout,
+		Formatter: &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano},
+		Hooks:     make(logrus.LevelHooks),
+		Level:     logrus.InfoLevel,
+	}
+)
+
+// SetAccessLog sets whether each request is logged, the request ID is handled anyway.
+func SetAccessLog(enabled bool) {
+	accessLogEnabled = enabled
+}
+
+// accessLogMiddleware binds the request ID to the request, then logs and observes the request once it is served,
+// the ID of the client is kept if it is sane, otherwise a new one is generated
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !isValidRequestID(id) {
+			id = util.RandStringWithSmall(requestIDLength)
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(backend.WithRequestID(r.Context(), id))
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		latency := time.Since(start)
+		name := ""
+		if route := mux.CurrentRoute(r); route != nil {
+			name = route.GetName()
+		}
+		metric.ObserveRequest(name, r.Method, sw.status, latency)
+		if !accessLogEnabled {
+			return
+		}
+
+		accessLogger.WithFields(logrus.Fields{
+			"request_id": id,
+			"method":     r.Method,
+			"route":      name,
+			"path":       r.URL.Path,
+			"fqdn":       mux.Vars(r)["fqdn"],
+			"status":     sw.status,
+			"latency_ms": float64(latency.Microseconds()) / 1000,
+			"bytes":      sw.size,
+			"client_ip":  clientIP(r),
+		}).Info("access")
+	})
+}
+
+// Used to check the request ID of the client, it is logged so only short IDs of printable characters are kept
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
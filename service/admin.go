@@ -0,0 +1,144 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// adminKey is a static admin credential, only the digest of the key is kept in memory.
+type adminKey struct {
+	name   string
+	digest [sha256.Size]byte
+}
+
+var (
+	adminKeys       = make([]adminKey, 0)
+	adminRouteNames = make(map[string]bool)
+
+	errAdminUnauthorized = errors.New("admin credential is required")
+	errAdminClientCert   = errors.New("admin client certificate is required")
+)
+
+// SetAdminKeys loads the admin keys from the comma separated value and the file (one key per line, # for comments).
+// Each key is "<name>:<key>", the name is used in logs to identify the admin, admin routes are rejected if no key is set.
+func SetAdminKeys(value, file string) error {
+	entries := strings.Split(value, ",")
+	if file != "" {
+		lines, err := readLines(file)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read admin keys file %s", file)
+		}
+		entries = append(entries, lines...)
+	}
+
+	keys := make([]adminKey, 0)
+	names := make(map[string]bool)
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		kv := strings.SplitN(e, ":", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return errors.Errorf("admin key must be <name>:<key>, got an entry of %d characters", len(e))
+		}
+		if names[kv[0]] {
+			return errors.Errorf("admin key %s is duplicated", kv[0])
+		}
+		names[kv[0]] = true
+		keys = append(keys, adminKey{
+			name:   kv[0],
+			digest: sha256.Sum256([]byte(kv[1])),
+		})
+	}
+
+	if len(keys) == 0 {
+		logrus.Warn("no admin key is set, admin routes are disabled")
+	}
+	adminKeys = keys
+
+	return nil
+}
+
+// Used to find the admin key name of the bearer token, all keys are compared in constant time
+func matchAdminKey(token string) (string, bool) {
+	digest := sha256.Sum256([]byte(token))
+
+	name := ""
+	for _, k := range adminKeys {
+		if subtle.ConstantTimeCompare(digest[:], k.digest[:]) == 1 {
+			name = k.name
+		}
+	}
+	return name, name != ""
+}
+
+// Used to check whether the request is routed to an admin route
+func isAdminRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && adminRouteNames[route.GetName()]
+}
+
+func adminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client certificate is verified by the TLS handshake if it is given
+		if requireClientCert() && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			returnHTTPError(w, http.StatusForbidden, errAdminClientCert)
+			return
+		}
+
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", "rdns-admin"))
+			returnHTTPError(w, http.StatusUnauthorized, errAdminUnauthorized)
+			return
+		}
+
+		key := strings.TrimPrefix(authorization, "Bearer ")
+		name, ok := matchAdminKey(key)
+		if !ok {
+			returnHTTPError(w, http.StatusForbidden, errForbidden)
+			return
+		}
+		if !allowToken(w, key) {
+			return
+		}
+
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			name = name + " (" + r.TLS.VerifiedChains[0][0].Subject.CommonName + ")"
+		}
+		setAuditActor(r, name)
+		loadAuditState(r)
+		logrus.Infof("admin %s requested %s %s", name, r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Used to read the non-empty lines of the file, comment lines are skipped
+func readLines(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
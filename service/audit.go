@@ -0,0 +1,177 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/rdns-server/audit"
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	auditRecordA     = "A"
+	auditRecordCNAME = "CNAME"
+	auditRecordTXT   = "TXT"
+	auditRecordAny   = "ANY"
+
+	maxAuditBodySize = 1 << 20
+)
+
+type auditContextKey struct{}
+
+type auditStateKey struct{}
+
+// auditRoutes are the mutating routes recorded by the audit log, the value is the record type read as the states of the domain
+// the states are not recorded if the type is empty, the ANY type reads the A records and then the CNAME record
+var auditRoutes = map[string]string{
+	"createDomain":      auditRecordA,
+	"updateDomain":      auditRecordA,
+	"deleteDomain":      auditRecordA,
+	"renewDomain":       auditRecordA,
+	"createDomainCNAME": auditRecordCNAME,
+	"updateDomainCNAME": auditRecordCNAME,
+	"deleteDomainCNAME": auditRecordCNAME,
+	"createDomainText":  auditRecordTXT,
+	"updateDomainText":  auditRecordTXT,
+	"deleteDomainText":  auditRecordTXT,
+	"rotateToken":       "",
+	"createSubToken":    "",
+	"deleteSubToken":    "",
+	"migrateRecords":    "",
+	"migrateFrozen":     "",
+	"migrateToken":      "",
+	"forceDeleteDomain": auditRecordAny,
+	"forceRenewDomain":  auditRecordAny,
+	"revokeToken":       "",
+}
+
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if !audit.Enabled() || route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		recordType, ok := auditRoutes[route.GetName()]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		e := &model.AuditEvent{
+			Time:     time.Now().UTC(),
+			Action:   route.GetName(),
+			Fqdn:     mux.Vars(r)["fqdn"],
+			ClientIP: clientIP(r),
+		}
+		if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != "" {
+			e.TokenFingerprint = util.TokenFingerprint(token)
+		}
+		// the fqdn of new domains is responded, only migrations carry the fqdn in the body
+		if e.Fqdn == "" && recordType == "" {
+			e.Fqdn = requestFqdn(r)
+		}
+		// the state is read once the request is authorized, so forged requests cost no backend call
+		ctx := context.WithValue(r.Context(), auditContextKey{}, e)
+		ctx = context.WithValue(ctx, auditStateKey{}, func() {
+			e.Before = getAuditState(r.Context(), e.Fqdn, recordType)
+		})
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK, body: &bytes.Buffer{}}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		e.Status = sw.status
+		e.Result = model.AuditSuccess
+		res := &model.Response{}
+		json.Unmarshal(sw.body.Bytes(), res)
+		if sw.status >= http.StatusBadRequest {
+			e.Result = model.AuditFailure
+			e.Error = res.Message
+		} else if recordType != "" && res.Data.Fqdn != "" {
+			// creating domains responds the new fqdn, others respond the domain after the call
+			e.Fqdn = res.Data.Fqdn
+			e.After = &res.Data
+		}
+
+		audit.Record(e)
+	})
+}
+
+// Used to set the actor of the audit event, admin routes are done by the admin key instead of a token
+func setAuditActor(r *http.Request, actor string) {
+	if e, ok := r.Context().Value(auditContextKey{}).(*model.AuditEvent); ok {
+		e.Actor = actor
+		e.TokenFingerprint = ""
+	}
+}
+
+// Used to read the state of the audit event before the request is handled, it is called once the request is authorized
+func loadAuditState(r *http.Request) {
+	if load, ok := r.Context().Value(auditStateKey{}).(func()); ok {
+		load()
+	}
+}
+
+// Used to read the domain as the state of the audit event, nil is returned if it is not found
+func getAuditState(ctx context.Context, fqdn, recordType string) *model.Domain {
+	if fqdn == "" || recordType == "" {
+		return nil
+	}
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		return nil
+	}
+	b := z.Backend.WithContext(ctx)
+
+	opts := &model.DomainOptions{Fqdn: fqdn}
+	var d model.Domain
+	switch recordType {
+	case auditRecordA:
+		d, err = b.Get(opts)
+	case auditRecordCNAME:
+		d, err = b.GetCNAME(opts)
+	case auditRecordTXT:
+		d, err = b.GetText(opts)
+	case auditRecordAny:
+		d, err = b.Get(opts)
+		if err != nil || d.Fqdn == "" {
+			d, err = b.GetCNAME(opts)
+		}
+	}
+	if (err != nil && backend.KindOf(err) != backend.KindExpired) || d.Fqdn == "" {
+		return nil
+	}
+	return &d
+}
+
+// Used to get the fqdn of the request body, the body is kept for the handler
+func requestFqdn(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+	// the rest of a large body is left unread for the handler
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAuditBodySize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	v := struct {
+		Fqdn string `json:"fqdn"`
+	}{}
+	json.Unmarshal(b, &v)
+	return v.Fqdn
+}
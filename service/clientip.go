@@ -0,0 +1,89 @@
+package service
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	clientIPHeader string
+	trustedProxies []*net.IPNet
+)
+
+// SetClientIP sets the header of the client IP and the proxies trusted to set it.
+// The proxies are comma separated IPs or CIDRs, they are required by the header since clients can forge it.
+func SetClientIP(header, proxies string) error {
+	nets := make([]*net.IPNet, 0)
+	for _, p := range strings.Split(proxies, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse trusted proxy %s", p)
+		}
+		nets = append(nets, n)
+	}
+
+	if header != "" && len(nets) == 0 {
+		return errors.Errorf("trusted proxies must be set to read the client IP from %s", header)
+	}
+
+	clientIPHeader, trustedProxies = header, nets
+	return nil
+}
+
+// Used to get the IP of the client, the header is only used if the peer is a trusted proxy
+// the addresses of the header are walked from the nearest one and the first untrusted address is the client
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if clientIPHeader == "" || !isTrustedProxy(host) {
+		return host
+	}
+
+	v := r.Header.Get(clientIPHeader)
+	if v == "" {
+		return host
+	}
+	ips := strings.Split(v, ",")
+	for i := len(ips) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(ips[i])
+		// the address is forged or broken, the nearest trusted hop is the client then
+		if net.ParseIP(ip) == nil {
+			return host
+		}
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+		host = ip
+	}
+	return host
+}
+
+// Used to check whether the address is one of the trusted proxies
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetClientIP(t *testing.T) {
+	defer SetClientIP("", "")
+
+	if err := SetClientIP("X-Forwarded-For", ""); err == nil {
+		t.Error("header without trusted proxies is accepted")
+	}
+	if err := SetClientIP("X-Forwarded-For", "10.0.0.0/8,x"); err == nil {
+		t.Error("invalid trusted proxy is accepted")
+	}
+	if err := SetClientIP("", ""); err != nil {
+		t.Errorf("remote address is rejected: %v", err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	if err := SetClientIP("X-Forwarded-For", "10.0.0.0/8, 192.168.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	defer SetClientIP("", "")
+
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		want   string
+	}{
+		{name: "no header", remote: "10.0.0.1:1234", want: "10.0.0.1"},
+		{name: "untrusted peer", remote: "1.1.1.1:1234", header: "2.2.2.2", want: "1.1.1.1"},
+		{name: "trusted peer", remote: "10.0.0.1:1234", header: "2.2.2.2", want: "2.2.2.2"},
+		{name: "forged addresses are skipped", remote: "10.0.0.1:1234", header: "6.6.6.6, 2.2.2.2, 192.168.0.1", want: "2.2.2.2"},
+		{name: "all hops are trusted", remote: "10.0.0.1:1234", header: "10.0.0.3, 10.0.0.2", want: "10.0.0.3"},
+		{name: "broken address", remote: "10.0.0.1:1234", header: "2.2.2.2, garbage, 10.0.0.2", want: "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ping", nil)
+			r.RemoteAddr = tt.remote
+			if tt.header != "" {
+				r.Header.Set("X-Forwarded-For", tt.header)
+			}
+			if ip := clientIP(r); ip != tt.want {
+				t.Errorf("clientIP() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
@@ -1,23 +1,44 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
 	"net/http"
 
+	"github.com/rancher/rdns-server/audit"
 	"github.com/rancher/rdns-server/backend"
 	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/slug"
+	"github.com/rancher/rdns-server/util"
+	"github.com/rancher/rdns-server/webhook"
 
 	"github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
+// statusCodes maps the kind of backend errors to HTTP status codes, others keep the status code given by handler
+var statusCodes = map[backend.ErrorKind]int{
+	backend.KindNotFound:    http.StatusNotFound,
+	backend.KindConflict:    http.StatusConflict,
+	backend.KindInvalid:     http.StatusBadRequest,
+	backend.KindExpired:     http.StatusGone,
+	backend.KindUnavailable: http.StatusServiceUnavailable,
+}
+
 func returnHTTPError(w http.ResponseWriter, httpStatus int, err error) {
+	if code, ok := statusCodes[backend.KindOf(err)]; ok {
+		httpStatus = code
+	}
+
 	logrus.Errorf("got a response error: %v", err)
 	o := model.Response{
 		Status:  httpStatus,
 		Message: err.Error(),
 	}
+	if v, ok := err.(model.ValidationErrors); ok {
+		o.Errors = v
+	}
 	res, _ := json.Marshal(o)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -41,8 +62,8 @@ func returnSuccess(w http.ResponseWriter, d model.Domain, msg string) {
 	w.Write(res)
 }
 
-func returnSuccessWithToken(w http.ResponseWriter, d model.Domain, msg string) {
-	token, err := generateToken(d.Fqdn)
+func returnSuccessWithToken(w http.ResponseWriter, r *http.Request, d model.Domain, msg string) {
+	token, err := generateToken(r.Context(), d.Fqdn)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -63,6 +84,21 @@ func returnSuccessWithToken(w http.ResponseWriter, d model.Domain, msg string) {
 	w.Write(res)
 }
 
+func returnSuccessWithData(w http.ResponseWriter, data interface{}) {
+	o := model.DataResponse{
+		Status: http.StatusOK,
+		Data:   data,
+	}
+	res, err := json.Marshal(o)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
 func returnSuccessNoData(w http.ResponseWriter) {
 	o := model.Response{
 		Status: http.StatusOK,
@@ -77,12 +113,60 @@ func apiHandler(f http.Handler) http.Handler {
 	return context.ClearHandler(f)
 }
 
+// statusWriter records the status code and the size of the body written by handlers, the body is copied if the buffer is set
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	body   *bytes.Buffer
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.body != nil {
+		w.body.Write(b)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush sends the buffered body to the client, the watch streams require it
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Used to validate the requested name of a new domain, the name must not contain the denied words of slug policy
+func validateName(opts *model.DomainOptions) error {
+	if err := opts.ValidateName(); err != nil {
+		return err
+	}
+	if slug.GetPolicy().IsDenied(opts.Name) {
+		return model.ValidationErrors{{Field: "name", Reason: "must not contain denied words"}}
+	}
+	return nil
+}
+
 func createDomain(w http.ResponseWriter, r *http.Request) {
 	vals := r.URL.Query()
 
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateHosts(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateName(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -90,32 +174,43 @@ func createDomain(w http.ResponseWriter, r *http.Request) {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	d, err := b.Set(opts)
+	z, err := backend.GetZone(opts.Zone)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).Set(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
-	returnSuccessWithToken(w, d, "")
+	webhook.Notify(r.Context(), model.WebhookDomainCreated, &d)
+	returnSuccessWithToken(w, r, d, "")
 }
 
 func getDomain(w http.ResponseWriter, r *http.Request) {
 	vals := r.URL.Query()
 	vars := mux.Vars(r)
 	fqdn := vars["fqdn"]
-	msg := ""
 
 	opts := &model.DomainOptions{Fqdn: fqdn}
 	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	d, err := b.Get(opts)
+	z, err := backend.MatchZone(fqdn)
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
 	}
-	returnSuccess(w, d, msg)
+
+	d, err := z.Backend.WithContext(r.Context()).Get(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccess(w, d, "")
 }
 
 func renewDomain(w http.ResponseWriter, r *http.Request) {
@@ -124,12 +219,18 @@ func renewDomain(w http.ResponseWriter, r *http.Request) {
 
 	opts := &model.DomainOptions{Fqdn: fqdn}
 
-	b := backend.GetBackend()
-	d, err := b.Renew(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).Renew(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
+	webhook.Notify(r.Context(), model.WebhookDomainRenewed, &d)
 
 	returnSuccess(w, d, "")
 }
@@ -141,7 +242,11 @@ func updateDomain(w http.ResponseWriter, r *http.Request) {
 
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateHosts(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
@@ -149,12 +254,18 @@ func updateDomain(w http.ResponseWriter, r *http.Request) {
 	}
 	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.Update(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).Update(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
+	webhook.Notify(r.Context(), model.WebhookDomainUpdated, &d)
 
 	returnSuccess(w, d, "")
 }
@@ -169,12 +280,18 @@ func deleteDomain(w http.ResponseWriter, r *http.Request) {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	err := b.Delete(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).Delete(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
+	webhook.Notify(r.Context(), model.WebhookDomainDeleted, &model.Domain{Fqdn: fqdn})
 
 	returnSuccessNoData(w)
 }
@@ -184,7 +301,15 @@ func createDomainCNAME(w http.ResponseWriter, r *http.Request) {
 
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateCNAME(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := validateName(opts); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 
@@ -192,32 +317,43 @@ func createDomainCNAME(w http.ResponseWriter, r *http.Request) {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	d, err := b.SetCNAME(opts)
+	z, err := backend.GetZone(opts.Zone)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).SetCNAME(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
-	returnSuccessWithToken(w, d, "")
+	webhook.Notify(r.Context(), model.WebhookDomainCreated, &d)
+	returnSuccessWithToken(w, r, d, "")
 }
 
 func getDomainCNAME(w http.ResponseWriter, r *http.Request) {
 	vals := r.URL.Query()
 	vars := mux.Vars(r)
 	fqdn := vars["fqdn"]
-	msg := ""
 
 	opts := &model.DomainOptions{Fqdn: fqdn}
 	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	d, err := b.GetCNAME(opts)
+	z, err := backend.MatchZone(fqdn)
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
 	}
-	returnSuccess(w, d, msg)
+
+	d, err := z.Backend.WithContext(r.Context()).GetCNAME(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccess(w, d, "")
 }
 
 func updateDomainCNAME(w http.ResponseWriter, r *http.Request) {
@@ -227,7 +363,11 @@ func updateDomainCNAME(w http.ResponseWriter, r *http.Request) {
 
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateCNAME(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 	if len(vals["normal"]) > 0 && vals["normal"][0] == "true" {
@@ -235,12 +375,18 @@ func updateDomainCNAME(w http.ResponseWriter, r *http.Request) {
 	}
 	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.UpdateCNAME(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).UpdateCNAME(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
+	webhook.Notify(r.Context(), model.WebhookDomainUpdated, &d)
 
 	returnSuccess(w, d, "")
 }
@@ -255,12 +401,18 @@ func deleteDomainCNAME(w http.ResponseWriter, r *http.Request) {
 		opts.Normal = true
 	}
 
-	b := backend.GetBackend()
-	err := b.DeleteCNAME(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).DeleteCNAME(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
+	webhook.Notify(r.Context(), model.WebhookDomainDeleted, &model.Domain{Fqdn: fqdn})
 
 	returnSuccessNoData(w)
 }
@@ -270,13 +422,22 @@ func createDomainText(w http.ResponseWriter, r *http.Request) {
 	fqdn := vars["fqdn"]
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateText(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 	opts.Fqdn = fqdn
 
-	b := backend.GetBackend()
-	d, err := b.SetText(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).SetText(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -288,15 +449,20 @@ func createDomainText(w http.ResponseWriter, r *http.Request) {
 func getDomainText(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	fqdn := vars["fqdn"]
-	msg := ""
 
 	opts := &model.DomainOptions{Fqdn: fqdn}
-	b := backend.GetBackend()
-	d, err := b.GetText(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).GetText(opts)
 	if err != nil {
-		msg = err.Error()
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
 	}
-	returnSuccess(w, d, msg)
+	returnSuccess(w, d, "")
 }
 
 func updateDomainText(w http.ResponseWriter, r *http.Request) {
@@ -305,12 +471,21 @@ func updateDomainText(w http.ResponseWriter, r *http.Request) {
 
 	opts, err := model.ParseDomainOptions(r)
 	if err != nil {
-		returnHTTPError(w, http.StatusInternalServerError, err)
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := opts.ValidateText(); err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
 		return
 	}
 	opts.Fqdn = fqdn
-	b := backend.GetBackend()
-	d, err := b.UpdateText(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).UpdateText(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -324,8 +499,13 @@ func deleteDomainText(w http.ResponseWriter, r *http.Request) {
 	fqdn := vars["fqdn"]
 
 	opts := &model.DomainOptions{Fqdn: fqdn}
-	b := backend.GetBackend()
-	err := b.DeleteText(opts)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).DeleteText(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -334,6 +514,86 @@ func deleteDomainText(w http.ResponseWriter, r *http.Request) {
 	returnSuccessNoData(w)
 }
 
+// rotateToken replaces the token of the domain, the old token is rejected as soon as the new one is issued
+// rotateToken issues a new token of the domain, the token of a sub-name is held by its domain
+func rotateToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	_, fqdn, err := tokenHolder(vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	returnSuccessWithToken(w, r, model.Domain{Fqdn: fqdn}, "")
+}
+
+// createSubToken mints a sub token of the domain, the sub token is only responded once
+func createSubToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	opts, err := model.ParseSubTokenOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// sub tokens belong to the domain token, which a sub-name shares with its domain
+	z, fqdn, err := tokenHolder(vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	t, secret := generateSubToken(opts)
+	if err := z.Backend.WithContext(r.Context()).SetSubToken(fqdn, t); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	info := model.NewSubTokenInfo(t)
+	info.Token = model.SubTokenPrefix + t.SID + "." + secret
+	returnSuccessWithData(w, info)
+}
+
+func listSubTokens(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	z, fqdn, err := tokenHolder(vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	ts, err := z.Backend.WithContext(r.Context()).GetSubTokens(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	infos := make([]model.SubTokenInfo, 0)
+	for _, t := range ts {
+		infos = append(infos, model.NewSubTokenInfo(t))
+	}
+	returnSuccessWithData(w, infos)
+}
+
+func deleteSubToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	z, fqdn, err := tokenHolder(vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if err := z.Backend.WithContext(r.Context()).DeleteSubToken(fqdn, vars["id"]); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccessNoData(w)
+}
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	returnSuccessNoData(w)
 }
@@ -345,8 +605,14 @@ func migrateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := backend.GetBackend()
-	err = b.MigrateRecord(opts)
+	// the zone of a migration is chosen by the client, so a zone not served is an invalid request
+	z, err := backend.MatchZone(opts.Fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, backend.Invalid(err))
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).MigrateRecord(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -362,8 +628,13 @@ func migrateFrozen(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := backend.GetBackend()
-	err = b.MigrateFrozen(opts)
+	z, err := backend.GetZone(opts.Zone)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).MigrateFrozen(opts)
 	if err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
@@ -379,12 +650,159 @@ func migrateToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := backend.GetBackend()
-	err = b.MigrateToken(opts)
+	z, err := backend.MatchZone(opts.Fqdn())
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, backend.Invalid(err))
+		return
+	}
+
+	err = z.Backend.WithContext(r.Context()).MigrateToken(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	returnSuccessNoData(w)
+}
+
+func listDomains(w http.ResponseWriter, r *http.Request) {
+	opts, err := model.ParseListOptions(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	z, err := backend.GetZone(opts.Zone)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	l, err := z.Backend.WithContext(r.Context()).List(opts)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccessWithData(w, l)
+}
+
+func inspectDomain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).Inspect(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccessWithData(w, d)
+}
+
+func forceDeleteDomain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	b := z.Backend.WithContext(r.Context())
+
+	// the records are gone once deleted, so the domain of the event is inspected before
+	d := &model.Domain{Fqdn: fqdn}
+	if webhook.Enabled() {
+		if info, err := b.Inspect(fqdn); err == nil {
+			d = info.Domain()
+		}
+	}
+
+	if err := b.ForceDelete(fqdn); err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Notify(r.Context(), model.WebhookDomainDeleted, d)
+	returnSuccessNoData(w)
+}
+
+func forceRenewDomain(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// a sub-name is renewed with the domain holding its token
+	z, fqdn, err := tokenHolder(vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := z.Backend.WithContext(r.Context()).ForceRenew(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	webhook.Notify(r.Context(), model.WebhookDomainRenewed, d.Domain())
+	returnSuccessWithData(w, d)
+}
+
+// revokeToken replaces the token of the domain without responding the new one and deletes its sub tokens,
+// so nobody can manage the domain anymore
+func revokeToken(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	fqdn := vars["fqdn"]
+
+	z, err := backend.MatchZone(fqdn)
 	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+
+	b := z.Backend.WithContext(r.Context())
+
+	// nobody knows the token of the hash
+	if err := b.UpdateToken(fqdn, util.HashToken(util.RandStringWithAll(tokenLength))); err != nil {
 		returnHTTPError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	ts, err := b.GetSubTokens(fqdn)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, t := range ts {
+		if err := b.DeleteSubToken(fqdn, t.SID); err != nil && backend.KindOf(err) != backend.KindNotFound {
+			returnHTTPError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	logrus.Infof("token of %s is revoked", fqdn)
 	returnSuccessNoData(w)
 }
+
+func getAuditEvents(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	q, err := model.ParseAuditQuery(r, vars["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := audit.Query(q)
+	if err == audit.ErrNotQueryable {
+		returnHTTPError(w, http.StatusNotImplemented, err)
+		return
+	}
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+	returnSuccessWithData(w, events)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/webhook"
+)
+
+func TestDomainLifecycle(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4", "2001:db8::2")
+	path := "/v1/domain/" + fqdn
+
+	res := &model.Response{}
+	if code := doRequest(t, http.MethodGet, path, token, nil, res); code != http.StatusOK {
+		t.Fatalf("get domain = %d, %s", code, res.Message)
+	}
+	if want := []string{"4.4.4.4", "2001:db8::2"}; !reflect.DeepEqual(res.Data.Hosts, want) {
+		t.Errorf("hosts = %v, want %v", res.Data.Hosts, want)
+	}
+
+	update := map[string]interface{}{
+		"hosts":     []string{"3.3.3.3"},
+		"subdomain": map[string][]string{"sub1": {"9.9.9.9"}},
+	}
+	res = &model.Response{}
+	if code := doRequest(t, http.MethodPut, path, token, update, res); code != http.StatusOK {
+		t.Fatalf("update domain = %d, %s", code, res.Message)
+	}
+	res = &model.Response{}
+	doRequest(t, http.MethodGet, path, token, nil, res)
+	if !reflect.DeepEqual(res.Data.Hosts, []string{"3.3.3.3"}) || !reflect.DeepEqual(res.Data.SubDomain["sub1"], []string{"9.9.9.9"}) {
+		t.Errorf("domain after update = %+v", res.Data)
+	}
+
+	before := time.Now()
+	res = &model.Response{}
+	if code := doRequest(t, http.MethodPut, path+"/renew", token, nil, res); code != http.StatusOK {
+		t.Fatalf("renew domain = %d, %s", code, res.Message)
+	}
+	if res.Data.Expiration == nil || res.Data.Expiration.Before(before.Add(239*time.Hour)) {
+		t.Errorf("expiration after renew = %v, want a new lease", res.Data.Expiration)
+	}
+
+	if code := doRequest(t, http.MethodDelete, path, token, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete domain = %d", code)
+	}
+	res = &model.Response{}
+	if code := doRequest(t, http.MethodGet, path, token, nil, res); code != http.StatusNotFound {
+		t.Errorf("get deleted domain = %d, %+v, want 404", code, res)
+	}
+}
+
+func TestTokenAuthorization(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	other, _ := createTestDomain(t, "5.5.5.5")
+	path := "/v1/domain/" + fqdn
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		code  int
+	}{
+		{name: "no token", path: path, code: http.StatusForbidden},
+		{name: "wrong token", path: path, token: "xxxxxx", code: http.StatusForbidden},
+		{name: "token of another domain", path: "/v1/domain/" + other, token: token, code: http.StatusForbidden},
+		{name: "token of the domain", path: path, token: token, code: http.StatusOK},
+		{name: "admin key", path: path, token: testAdminKey, code: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := doRequest(t, http.MethodGet, tt.path, tt.token, nil, nil); code != tt.code {
+				t.Errorf("get domain = %d, want %d", code, tt.code)
+			}
+		})
+	}
+}
+
+func TestMissingRecords(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn
+
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{name: "A records", path: path, code: http.StatusOK},
+		{name: "CNAME record", path: path + "/cname", code: http.StatusNotFound},
+		{name: "TXT record", path: path + "/txt", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &model.Response{}
+			if code := doRequest(t, http.MethodGet, tt.path, token, nil, res); code != tt.code || res.Status != tt.code {
+				t.Errorf("get = %d (status %d), want %d", code, res.Status, tt.code)
+			}
+		})
+	}
+}
+
+func TestCreateDomainValidation(t *testing.T) {
+	res := &model.Response{}
+	code := doRequest(t, http.MethodPost, "/v1/domain", "", map[string]interface{}{"hosts": []string{"4.4.4.4", "x"}}, res)
+	if code != http.StatusBadRequest {
+		t.Fatalf("create domain = %d, want 400", code)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Field != "hosts[1]" {
+		t.Errorf("errors = %v, want hosts[1]", res.Errors)
+	}
+}
+
+func TestDeletedEvents(t *testing.T) {
+	if err := webhook.SetOptions(&webhook.Options{
+		Endpoints:   []webhook.Endpoint{{URL: "http://127.0.0.1/hook", Events: []string{model.WebhookDomainDeleted}}},
+		Secret:      "secret",
+		MaxAttempts: 1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer webhook.SetOptions(&webhook.Options{})
+
+	deleted, token := createTestDomain(t, "4.4.4.4")
+	if code := doRequest(t, http.MethodDelete, "/v1/domain/"+deleted, token, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete domain = %d", code)
+	}
+
+	res := &model.Response{}
+	if code := doRequest(t, http.MethodPost, "/v1/domain/cname", "", map[string]interface{}{"cname": "www.example.com"}, res); code != http.StatusOK {
+		t.Fatalf("create cname domain = %d, %s", code, res.Message)
+	}
+	cname := res.Data.Fqdn
+	if code := doRequest(t, http.MethodDelete, "/v1/domain/"+cname+"/cname", res.Token, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete cname domain = %d", code)
+	}
+
+	forced, _ := createTestDomain(t, "5.5.5.5")
+	if code := doRequest(t, http.MethodDelete, "/v1/admin/domains/"+forced, testAdminKey, nil, nil); code != http.StatusOK {
+		t.Fatalf("force delete domain = %d", code)
+	}
+
+	now := time.Now()
+	ws, err := database.GetDatabase().ListDueWebhookDeliveries(&now, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(map[string]*model.Domain)
+	for _, w := range ws {
+		defer database.GetDatabase().DeleteWebhookDelivery(w.ID)
+
+		e := &model.WebhookEvent{}
+		if err := json.Unmarshal([]byte(w.Content), e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != model.WebhookDomainDeleted {
+			t.Errorf("event of %s = %s, want %s", e.Domain.Fqdn, e.Type, model.WebhookDomainDeleted)
+		}
+		events[e.Domain.Fqdn] = e.Domain
+	}
+
+	for _, fqdn := range []string{deleted, cname, forced} {
+		if events[fqdn] == nil {
+			t.Errorf("no deleted event of %s", fqdn)
+		}
+	}
+	// the force deleted domain is inspected before its records are gone
+	if d := events[forced]; d != nil && !reflect.DeepEqual(d.Hosts, []string{"5.5.5.5"}) {
+		t.Errorf("hosts of the force deleted domain = %v, want [5.5.5.5]", d.Hosts)
+	}
+}
+
+func TestForceRenewBySubName(t *testing.T) {
+	fqdn, _ := createTestDomain(t, "4.4.4.4")
+
+	res := struct {
+		Data model.DomainInfo `json:"data"`
+	}{}
+	if code := doRequest(t, http.MethodPut, "/v1/admin/domains/_acme-challenge."+fqdn+"/renew", testAdminKey, nil, &res); code != http.StatusOK {
+		t.Fatalf("force renew by sub-name = %d", code)
+	}
+	if res.Data.Fqdn != fqdn {
+		t.Errorf("fqdn of renewed domain = %s, want %s", res.Data.Fqdn, fqdn)
+	}
+}
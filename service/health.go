@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// readiness is probed by every replica of the load balancer and kubernetes, so the results are kept for a while
+	readinessCacheTTL = 5 * time.Second
+	readinessTimeout  = 3 * time.Second
+)
+
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+var (
+	readinessLock   sync.Mutex
+	readinessChecks []readinessCheck
+	readinessCache  *model.Readiness
+)
+
+// AddReadinessCheck registers the check of a dependency, the backends of the zones are always checked.
+func AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	readinessLock.Lock()
+	defer readinessLock.Unlock()
+
+	readinessChecks = append(readinessChecks, readinessCheck{name: name, check: check})
+	readinessCache = nil
+}
+
+// healthz reports the process is alive, the dependencies are checked by readyz
+func healthz(w http.ResponseWriter, r *http.Request) {
+	returnSuccessNoData(w)
+}
+
+// readyz responds 503 if any dependency is not ready, the result of each check is responded
+func readyz(w http.ResponseWriter, r *http.Request) {
+	rd := checkReadiness()
+
+	status := http.StatusOK
+	if !rd.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	res, _ := json.Marshal(model.DataResponse{
+		Status: status,
+		Data:   rd,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(res)
+}
+
+// Used to run the checks at once, the cached result is returned if it is fresh
+// the lock is held while checking, so concurrent probes wait for the same result instead of checking again
+func checkReadiness() *model.Readiness {
+	readinessLock.Lock()
+	defer readinessLock.Unlock()
+
+	if readinessCache != nil && time.Since(readinessCache.CheckedAt) < readinessCacheTTL {
+		return readinessCache
+	}
+
+	checks := make([]readinessCheck, 0)
+	for _, z := range backend.GetZones() {
+		checks = append(checks, readinessCheck{name: "zone " + z.Name, check: z.Backend.Ping})
+	}
+	checks = append(checks, readinessChecks...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), readinessTimeout)
+	defer cancel()
+
+	rd := &model.Readiness{
+		Ready:  true,
+		Checks: make([]model.CheckResult, len(checks)),
+	}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c readinessCheck) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.check(ctx)
+			rd.Checks[i] = model.CheckResult{
+				Name:      c.name,
+				Ready:     err == nil,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				rd.Checks[i].Error = err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	rd.CheckedAt = time.Now().UTC()
+
+	for _, c := range rd.Checks {
+		if !c.Ready {
+			rd.Ready = false
+			logrus.Warnf("%s is not ready: %s", c.Name, c.Error)
+		}
+	}
+	readinessCache = rd
+	return rd
+}
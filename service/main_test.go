@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/backend/memory"
+	"github.com/rancher/rdns-server/database"
+	memorydb "github.com/rancher/rdns-server/database/memory"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testZone     = "lb.rancher.cloud"
+	testAdminKey = "secret"
+)
+
+var testServer *httptest.Server
+
+// TestMain serves the router by the memory backend and database, the zone is shared by the tests
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	SetAccessLog(false)
+
+	database.SetDatabase(memorydb.NewDatabase())
+	b, err := memory.NewBackend(&backend.ZoneOptions{Name: testZone, LeaseTime: 240 * time.Hour})
+	if err != nil {
+		panic(err)
+	}
+	if err := backend.AddZone(&backend.Zone{Name: b.Zone, LeaseTime: b.LeaseTime, Backend: backend.WithChanges(b)}); err != nil {
+		panic(err)
+	}
+	if err := SetAdminKeys("ops:"+testAdminKey, ""); err != nil {
+		panic(err)
+	}
+
+	testServer = httptest.NewServer(NewRouter())
+	code := m.Run()
+	testServer.Close()
+	os.Exit(code)
+}
+
+// Used to send the request to the test server, the response body is decoded into out if it is set
+func doRequest(t *testing.T, method, path, token string, body interface{}, out interface{}) int {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, testServer.URL+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: failed to decode response, err: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// Used to create a domain of the hosts, its fqdn and token are returned
+func createTestDomain(t *testing.T, hosts ...string) (string, string) {
+	t.Helper()
+
+	res := &model.Response{}
+	if code := doRequest(t, http.MethodPost, "/v1/domain", "", map[string]interface{}{"hosts": hosts}, res); code != http.StatusOK {
+		t.Fatalf("create domain = %d, %s", code, res.Message)
+	}
+	if res.Data.Fqdn == "" || res.Token == "" {
+		t.Fatalf("create domain responded no fqdn or token: %+v", res)
+	}
+	return res.Data.Fqdn, res.Token
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/ratelimit"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimitOptions are the limits of each client, a zero rate or quota disables the limit.
+// The limits are kept in memory, so each server instance limits the requests it serves.
+type RateLimitOptions struct {
+	IPRate      float64
+	IPBurst     int
+	TokenRate   float64
+	TokenBurst  int
+	CreateQuota int
+}
+
+var (
+	ipLimiter    *ratelimit.Limiter
+	tokenLimiter *ratelimit.Limiter
+	createQuota  *ratelimit.Quota
+
+	// createRoutes allocate new domains, they are counted by the create quota
+	createRoutes = map[string]bool{
+		"createDomain":      true,
+		"createDomainCNAME": true,
+	}
+
+	// unlimitedPaths are probed by health checks and monitoring
+	unlimitedPaths = map[string]bool{
+		"/ping":    true,
+		"/healthz": true,
+		"/readyz":  true,
+		"/metrics": true,
+	}
+
+	errRateLimited   = errors.New("too many requests")
+	errQuotaExceeded = errors.New("too many domains are created today")
+)
+
+// SetRateLimits validates and applies the limits.
+func SetRateLimits(opts *RateLimitOptions) error {
+	if opts.IPRate < 0 || opts.TokenRate < 0 || opts.CreateQuota < 0 {
+		return errors.New("rate limits and create quota must not be negative")
+	}
+	if (opts.IPRate > 0 && opts.IPBurst < 1) || (opts.TokenRate > 0 && opts.TokenBurst < 1) {
+		return errors.New("rate limit burst must be at least 1")
+	}
+
+	ipLimiter, tokenLimiter, createQuota = nil, nil, nil
+	if opts.IPRate > 0 {
+		ipLimiter = ratelimit.NewLimiter(opts.IPRate, opts.IPBurst)
+	}
+	if opts.TokenRate > 0 {
+		tokenLimiter = ratelimit.NewLimiter(opts.TokenRate, opts.TokenBurst)
+	}
+	if opts.CreateQuota > 0 {
+		createQuota = ratelimit.NewQuota(opts.CreateQuota)
+	}
+	return nil
+}
+
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unlimitedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ip := clientIP(r)
+		if ipLimiter != nil {
+			if ok, d := ipLimiter.Allow(ip); !ok {
+				returnTooManyRequests(w, "ip", d, errRateLimited)
+				return
+			}
+		}
+
+		route := mux.CurrentRoute(r)
+		if createQuota == nil || route == nil || !createRoutes[route.GetName()] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if ok, d := createQuota.Reserve(ip); !ok {
+			logrus.Warnf("create quota of %s is exceeded", ip)
+			returnTooManyRequests(w, "create_quota", d, errQuotaExceeded)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status != http.StatusOK {
+			createQuota.Refund(ip)
+		}
+	})
+}
+
+// Used to limit the requests of the bearer token, it is called once the token is checked
+// so random tokens neither escape the limit nor grow the buckets of the limiter
+func allowToken(w http.ResponseWriter, token string) bool {
+	if tokenLimiter == nil {
+		return true
+	}
+	if ok, d := tokenLimiter.Allow(util.HashToken(token)); !ok {
+		returnTooManyRequests(w, "token", d, errRateLimited)
+		return false
+	}
+	return true
+}
+
+// Used to respond 429 with the seconds to wait
+func returnTooManyRequests(w http.ResponseWriter, limit string, wait time.Duration, err error) {
+	metric.ObserveRateLimited(limit)
+	w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+	returnHTTPError(w, http.StatusTooManyRequests, err)
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestTokenLimitAfterAuthorization(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn
+
+	if err := SetRateLimits(&RateLimitOptions{TokenRate: 0.001, TokenBurst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRateLimits(&RateLimitOptions{})
+
+	// rejected tokens are neither limited nor tracked
+	for i := 0; i < 3; i++ {
+		if code := doRequest(t, http.MethodGet, path, "forged-"+strconv.Itoa(i), nil, nil); code != http.StatusForbidden {
+			t.Errorf("get domain by a forged token = %d, want 403", code)
+		}
+	}
+	if n := tokenLimiter.Len(); n != 0 {
+		t.Errorf("tracked tokens = %d, want 0", n)
+	}
+
+	if code := doRequest(t, http.MethodGet, path, token, nil, nil); code != http.StatusOK {
+		t.Errorf("first request = %d, want 200", code)
+	}
+	if code := doRequest(t, http.MethodGet, path, token, nil, nil); code != http.StatusTooManyRequests {
+		t.Errorf("request over the limit = %d, want 429", code)
+	}
+}
+
+func TestCreateQuotaRefundsFailures(t *testing.T) {
+	if err := SetRateLimits(&RateLimitOptions{CreateQuota: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRateLimits(&RateLimitOptions{})
+
+	invalid := map[string]interface{}{"hosts": []string{"x"}}
+	if code := doRequest(t, http.MethodPost, "/v1/domain", "", invalid, nil); code != http.StatusBadRequest {
+		t.Fatalf("create invalid domain = %d, want 400", code)
+	}
+
+	createTestDomain(t, "4.4.4.4")
+	if code := doRequest(t, http.MethodPost, "/v1/domain", "", map[string]interface{}{"hosts": []string{"4.4.4.4"}}, nil); code != http.StatusTooManyRequests {
+		t.Errorf("create domain over the quota = %d, want 429", code)
+	}
+}
@@ -24,6 +24,18 @@ var routes = Routes{
 		"/ping",
 		ping,
 	},
+	Route{
+		"healthz",
+		"GET",
+		"/healthz",
+		healthz,
+	},
+	Route{
+		"readyz",
+		"GET",
+		"/readyz",
+		readyz,
+	},
 	Route{
 		"getDomain",
 		"GET",
@@ -102,6 +114,40 @@ var routes = Routes{
 		"/v1/domain/{fqdn}/txt",
 		deleteDomainText,
 	},
+	Route{
+		"rotateToken",
+		"POST",
+		"/v1/domain/{fqdn}/token/rotate",
+		rotateToken,
+	},
+	Route{
+		"createSubToken",
+		"POST",
+		"/v1/domain/{fqdn}/tokens",
+		createSubToken,
+	},
+	Route{
+		"listSubTokens",
+		"GET",
+		"/v1/domain/{fqdn}/tokens",
+		listSubTokens,
+	},
+	Route{
+		"deleteSubToken",
+		"DELETE",
+		"/v1/domain/{fqdn}/tokens/{id}",
+		deleteSubToken,
+	},
+	Route{
+		"watchDomain",
+		"GET",
+		"/v1/domain/{fqdn}/watch",
+		watchDomain,
+	},
+}
+
+// adminRoutes require an admin key instead of the domain token
+var adminRoutes = Routes{
 	Route{
 		"migrateRecords",
 		"POST",
@@ -120,6 +166,48 @@ var routes = Routes{
 		"/v1/migrate/token",
 		migrateToken,
 	},
+	Route{
+		"listDomains",
+		"GET",
+		"/v1/admin/domains",
+		listDomains,
+	},
+	Route{
+		"inspectDomain",
+		"GET",
+		"/v1/admin/domains/{fqdn}",
+		inspectDomain,
+	},
+	Route{
+		"forceDeleteDomain",
+		"DELETE",
+		"/v1/admin/domains/{fqdn}",
+		forceDeleteDomain,
+	},
+	Route{
+		"forceRenewDomain",
+		"PUT",
+		"/v1/admin/domains/{fqdn}/renew",
+		forceRenewDomain,
+	},
+	Route{
+		"revokeToken",
+		"DELETE",
+		"/v1/admin/domains/{fqdn}/token",
+		revokeToken,
+	},
+	Route{
+		"getAuditEvents",
+		"GET",
+		"/v1/admin/domains/{fqdn}/audit",
+		getAuditEvents,
+	},
+	Route{
+		"watchDomains",
+		"GET",
+		"/v1/admin/watch",
+		watchDomains,
+	},
 }
 
 func NewRouter() *mux.Router {
@@ -134,8 +222,27 @@ func NewRouter() *mux.Router {
 			Handler(apiHandler(route.HandlerFunc))
 	}
 
+	for _, route := range adminRoutes {
+		adminRouteNames[route.Name] = true
+		router.
+			Methods(route.Method).
+			Path(route.Pattern).
+			Name(route.Name).
+			Handler(apiHandler(adminMiddleware(route.HandlerFunc)))
+	}
+
 	router.Handle("/metrics", promhttp.Handler())
 
+	// the middlewares only wrap the matched routes, unmatched requests are logged by the handlers
+	router.NotFoundHandler = accessLogMiddleware(http.NotFoundHandler())
+	router.MethodNotAllowedHandler = accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	router.Use(accessLogMiddleware)
+	router.Use(tracingMiddleware)
+	router.Use(rateLimitMiddleware)
+	router.Use(auditMiddleware)
 	router.Use(tokenMiddleware)
 
 	return router
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	readHeaderTimeout = 10 * time.Second
+	idleTimeout       = 120 * time.Second
+)
+
+// Server serves the router, the requests in flight are drained by Shutdown.
+type Server struct {
+	s *http.Server
+}
+
+// NewServer returns the server of the address, TLS is used if the options are set.
+func NewServer(addr string) *Server {
+	s := &http.Server{
+		Addr:              addr,
+		Handler:           NewRouter(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	// the watch streams are ended first, otherwise the shutdown waits for them until it times out
+	s.RegisterOnShutdown(stopWatches)
+	return &Server{s: s}
+}
+
+// ListenAndServe blocks until the server fails or is shut down, nil is returned once it is shut down.
+func (s *Server) ListenAndServe() error {
+	var err error
+	if reloader == nil {
+		logrus.Warn("tls cert is not set, tokens are sent in plaintext")
+		err = s.s.ListenAndServe()
+	} else {
+		done := make(chan struct{})
+		defer close(done)
+		go reloader.watch(done)
+
+		s.s.TLSConfig = reloader.config()
+		err = s.s.ListenAndServeTLS("", "")
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown stops accepting requests and waits for the requests in flight until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.s.Shutdown(ctx)
+}
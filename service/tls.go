@@ -0,0 +1,173 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const tlsReloadInterval = 10 * time.Second
+
+// TLSOptions are the files of the server certificate, the client CA is optional and only used to verify admins.
+type TLSOptions struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// tlsReloader keeps the certificate and the client CA loaded from the files, they are reloaded when the files are changed.
+type tlsReloader struct {
+	opts *TLSOptions
+
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+}
+
+var reloader *tlsReloader
+
+// SetTLSOptions loads the certificate and the client CA, the server is served with TLS afterwards.
+// Nothing is changed if the certificate is not set, the key is required with the certificate.
+func SetTLSOptions(opts *TLSOptions) error {
+	if opts.CertFile == "" && opts.KeyFile == "" {
+		if opts.ClientCAFile != "" {
+			return errors.New("tls client ca requires tls cert and tls key")
+		}
+		return nil
+	}
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return errors.New("tls cert and tls key must be set together")
+	}
+
+	r := &tlsReloader{
+		opts:     opts,
+		modTimes: make(map[string]time.Time),
+	}
+	if _, err := r.reload(); err != nil {
+		return err
+	}
+	reloader = r
+
+	return nil
+}
+
+// Used to build the TLS config with sensible defaults, client certificates are verified if given and required by admin routes only
+func (r *tlsReloader) config() *tls.Config {
+	c := &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+
+	c.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		r.lock.RLock()
+		defer r.lock.RUnlock()
+		return r.cert, nil
+	}
+
+	if r.opts.ClientCAFile != "" {
+		// the client CA may be reloaded, so the config is cloned with the current one for each connection
+		c.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.lock.RLock()
+			defer r.lock.RUnlock()
+			cc := c.Clone()
+			cc.GetConfigForClient = nil
+			cc.ClientAuth = tls.VerifyClientCertIfGiven
+			cc.ClientCAs = r.clientCA
+			return cc, nil
+		}
+	}
+
+	return c
+}
+
+// Used to reload the files periodically, a file which fails to reload keeps the last loaded content
+func (r *tlsReloader) watch(done <-chan struct{}) {
+	t := time.NewTicker(tlsReloadInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			reloaded, err := r.reload()
+			if err != nil {
+				logrus.Errorf("failed to reload tls files, keep the last loaded ones, err: %v", err)
+				continue
+			}
+			if reloaded {
+				logrus.Info("tls files are reloaded")
+			}
+		}
+	}
+}
+
+// Used to load the files if any of them is changed since the last load
+func (r *tlsReloader) reload() (bool, error) {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+
+	changed := false
+	modTimes := make(map[string]time.Time)
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to stat tls file %s", f)
+		}
+		modTimes[f] = info.ModTime()
+		if !info.ModTime().Equal(r.modTimes[f]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to load tls cert %s and key %s", r.opts.CertFile, r.opts.KeyFile)
+	}
+
+	var pool *x509.CertPool
+	if r.opts.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read tls client ca %s", r.opts.ClientCAFile)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return false, errors.Errorf("not found any certificate in tls client ca %s", r.opts.ClientCAFile)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cert = &cert
+	r.clientCA = pool
+	r.modTimes = modTimes
+
+	return true, nil
+}
+
+// Used to check whether admins must present a client certificate
+func requireClientCert() bool {
+	return reloader != nil && reloader.opts.ClientCAFile != ""
+}
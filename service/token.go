@@ -1,11 +1,19 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/tracing"
+	"github.com/rancher/rdns-server/util"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
@@ -13,78 +21,219 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func generateToken(fqdn string) (string, error) {
-	b := backend.GetBackend()
-	origin, err := b.GetToken(fqdn)
+const (
+	tokenLength          = 32
+	subTokenIDLength     = 12
+	subTokenSecretLength = 32
+)
+
+// generateToken issues a new token of the domain holding the fqdn's token, the old token is rejected at once
+// only the hash of the token is kept by the backend, so the token can not be responded again
+func generateToken(ctx context.Context, fqdn string) (string, error) {
+	z, fqdn, err := tokenHolder(fqdn)
 	if err != nil {
-		logrus.Errorf("failed to get token origin %s, err: %v", fqdn, err)
 		return "", err
 	}
-	hash, err := bcrypt.GenerateFromPassword([]byte(origin), bcrypt.MinCost)
-	if err != nil {
-		logrus.Errorf("failed to generate token with %s, err: %v", fqdn, err)
+
+	token := util.RandStringWithAll(tokenLength)
+	if err := z.Backend.WithContext(ctx).UpdateToken(fqdn, util.HashToken(token)); err != nil {
+		backend.Logger(ctx).Errorf("failed to update token of %s, err: %v", fqdn, err)
 		return "", err
 	}
-
-	token := base64.StdEncoding.EncodeToString(hash)
 	return token, nil
 }
 
-func compareToken(fqdn, token string) bool {
-	// normal text record & acme text record need special treatment
-	fqdnLen := len(strings.Split(fqdn, "."))
-	rootDomainLen := len(strings.Split(backend.GetBackend().GetZone(), "."))
-	diffLen := fqdnLen - rootDomainLen
-	if diffLen > 1 {
-		sp := strings.SplitAfterN(fqdn, ".", diffLen)
-		fqdn = sp[len(sp)-1]
+var errForbidden = errors.New("forbidden to use")
+
+// routeScopes are the sub token scopes allowed by the domain routes, the routes not listed require the domain token
+var routeScopes = map[string][]string{
+	"getDomain":         {model.ScopeRead, model.ScopeFull},
+	"updateDomain":      {model.ScopeFull},
+	"deleteDomain":      {model.ScopeFull},
+	"renewDomain":       {model.ScopeFull},
+	"getDomainCNAME":    {model.ScopeRead, model.ScopeFull},
+	"updateDomainCNAME": {model.ScopeFull},
+	"deleteDomainCNAME": {model.ScopeFull},
+	"createDomainText":  {model.ScopeTXTWrite, model.ScopeFull},
+	"getDomainText":     {model.ScopeRead, model.ScopeTXTWrite, model.ScopeFull},
+	"updateDomainText":  {model.ScopeTXTWrite, model.ScopeFull},
+	"deleteDomainText":  {model.ScopeTXTWrite, model.ScopeFull},
+	"watchDomain":       {model.ScopeRead, model.ScopeFull},
+}
+
+// Used to find the zone and the fqdn holding the token of the fqdn
+// normal text record & acme text record need special treatment, they are held by the domain token
+func tokenHolder(fqdn string) (*backend.Zone, string, error) {
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		return nil, fqdn, err
 	}
+	return z, z.Domain(fqdn), nil
+}
 
-	hash, err := base64.StdEncoding.DecodeString(token)
+// compareToken returns nil if the token matches the fqdn, the expired error is returned if the token matches but its lease is expired
+func compareToken(ctx context.Context, fqdn, token string) error {
+	ctx, span := tracing.StartChild(ctx, "compareToken")
+	defer span.End()
+
+	log := backend.Logger(ctx)
+	z, fqdn, err := tokenHolder(fqdn)
 	if err != nil {
-		logrus.Errorf("failed to decode token: %s", fqdn)
-		return false
+		log.Errorf("failed to find zone of %s, err: %v", fqdn, err)
+		return errForbidden
+	}
+
+	b := z.Backend.WithContext(ctx)
+	origin, expired := b.GetToken(fqdn)
+	if expired != nil && backend.KindOf(expired) != backend.KindExpired {
+		log.Errorf("failed to get token origin %s, err: %v", fqdn, expired)
+		return errForbidden
 	}
 
-	b := backend.GetBackend()
-	origin, err := b.GetToken(fqdn)
+	if util.IsHashedToken(origin) {
+		if !util.CompareHashedToken(origin, token) {
+			log.Errorf("failed to compare token of %s", fqdn)
+			return errForbidden
+		}
+	} else if err := compareLegacyToken(ctx, b, fqdn, origin, token); err != nil {
+		return err
+	}
+	log.Debugf("token **** matched with fqdn %s", fqdn)
+	return expired
+}
+
+// compareLegacyToken compares the token issued before tokens were hashed, the token is the bcrypt hash of the origin kept in plaintext
+// the origin is replaced by the hash of the token once it matches, so the client keeps using the same token
+func compareLegacyToken(ctx context.Context, b backend.Backend, fqdn, origin, token string) error {
+	log := backend.Logger(ctx)
+	hash, err := base64.StdEncoding.DecodeString(token)
 	if err != nil {
-		logrus.Errorf("failed to get token origin %s, err: %v", fqdn, err)
-		return false
+		log.Errorf("failed to decode token: %s", fqdn)
+		return errForbidden
 	}
 
+	// bcrypt is slow by design, so it has its own span
+	_, span := tracing.StartChild(ctx, "bcrypt.CompareHashAndPassword")
 	err = bcrypt.CompareHashAndPassword(hash, []byte(origin))
+	span.End()
 	if err != nil {
-		logrus.WithFields(logrus.Fields{
-			"token": token,
-			"fqdn":  fqdn,
-		}).Errorf("failed to compare token, err: %v", err)
-		return false
-	}
-	logrus.Debugf("token **** matched with fqdn %s", fqdn)
-	return true
+		log.Errorf("failed to compare token of %s, err: %v", fqdn, err)
+		return errForbidden
+	}
+
+	if err := b.UpdateToken(fqdn, util.HashToken(token)); err != nil {
+		log.Warnf("failed to hash the legacy token of %s, err: %v", fqdn, err)
+		return nil
+	}
+	log.Infof("legacy token of %s is hashed", fqdn)
+	return nil
+}
+
+// generateSubToken returns a sub token of the scope and its secret, only the digest of the secret is kept by the backend
+func generateSubToken(opts *model.SubTokenOptions) (*model.SubToken, string) {
+	secret := util.RandStringWithAll(subTokenSecretLength)
+	digest := sha256.Sum256([]byte(secret))
+	t := &model.SubToken{
+		SID:       util.RandStringWithSmall(subTokenIDLength),
+		Name:      opts.Name,
+		Scope:     opts.Scope,
+		Digest:    hex.EncodeToString(digest[:]),
+		CreatedOn: time.Now().UnixNano(),
+	}
+	return t, secret
+}
+
+// compareSubToken returns nil if the sub token of the fqdn matches and its scope is one of the scopes
+// the sub token is formatted as st_<id>.<secret>, the expired error is returned if the domain lease is expired
+func compareSubToken(ctx context.Context, fqdn, token string, scopes []string) error {
+	log := backend.Logger(ctx)
+	z, fqdn, err := tokenHolder(fqdn)
+	if err != nil {
+		log.Errorf("failed to find zone of %s, err: %v", fqdn, err)
+		return errForbidden
+	}
+
+	ss := strings.SplitN(strings.TrimPrefix(token, model.SubTokenPrefix), ".", 2)
+	if len(ss) != 2 {
+		log.Errorf("failed to parse sub token of %s", fqdn)
+		return errForbidden
+	}
+
+	b := z.Backend.WithContext(ctx)
+	ts, err := b.GetSubTokens(fqdn)
+	if err != nil {
+		log.Errorf("failed to get sub tokens of %s, err: %v", fqdn, err)
+		return errForbidden
+	}
+
+	var t *model.SubToken
+	for _, v := range ts {
+		if v.SID == ss[0] {
+			t = v
+		}
+	}
+	if t == nil {
+		log.Errorf("failed to find sub token %s of %s", ss[0], fqdn)
+		return errForbidden
+	}
+
+	digest := sha256.Sum256([]byte(ss[1]))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(digest[:])), []byte(t.Digest)) != 1 {
+		log.Errorf("failed to compare sub token %s of %s", t.SID, fqdn)
+		return errForbidden
+	}
+
+	allowed := false
+	for _, s := range scopes {
+		if s == t.Scope {
+			allowed = true
+		}
+	}
+	if !allowed {
+		log.Errorf("sub token %s of %s with scope %s is not allowed", t.SID, fqdn, t.Scope)
+		return errForbidden
+	}
+	log.Debugf("sub token %s matched with fqdn %s", t.SID, fqdn)
+
+	if _, err := b.GetToken(fqdn); backend.KindOf(err) == backend.KindExpired {
+		return err
+	}
+	return nil
 }
 
 func tokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// createDomain and ping and metrics have no need to check token
+		// createDomain and ping and metrics have no need to check token, admin routes check admin key instead
+		// the routes of a domain require the domain token or a sub token allowed by the route
 		logrus.Debugf("request URL path: %s", r.URL.Path)
-		if (r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/txt")) ||
-			(r.Method != http.MethodPost && !strings.HasPrefix(r.URL.Path, "/ping") && !strings.HasPrefix(r.URL.Path, "/metrics")) {
-			authorization := r.Header.Get("Authorization")
-			token := strings.TrimLeft(authorization, "Bearer ")
-			fqdn, ok := mux.Vars(r)["fqdn"]
-			if ok {
-				if !compareToken(fqdn, token) {
-					returnHTTPError(w, http.StatusForbidden, errors.New("forbidden to use"))
-					return
-				}
-			} else {
-				returnHTTPError(w, http.StatusForbidden, errors.New("must specific the fqdn"))
-				return
-			}
+		if isAdminRoute(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		fqdn, ok := mux.Vars(r)["fqdn"]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
 		}
 
+		if err := authorizeDomain(r, fqdn); err != nil {
+			returnHTTPError(w, http.StatusForbidden, err)
+			return
+		}
+		if !allowToken(w, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+			return
+		}
+		loadAuditState(r)
+
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Used to check the bearer token of the request against the fqdn, a sub token must have a scope allowed by the route
+func authorizeDomain(r *http.Request, fqdn string) error {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if strings.HasPrefix(token, model.SubTokenPrefix) {
+		return compareSubToken(r.Context(), fqdn, token, routeScopes[mux.CurrentRoute(r).GetName()])
+	}
+	return compareToken(r.Context(), fqdn, token)
+}
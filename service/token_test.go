@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+	"github.com/rancher/rdns-server/util"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLegacyTokenIsHashedOnUse(t *testing.T) {
+	fqdn, _ := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn
+
+	// older versions kept the origin in plaintext and responded the bcrypt hash of it as the token
+	origin := util.RandStringWithAll(tokenLength)
+	z, err := backend.MatchZone(fqdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Backend.UpdateToken(fqdn, origin); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(origin), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := base64.StdEncoding.EncodeToString(hash)
+
+	if code := doRequest(t, http.MethodGet, path, origin, nil, nil); code != http.StatusForbidden {
+		t.Errorf("get domain by the origin = %d, want 403", code)
+	}
+	if code := doRequest(t, http.MethodGet, path, token, nil, nil); code != http.StatusOK {
+		t.Fatalf("get domain by the legacy token = %d, want 200", code)
+	}
+
+	stored, err := database.GetDatabase().QueryToken(fqdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token != util.HashToken(token) {
+		t.Errorf("stored token = %s, want the hash of the legacy token", stored.Token)
+	}
+	if code := doRequest(t, http.MethodGet, path, token, nil, nil); code != http.StatusOK {
+		t.Errorf("get domain by the hashed legacy token = %d, want 200", code)
+	}
+}
+
+func TestRotateToken(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn
+
+	res := struct {
+		Token string `json:"token"`
+	}{}
+	if code := doRequest(t, http.MethodPost, path+"/token/rotate", token, nil, &res); code != http.StatusOK || res.Token == "" {
+		t.Fatalf("rotate token = %d, %+v", code, res)
+	}
+	if code := doRequest(t, http.MethodGet, path, token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("get domain by the old token = %d, want 403", code)
+	}
+	if code := doRequest(t, http.MethodGet, path, res.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("get domain by the new token = %d, want 200", code)
+	}
+}
+
+func TestRotateTokenBySubName(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+
+	// the token of an acme text record is held by its domain
+	res := &model.Response{}
+	if code := doRequest(t, http.MethodPost, "/v1/domain/_acme-challenge."+fqdn+"/token/rotate", token, nil, res); code != http.StatusOK || res.Token == "" {
+		t.Fatalf("rotate token by sub-name = %d, %+v", code, res)
+	}
+	if res.Data.Fqdn != fqdn {
+		t.Errorf("fqdn of rotated token = %s, want %s", res.Data.Fqdn, fqdn)
+	}
+	if code := doRequest(t, http.MethodGet, "/v1/domain/"+fqdn, token, nil, nil); code != http.StatusForbidden {
+		t.Errorf("get domain by the old token = %d, want 403", code)
+	}
+	if code := doRequest(t, http.MethodGet, "/v1/domain/"+fqdn, res.Token, nil, nil); code != http.StatusOK {
+		t.Errorf("get domain by the new token = %d, want 200", code)
+	}
+}
+
+func TestSubTokensBySubName(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	sub := "/v1/domain/_acme-challenge." + fqdn + "/tokens"
+
+	created := struct {
+		Data model.SubTokenInfo `json:"data"`
+	}{}
+	if code := doRequest(t, http.MethodPost, sub, token, map[string]string{"scope": model.ScopeTXTWrite}, &created); code != http.StatusOK || created.Data.Token == "" {
+		t.Fatalf("create sub token by sub-name = %d, %+v", code, created)
+	}
+
+	// the sub token is held by the domain, so it is listed by the domain too
+	listed := struct {
+		Data []model.SubTokenInfo `json:"data"`
+	}{}
+	if code := doRequest(t, http.MethodGet, "/v1/domain/"+fqdn+"/tokens", token, nil, &listed); code != http.StatusOK {
+		t.Fatalf("list sub tokens = %d", code)
+	}
+	if len(listed.Data) != 1 || listed.Data[0].ID != created.Data.ID {
+		t.Fatalf("sub tokens of the domain = %+v, want %s", listed.Data, created.Data.ID)
+	}
+
+	if code := doRequest(t, http.MethodDelete, sub+"/"+created.Data.ID, token, nil, nil); code != http.StatusOK {
+		t.Fatalf("delete sub token by sub-name = %d", code)
+	}
+	listed.Data = nil
+	doRequest(t, http.MethodGet, sub, token, nil, &listed)
+	if len(listed.Data) != 0 {
+		t.Errorf("sub tokens after delete = %+v, want none", listed.Data)
+	}
+}
+
+func TestMigrateToken(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	fqdn := "migrated-" + util.RandStringWithSmall(6) + "." + testZone
+	origin := util.RandStringWithAll(tokenLength)
+
+	tests := []struct {
+		name  string
+		path  string
+		token string
+		code  int
+	}{
+		{name: "origin of v0.4.x", path: fqdn, token: origin, code: http.StatusOK},
+		{name: "zone not served", path: "/tokenv3/migrated_example_com", token: "zzzzzz", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]interface{}{"path": tt.path, "token": tt.token, "expiration": expiration}
+			if code := doRequest(t, http.MethodPost, "/v1/migrate/token", testAdminKey, body, nil); code != tt.code {
+				t.Fatalf("migrate token = %d, want %d", code, tt.code)
+			}
+		})
+	}
+
+	// the origin is stored as is, so the token held by the v0.4.x client is accepted and hashed on first use
+	stored, err := database.GetDatabase().QueryToken(fqdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token != origin {
+		t.Fatalf("stored token = %s, want the origin", stored.Token)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(origin), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := base64.StdEncoding.EncodeToString(hash)
+	if code := doRequest(t, http.MethodGet, "/v1/domain/"+fqdn, token, nil, nil); code == http.StatusForbidden {
+		t.Fatal("get migrated domain by the v0.4.x token = 403")
+	}
+
+	stored, err = database.GetDatabase().QueryToken(fqdn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Token != util.HashToken(token) {
+		t.Errorf("stored token = %s, want the hash of the v0.4.x token", stored.Token)
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingMiddleware starts the span of the request, the trace of the client is continued if it sends traceparent
+// the span is named by the route template so the requests of a route are grouped
+func tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, template := "", r.URL.Path
+		if route := mux.CurrentRoute(r); route != nil {
+			name = route.GetName()
+			if t, err := route.GetPathTemplate(); err == nil {
+				template = t
+			}
+		}
+
+		ctx, span := tracing.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+template,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", template),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("rdns.route", name),
+				attribute.String("rdns.request_id", backend.RequestID(r.Context())),
+			))
+		if fqdn := mux.Vars(r)["fqdn"]; fqdn != "" {
+			span.SetAttributes(attribute.String("rdns.fqdn", fqdn))
+		}
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(sw.status))
+		}
+	})
+}
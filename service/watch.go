@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/rdns-server/backend"
+	"github.com/rancher/rdns-server/model"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const watchHeartbeatInterval = 30 * time.Second
+
+var (
+	// watchStop is closed once the server is shut down, the streams never end on their own so they would block the shutdown
+	watchStop     = make(chan struct{})
+	watchStopOnce sync.Once
+
+	errStreamUnsupported = errors.New("streaming is not supported by the connection")
+)
+
+// Used to end the watch streams, it is called when the server starts to shut down
+func stopWatches() {
+	watchStopOnce.Do(func() {
+		close(watchStop)
+	})
+}
+
+// watchDomain streams the changes of the domain, the stream ends once the domain is deleted or the token is not valid anymore
+func watchDomain(w http.ResponseWriter, r *http.Request) {
+	z, fqdn, err := tokenHolder(mux.Vars(r)["fqdn"])
+	if err != nil {
+		returnHTTPError(w, http.StatusNotFound, err)
+		return
+	}
+	serveWatch(w, r, z.Backend, fqdn)
+}
+
+// watchDomains streams the changes of all domains, the zones share the etcd keys or the database, so the default backend watches them all
+func watchDomains(w http.ResponseWriter, r *http.Request) {
+	serveWatch(w, r, backend.GetBackend(), "")
+}
+
+// Used to stream the changes as Server-Sent Events, the revision of a change is the event ID, so clients resume by Last-Event-ID.
+// The changes of a domain watch are sent until the domain is deleted, the token is checked again on each heartbeat.
+func serveWatch(w http.ResponseWriter, r *http.Request, b backend.Backend, fqdn string) {
+	revision, err := model.ParseWatchRevision(r)
+	if err != nil {
+		returnHTTPError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		returnHTTPError(w, http.StatusInternalServerError, errStreamUnsupported)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	changes, err := b.Watch(ctx, fqdn, revision)
+	if err != nil {
+		returnHTTPError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// proxies such as nginx buffer the responses by default, the events must be sent at once
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log := backend.Logger(ctx)
+	heartbeat := time.NewTicker(watchHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-watchStop:
+			return
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if fqdn != "" {
+				if err := authorizeDomain(r, fqdn); err != nil {
+					log.Infof("watch of %s is stopped, err: %v", fqdn, err)
+					return
+				}
+			}
+			// comment lines are ignored by clients, they keep the connection from being closed by idle proxies
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case c, ok := <-changes:
+			if !ok {
+				log.Warnf("watch of %q after revision %d is ended by the backend", fqdn, revision)
+				return
+			}
+			e, err := watchEvent(ctx, c)
+			if err != nil {
+				log.Errorf("failed to read the domain of change %d, err: %v", c.Revision, err)
+				return
+			}
+			if err := writeWatchEvent(w, e); err != nil {
+				return
+			}
+			flusher.Flush()
+			revision = c.Revision
+
+			if fqdn != "" && e.Type == model.WatchDeleted {
+				return
+			}
+		}
+	}
+}
+
+// Used to convert the change to the event, the domain is read at once so the event carries the current state of the domain
+func watchEvent(ctx context.Context, c model.WatchChange) (*model.WatchEvent, error) {
+	e := &model.WatchEvent{
+		Revision: c.Revision,
+		Type:     model.WatchBookmark,
+	}
+	if c.Fqdn == "" {
+		return e, nil
+	}
+
+	z, err := backend.MatchZone(c.Fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := z.Backend.WithContext(ctx).Inspect(c.Fqdn)
+	switch {
+	case backend.KindOf(err) == backend.KindNotFound:
+		e.Type = model.WatchDeleted
+		e.Domain = &model.Domain{Fqdn: c.Fqdn}
+	case err != nil:
+		return nil, err
+	default:
+		e.Type = model.WatchUpdated
+		e.Domain = info.Domain()
+	}
+	return e, nil
+}
+
+// Used to write the event in the format of Server-Sent Events
+func writeWatchEvent(w http.ResponseWriter, e *model.WatchEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Revision, e.Type, data)
+	return err
+}
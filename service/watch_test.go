@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/rdns-server/database"
+	"github.com/rancher/rdns-server/model"
+)
+
+// watchStream reads the events of a watch response
+type watchStream struct {
+	resp    *http.Response
+	scanner *bufio.Scanner
+	cancel  context.CancelFunc
+}
+
+// Used to start watching the path, the stream is closed when the test ends
+func startWatch(t *testing.T, path, token string, lastEventID int64) (*watchStream, int) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, testServer.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID > 0 {
+		req.Header.Set(model.LastEventIDHeader, strconv.FormatInt(lastEventID, 10))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	s := &watchStream{resp: resp, scanner: bufio.NewScanner(resp.Body), cancel: cancel}
+	t.Cleanup(s.close)
+	return s, resp.StatusCode
+}
+
+func (s *watchStream) close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
+// Used to read the next event, heartbeats are skipped and nil is returned once the stream ends
+func (s *watchStream) next(t *testing.T) *model.WatchEvent {
+	t.Helper()
+
+	var id, event, data string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		switch {
+		case line == "" && data != "":
+			e := &model.WatchEvent{}
+			if err := json.Unmarshal([]byte(data), e); err != nil {
+				t.Fatalf("failed to decode event %s, err: %v", data, err)
+			}
+			if e.Type != event || strconv.FormatInt(e.Revision, 10) != id {
+				t.Errorf("event %s (id %s) carries %s (revision %d)", event, id, e.Type, e.Revision)
+			}
+			return e
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return nil
+}
+
+func TestWatchResumesAfterRevision(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn
+
+	w, code := startWatch(t, path+"/watch", token, 0)
+	if code != http.StatusOK {
+		t.Fatalf("watch = %d, want 200", code)
+	}
+	bookmark := w.next(t)
+	if bookmark == nil || bookmark.Type != model.WatchBookmark {
+		t.Fatalf("first event = %+v, want bookmark", bookmark)
+	}
+	w.close()
+
+	// the change is made while the client is disconnected
+	if code := doRequest(t, http.MethodPut, path, token, map[string]interface{}{"hosts": []string{"3.3.3.3"}}, nil); code != http.StatusOK {
+		t.Fatalf("update domain = %d", code)
+	}
+
+	check := func(w *watchStream) {
+		t.Helper()
+		e := w.next(t)
+		if e == nil || e.Type != model.WatchUpdated || e.Revision <= bookmark.Revision {
+			t.Fatalf("event = %+v, want updated after revision %d", e, bookmark.Revision)
+		}
+		if e.Domain == nil || e.Domain.Fqdn != fqdn || !reflect.DeepEqual(e.Domain.Hosts, []string{"3.3.3.3"}) {
+			t.Errorf("domain of event = %+v", e.Domain)
+		}
+	}
+
+	w, code = startWatch(t, path+"/watch", token, bookmark.Revision)
+	if code != http.StatusOK {
+		t.Fatalf("resume by Last-Event-ID = %d, want 200", code)
+	}
+	check(w)
+	w.close()
+
+	// Last-Event-ID wins over the revision query
+	w, code = startWatch(t, path+"/watch?revision=x", token, bookmark.Revision)
+	if code != http.StatusOK {
+		t.Fatalf("resume by Last-Event-ID with invalid query = %d, want 200", code)
+	}
+	check(w)
+	w.close()
+
+	w, code = startWatch(t, path+"/watch?revision="+strconv.FormatInt(bookmark.Revision, 10), token, 0)
+	if code != http.StatusOK {
+		t.Fatalf("resume by revision query = %d, want 200", code)
+	}
+	check(w)
+}
+
+func TestWatchEndsOnceDomainIsDeleted(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+
+	w, _ := startWatch(t, "/v1/domain/"+fqdn+"/watch", token, 0)
+	bookmark := w.next(t)
+	if bookmark == nil {
+		t.Fatal("no bookmark is sent")
+	}
+
+	if code := doRequest(t, http.MethodDelete, "/v1/admin/domains/"+fqdn, testAdminKey, nil, nil); code != http.StatusOK {
+		t.Fatalf("force delete domain = %d", code)
+	}
+
+	e := w.next(t)
+	if e == nil || e.Type != model.WatchDeleted || e.Domain == nil || e.Domain.Fqdn != fqdn {
+		t.Fatalf("event = %+v, want deleted", e)
+	}
+	if e := w.next(t); e != nil {
+		t.Errorf("event after deleted = %+v, want the stream ended", e)
+	}
+
+	// force deleting the domain is recorded as one change
+	cs, err := database.GetDatabase().ListDomainChanges(fqdn, bookmark.Revision, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cs) != 1 {
+		t.Errorf("changes after force delete = %d, want 1", len(cs))
+	}
+}
+
+func TestWatchRejectsRevision(t *testing.T) {
+	fqdn, token := createTestDomain(t, "4.4.4.4")
+	path := "/v1/domain/" + fqdn + "/watch"
+
+	_, last, err := database.GetDatabase().QueryDomainChangeRange()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{
+		{name: "invalid revision", query: "?revision=x", code: http.StatusBadRequest},
+		{name: "negative revision", query: "?revision=-1", code: http.StatusBadRequest},
+		{name: "future revision", query: "?revision=" + strconv.FormatInt(last+10, 10), code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, code := startWatch(t, path+tt.query, token, 0); code != tt.code {
+				t.Errorf("watch = %d, want %d", code, tt.code)
+			}
+		})
+	}
+
+	t.Run("purged revision", func(t *testing.T) {
+		// the newest change is kept, so the changes after the first revision are gone
+		createTestDomain(t, "5.5.5.5")
+		now := time.Now()
+		if err := database.GetDatabase().DeleteExpiredDomainChanges(&now); err != nil {
+			t.Fatal(err)
+		}
+		if _, code := startWatch(t, path+"?revision=1", token, 0); code != http.StatusGone {
+			t.Errorf("watch = %d, want 410", code)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+package slug
+
+const (
+	errAllocateSlug     = "failed to allocate slug after %d attempts"
+	errInvalidAlphabet  = "slug alphabet must have at least 2 unique lowercase letters or digits: %q"
+	errInvalidAffix     = "slug %s must be lowercase letters, digits and hyphens without a hyphen at the %s: %q"
+	errInvalidLength    = "slug length must be at least %d, got %d"
+	errInvalidRetries   = "slug max retries must be at least 1, got %d"
+	errLabelTooLong     = "slug with prefix and suffix must be at most %d characters, got %d"
+	errReadDenylistFile = "failed to read slug denylist file %s"
+)
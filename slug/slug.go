@@ -0,0 +1,187 @@
+package slug
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rancher/rdns-server/metric"
+	"github.com/rancher/rdns-server/util"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultLength     = 6
+	DefaultAlphabet   = "0123456789abcdefghijklmnopqrstuvwxyz"
+	DefaultMaxRetries = 100
+	minLength         = 1
+	maxLabelLength    = 63
+)
+
+var (
+	affixRegexp    = regexp.MustCompile(`^[a-z0-9-]*$`)
+	alphabetRegexp = regexp.MustCompile(`^[a-z0-9]+$`)
+
+	lock    sync.RWMutex
+	current = &Policy{
+		Length:     DefaultLength,
+		Alphabet:   DefaultAlphabet,
+		MaxRetries: DefaultMaxRetries,
+	}
+)
+
+// Policy decides how random slugs are generated, a slug is <prefix><random string of the alphabet><suffix>.
+type Policy struct {
+	Length     int
+	Alphabet   string
+	Prefix     string
+	Suffix     string
+	Denylist   []string
+	MaxRetries int
+}
+
+// Validate checks the generated slugs are DNS labels.
+func (p *Policy) Validate() error {
+	if p.Length < minLength {
+		return errors.Errorf(errInvalidLength, minLength, p.Length)
+	}
+	if !alphabetRegexp.MatchString(p.Alphabet) || len(uniqueChars(p.Alphabet)) < 2 {
+		return errors.Errorf(errInvalidAlphabet, p.Alphabet)
+	}
+	if !affixRegexp.MatchString(p.Prefix) || strings.HasPrefix(p.Prefix, "-") {
+		return errors.Errorf(errInvalidAffix, "prefix", "beginning", p.Prefix)
+	}
+	if !affixRegexp.MatchString(p.Suffix) || strings.HasSuffix(p.Suffix, "-") {
+		return errors.Errorf(errInvalidAffix, "suffix", "end", p.Suffix)
+	}
+	if n := len(p.Prefix) + p.Length + len(p.Suffix); n > maxLabelLength {
+		return errors.Errorf(errLabelTooLong, maxLabelLength, n)
+	}
+	if p.MaxRetries < 1 {
+		return errors.Errorf(errInvalidRetries, p.MaxRetries)
+	}
+	return nil
+}
+
+// Generate returns a random slug of the policy, it may be denied.
+func (p *Policy) Generate() string {
+	return p.Prefix + util.SecureRandomString(uniqueChars(p.Alphabet), p.Length) + p.Suffix
+}
+
+// IsDenied reports whether the name contains any word of the denylist.
+func (p *Policy) IsDenied(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range p.Denylist {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPolicy replaces the current policy after validating it.
+func SetPolicy(p *Policy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	current = p
+
+	return nil
+}
+
+// GetPolicy returns the current policy.
+func GetPolicy() *Policy {
+	lock.RLock()
+	defer lock.RUnlock()
+	return current
+}
+
+// Allocate generates random slugs until the available func accepts one, slugs containing denied words are skipped.
+// The attempts are observed by metrics and an error is returned if no slug is accepted after the max retries.
+func Allocate(available func(slug string) (bool, error)) (string, error) {
+	p := GetPolicy()
+
+	for i := 1; i <= p.MaxRetries; i++ {
+		s := p.Generate()
+		if p.IsDenied(s) {
+			logrus.Debugf("slug %s is denied, will try another", s)
+			continue
+		}
+
+		ok, err := available(s)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			metric.ObserveSlugAllocation(i, true)
+			return s, nil
+		}
+		logrus.Debugf("slug %s can not be used, will try another", s)
+	}
+
+	metric.ObserveSlugAllocation(p.MaxRetries, false)
+	logrus.Warnf(errAllocateSlug, p.MaxRetries)
+
+	return "", errors.Errorf(errAllocateSlug, p.MaxRetries)
+}
+
+// ParseDenylist merges the comma separated words and the words of the file (one word per line, # for comments).
+func ParseDenylist(words, file string) ([]string, error) {
+	result := make([]string, 0)
+	for _, w := range strings.Split(words, ",") {
+		result = appendWord(result, w)
+	}
+
+	if file == "" {
+		return result, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return result, errors.Wrapf(err, errReadDenylistFile, file)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		result = appendWord(result, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return result, errors.Wrapf(err, errReadDenylistFile, file)
+	}
+
+	return result, nil
+}
+
+// Used to append a trimmed lowercase word, empty words are ignored
+func appendWord(words []string, w string) []string {
+	w = strings.ToLower(strings.TrimSpace(w))
+	if w == "" {
+		return words
+	}
+	return append(words, w)
+}
+
+// Used to remove duplicate characters, so each character has the same probability
+func uniqueChars(s string) string {
+	seen := make(map[rune]bool)
+	var b strings.Builder
+	for _, c := range s {
+		if !seen[c] {
+			seen[c] = true
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
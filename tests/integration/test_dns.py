@@ -59,3 +59,41 @@ def test_dns():  # NOQA
 
     url = build_url(BASE_URL, "/" + fqdn, "")
     delete_domain_test(url, token)
+
+
+def test_dns_aaaa():  # NOQA
+    url = build_url(BASE_URL, "", "")
+    response = create_domain_test(url,
+                                  {
+                                      'fqdn': '',
+                                      'hosts': ["4.4.4.4", "2001:db8::4"],
+                                      'subdomain': {
+                                          'sub1': ["2001:db8::5"],
+                                      },
+                                  })
+    result = response.json()
+    token = result['token']
+    fqdn = result['data']['fqdn']
+    assert sorted(result['data']['hosts']) == ["2001:db8::4", "4.4.4.4"]
+
+    # test query A record only returns IPv4 hosts
+    dns_query = dns.message.make_query(fqdn, 'A')
+    response = dns.query.udp(dns_query, '8.8.8.8')
+    for i in response.answer:
+        for j in i.items:
+            assert str(j) in ["4.4.4.4"]
+
+    # test query AAAA record
+    dns_query = dns.message.make_query(fqdn, 'AAAA')
+    response = dns.query.udp(dns_query, '8.8.8.8')
+    for i in response.answer:
+        for j in i.items:
+            assert str(j) in ["2001:db8::4"]
+    sub_query = dns.message.make_query('sub1.' + fqdn, 'AAAA')
+    response = dns.query.udp(sub_query, '8.8.8.8')
+    for i in response.answer:
+        for j in i.items:
+            assert str(j) in ["2001:db8::5"]
+
+    url = build_url(BASE_URL, "/" + fqdn, "")
+    delete_domain_test(url, token)
@@ -107,16 +107,186 @@ def test_server_apis():  # NOQA
     response = get_domain_test(url, token)
     assert response != ""
     result = response.json()
-    assert result['status'] == 200
-    assert result['data'] == {}
+    assert response.status_code == 404
+    assert result['status'] == 404
 
     # check acme text record
     acme_url = build_url(BASE_URL, "/_acme-challenge." + fqdn, "/txt")
     response = get_domain_test(acme_url, token)
     assert response != ""
     result = response.json()
-    assert result['status'] == 200
-    assert result['data'] == {}
+    assert response.status_code == 404
+    assert result['status'] == 404
+
+
+def test_validation():  # NOQA
+    url = build_url(BASE_URL, "", "")
+    response = create_domain_test(url,
+                                  {
+                                      'fqdn': '',
+                                      'hosts': ["1.1.1.1", "not-an-ip"],
+                                      'subdomain': {
+                                          'bad_label': ["9.9.9.9"],
+                                      },
+                                  })
+    assert response.status_code == 400
+    fields = [e['field'] for e in response.json()['errors']]
+    assert fields == ['hosts[1]', 'subdomain.bad_label']
+
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"]})
+    assert response.status_code == 200
+    result = response.json()
+    token = result['token']
+    fqdn = result['data']['fqdn']
+
+    acme_url = build_url(BASE_URL, "/_acme-challenge." + fqdn, "/txt")
+    response = create_domain_text_test(acme_url, token, {"text": "a" * 254})
+    assert response.status_code == 400
+    assert response.json()['errors'][0]['field'] == 'text'
+
+    cname_url = build_url(BASE_URL, "", "/cname")
+    response = create_domain_test(cname_url, {"cname": "1.2.3.4"})
+    assert response.status_code == 400
+    assert response.json()['errors'][0]['field'] == 'cname'
+
+    url = build_url(BASE_URL, "/" + fqdn, "")
+    delete_domain_test(url, token)
+
+
+def test_vanity_name():  # NOQA
+    name = 'vanity-%d' % int(time.time())
+    url = build_url(BASE_URL, "", "")
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"], 'name': name})
+    assert response.status_code == 200
+    result = response.json()
+    token = result['token']
+    fqdn = result['data']['fqdn']
+    assert fqdn.startswith(name + '.')
+
+    # the name is taken by the domain
+    response = create_domain_test(url, {'hosts': ["2.2.2.2"], 'name': name})
+    assert response.status_code == 409
+
+    cname_url = build_url(BASE_URL, "", "/cname")
+    response = create_domain_test(cname_url,
+                                  {'cname': 'example.com', 'name': name})
+    assert response.status_code == 409
+
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"],
+                                        'name': 'Not_A_Label'})
+    assert response.status_code == 400
+    assert response.json()['errors'][0]['field'] == 'name'
+
+    # the name is still frozen after the domain is deleted
+    delete_domain_test(build_url(BASE_URL, "/" + fqdn, ""), token)
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"], 'name': name})
+    assert response.status_code == 409
+
+
+def test_rotate_token():  # NOQA
+    url = build_url(BASE_URL, "", "")
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"]})
+    assert response.status_code == 200
+    result = response.json()
+    token = result['token']
+    fqdn = result['data']['fqdn']
+
+    rotate_url = build_url(BASE_URL, "/" + fqdn, "/token/rotate")
+    response = rotate_token_test(rotate_url, "")
+    assert response.status_code == 403
+
+    response = rotate_token_test(rotate_url, token)
+    assert response.status_code == 200
+    new_token = response.json()['token']
+    assert new_token != ""
+
+    # the old token is rejected at once
+    domain_url = build_url(BASE_URL, "/" + fqdn, "")
+    response = get_domain_test(domain_url, token)
+    assert response.status_code == 403
+
+    response = get_domain_test(domain_url, new_token)
+    assert response.status_code == 200
+
+    delete_domain_test(domain_url, new_token)
+
+
+def test_sub_tokens():  # NOQA
+    url = build_url(BASE_URL, "", "")
+    response = create_domain_test(url, {'hosts': ["1.1.1.1"]})
+    assert response.status_code == 200
+    result = response.json()
+    token = result['token']
+    fqdn = result['data']['fqdn']
+
+    tokens_url = build_url(BASE_URL, "/" + fqdn, "/tokens")
+    response = create_domain_text_test(tokens_url, token, {'scope': 'admin'})
+    assert response.status_code == 400
+    assert response.json()['errors'][0]['field'] == 'scope'
+
+    response = create_domain_text_test(tokens_url, token,
+                                       {'scope': 'read', 'name': 'monitor'})
+    assert response.status_code == 200
+    read_token = response.json()['data']['token']
+    read_id = response.json()['data']['id']
+    response = create_domain_text_test(tokens_url, token,
+                                       {'scope': 'txt:write'})
+    assert response.status_code == 200
+    txt_token = response.json()['data']['token']
+
+    # sub tokens are listed without the secrets
+    response = get_domain_test(tokens_url, token)
+    assert response.status_code == 200
+    assert len(response.json()['data']) == 2
+    assert 'token' not in response.json()['data'][0]
+
+    # sub tokens can not manage sub tokens
+    response = get_domain_test(tokens_url, read_token)
+    assert response.status_code == 403
+
+    domain_url = build_url(BASE_URL, "/" + fqdn, "")
+    response = get_domain_test(domain_url, read_token)
+    assert response.status_code == 200
+    response = update_domain_test(domain_url, read_token,
+                                  {'hosts': ["2.2.2.2"]})
+    assert response.status_code == 403
+    response = get_domain_test(domain_url, txt_token)
+    assert response.status_code == 403
+
+    text_url = build_url(BASE_URL, "/_acme-challenge." + fqdn, "/txt")
+    response = create_domain_text_test(text_url, txt_token, {'text': 'acme'})
+    assert response.status_code == 200
+    response = create_domain_text_test(text_url, read_token, {'text': 'acme'})
+    assert response.status_code == 403
+    response = get_domain_test(text_url, read_token)
+    assert response.status_code == 200
+
+    # the deleted sub token is rejected at once
+    response = delete_domain_test(tokens_url + "/" + read_id, token)
+    assert response.status_code == 200
+    response = get_domain_test(domain_url, read_token)
+    assert response.status_code == 403
+
+    delete_domain_test(text_url, token)
+    delete_domain_test(domain_url, token)
+
+
+def test_health():  # NOQA
+    root = BASE_URL[:-len('/v1')]
+    response = requests.get(root + '/healthz')
+    assert response.status_code == 200
+
+    response = requests.get(root + '/readyz')
+    assert response.status_code == 200
+    result = response.json()
+    assert result['data']['ready']
+    for check in result['data']['checks']:
+        assert check['ready']
+
+    # the request ID of the client is kept
+    response = requests.get(root + '/ping',
+                            headers={'X-Request-ID': 'test-health'})
+    assert response.headers['X-Request-ID'] == 'test-health'
 
 
 # This method creates the domain
@@ -154,6 +324,13 @@ def renew_domain_test(url, token):
     return response
 
 
+# This method rotates the domain token
+def rotate_token_test(url, token):
+    headers = build_header(token)
+    response = requests.post(url, data=None, headers=headers)
+    return response
+
+
 # This method updates the domain
 def update_domain_test(url, token, data):
     headers = build_header(token)
@@ -0,0 +1,9 @@
+package tracing
+
+const (
+	errEmptyEndpoint      = "trace endpoint must be set for the otlp exporter"
+	errExportSpans        = "failed to export spans to %s, status code: %d, body: %s"
+	errInvalidSampleRatio = "trace sample ratio must be between 0 and 1, got %v"
+	errNewExporter        = "failed to create %s trace exporter"
+	errUnknownExporter    = "unknown trace exporter %q, expected none, stdout or otlp"
+)
@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	otlpTimeout      = 10 * time.Second
+	maxErrorBodySize = 1024
+)
+
+// otlpExporter posts the spans to the OTLP/HTTP endpoint in the JSON encoding, the gRPC exporters of
+// OpenTelemetry require a newer gRPC than the one used by the etcd client, so the protocol is spoken here.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newOTLPExporter(endpoint string) (*otlpExporter, error) {
+	if endpoint == "" {
+		return nil, errors.New(errEmptyEndpoint)
+	}
+	if _, err := url.ParseRequestURI(endpoint); err != nil {
+		return nil, err
+	}
+	return &otlpExporter{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: otlpTimeout},
+	}, nil
+}
+
+// ExportSpans implements the sdktrace.SpanExporter interface.
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return errors.Errorf(errExportSpans, e.endpoint, resp.StatusCode, msg)
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+// Shutdown implements the sdktrace.SpanExporter interface, the spans are exported at once so nothing is left.
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// Used to group the spans by resource and instrumentation scope as OTLP requires
+func otlpRequest(spans []sdktrace.ReadOnlySpan) *otlpTraces {
+	traces := &otlpTraces{}
+	resources := make(map[attribute.Distinct]*otlpResourceSpans)
+	scopes := make(map[*otlpResourceSpans]map[string]*otlpScopeSpans)
+
+	for _, s := range spans {
+		key := s.Resource().Equivalent()
+		rs, ok := resources[key]
+		if !ok {
+			rs = &otlpResourceSpans{Resource: otlpResource{Attributes: otlpAttributes(s.Resource().Attributes())}}
+			resources[key] = rs
+			scopes[rs] = make(map[string]*otlpScopeSpans)
+			traces.ResourceSpans = append(traces.ResourceSpans, rs)
+		}
+
+		scope := s.InstrumentationScope()
+		ss, ok := scopes[rs][scope.Name+"@"+scope.Version]
+		if !ok {
+			ss = &otlpScopeSpans{Scope: otlpScope{Name: scope.Name, Version: scope.Version}}
+			scopes[rs][scope.Name+"@"+scope.Version] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+
+		ss.Spans = append(ss.Spans, otlpSpanOf(s))
+	}
+	return traces
+}
+
+// Used to convert the span, the IDs are hex encoded and the timestamps are strings in the JSON encoding of OTLP
+func otlpSpanOf(s sdktrace.ReadOnlySpan) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.SpanContext().TraceID().String(),
+		SpanID:            s.SpanContext().SpanID().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		Attributes:        otlpAttributes(s.Attributes()),
+		Status:            otlpStatus{Message: s.Status().Description},
+	}
+	if s.Parent().IsValid() {
+		span.ParentSpanID = s.Parent().SpanID().String()
+	}
+	for _, e := range s.Events() {
+		span.Events = append(span.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   otlpAttributes(e.Attributes),
+		})
+	}
+
+	// the codes of OpenTelemetry are ordered Unset, Error, Ok, while OTLP orders them Unset, Ok, Error
+	switch s.Status().Code {
+	case codes.Ok:
+		span.Status.Code = 1
+	case codes.Error:
+		span.Status.Code = 2
+	}
+	return span
+}
+
+// Used to convert the attributes, the values which are not scalars are sent as strings
+func otlpAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	result := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]interface{}
+		switch a.Value.Type() {
+		case attribute.BOOL:
+			v = map[string]interface{}{"boolValue": a.Value.AsBool()}
+		case attribute.INT64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(a.Value.AsInt64(), 10)}
+		case attribute.FLOAT64:
+			v = map[string]interface{}{"doubleValue": a.Value.AsFloat64()}
+		default:
+			v = map[string]interface{}{"stringValue": a.Value.Emit()}
+		}
+		result = append(result, otlpKeyValue{Key: string(a.Key), Value: v})
+	}
+	return result
+}
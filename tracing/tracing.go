@@ -0,0 +1,134 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+
+	// DefaultEndpoint is the OTLP/HTTP endpoint of a collector running beside the server.
+	DefaultEndpoint = "http://localhost:4318/v1/traces"
+
+	serviceName         = "rdns-server"
+	instrumentationName = "github.com/rancher/rdns-server"
+)
+
+// Options are the settings of the exporter, the endpoint is only used by the otlp exporter.
+type Options struct {
+	Exporter    string
+	Endpoint    string
+	SampleRatio float64
+}
+
+var provider *sdktrace.TracerProvider
+
+// Setup installs the tracer provider of the options, spans are not recorded if the exporter is none.
+// The sampler follows the decision of the caller if the request carries a trace, so a trace is never broken.
+func Setup(opts *Options) error {
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return errors.Errorf(errInvalidSampleRatio, opts.SampleRatio)
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		exporter, err = newOTLPExporter(opts.Endpoint)
+	default:
+		return errors.Errorf(errUnknownExporter, opts.Exporter)
+	}
+	if err != nil {
+		return errors.Wrapf(err, errNewExporter, opts.Exporter)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return nil
+}
+
+// Shutdown exports the spans left in the batch, it is a noop if tracing is disabled.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Extract returns a copy of the context carrying the trace of the request headers, e.g. traceparent.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Start starts a span, it is the root of a new trace if the context carries no span.
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// StartChild starts a span only under the span of the context, so the calls made in the background
+// such as the token count and etcd lease renewals don't start traces on their own.
+func StartChild(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := trace.SpanFromContext(ctx)
+	if !parent.SpanContext().IsValid() {
+		return ctx, parent
+	}
+	return Start(ctx, name, opts...)
+}
+
+// RecordError marks the span as failed by the error, nil is ignored.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End records the error and ends the span.
+func End(span trace.Span, err error) {
+	RecordError(span, err)
+	span.End()
+}
+
+// UnaryClientInterceptor traces the gRPC calls made under a span, it is used by the etcd clients.
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := StartChild(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("rpc.system", "grpc"), attribute.String("rpc.method", method)))
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	End(span, err)
+	return err
+}
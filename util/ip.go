@@ -0,0 +1,26 @@
+package util
+
+import (
+	"net"
+)
+
+// IsIPv6 reports whether the host is an IPv6 address, IPv4-mapped addresses are treated as IPv4.
+func IsIPv6(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// SplitHostsByFamily splits hosts into IPv4 (A) and IPv6 (AAAA) hosts, the order of hosts is kept.
+// Hosts which are not IPv6 addresses are returned as IPv4 hosts and left to the backend to validate.
+func SplitHostsByFamily(hosts []string) (v4, v6 []string) {
+	v4 = make([]string, 0)
+	v6 = make([]string, 0)
+	for _, h := range hosts {
+		if IsIPv6(h) {
+			v6 = append(v6, h)
+			continue
+		}
+		v4 = append(v4, h)
+	}
+	return
+}
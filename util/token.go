@@ -0,0 +1,32 @@
+package util
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// hashedTokenPrefix marks the hashed tokens, tokens stored before hashing was introduced are kept in plaintext
+const hashedTokenPrefix = "sha256:"
+
+// HashToken returns the value of the token to store, tokens are random strings so a plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashedTokenPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsHashedToken reports whether the stored value is returned by HashToken.
+func IsHashedToken(value string) bool {
+	return strings.HasPrefix(value, hashedTokenPrefix)
+}
+
+// CompareHashedToken reports whether the token matches the hashed value in constant time.
+func CompareHashedToken(value, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(value), []byte(HashToken(token))) == 1
+}
+
+// TokenFingerprint returns a short ID of the token, it tells tokens apart in logs without revealing them.
+func TokenFingerprint(token string) string {
+	return strings.TrimPrefix(HashToken(token), hashedTokenPrefix)[:16]
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashToken(t *testing.T) {
+	h := HashToken("secret")
+
+	// echo -n secret | sha256sum
+	if want := "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"; h != want {
+		t.Errorf("HashToken() = %s, want %s", h, want)
+	}
+	if !IsHashedToken(h) {
+		t.Error("hashed token is not recognized")
+	}
+	if IsHashedToken("secret") {
+		t.Error("plaintext token is recognized as hashed")
+	}
+}
+
+func TestCompareHashedToken(t *testing.T) {
+	h := HashToken("secret")
+
+	if !CompareHashedToken(h, "secret") {
+		t.Error("token does not match its hash")
+	}
+	for _, token := range []string{"", "Secret", "secret ", h} {
+		if CompareHashedToken(h, token) {
+			t.Errorf("token %q matches the hash of another token", token)
+		}
+	}
+}
+
+func TestTokenFingerprint(t *testing.T) {
+	f := TokenFingerprint("secret")
+
+	if len(f) != 16 || !strings.HasPrefix(HashToken("secret"), hashedTokenPrefix+f) {
+		t.Errorf("TokenFingerprint() = %s, want the first 16 characters of the hash", f)
+	}
+	if TokenFingerprint("other") == f {
+		t.Error("fingerprints of different tokens are the same")
+	}
+}